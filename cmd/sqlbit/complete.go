@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KEYWORDS are the words of the statements sqlbit understands.
+var KEYWORDS = []string{"select", "from", "where", "insert", "delete", "begin", "commit", "rollback", "vacuum", "create", "table", "into", "values"}
+
+// metaCommands returns the meta-commands listed by .help.
+func metaCommands() []string {
+	commands := []string{}
+	for _, line := range strings.Split(META_COMMAND_HELP, "\n") {
+		if isMetaCommand(line) {
+			commands = append(commands, strings.Fields(line)[0])
+		}
+	}
+	return commands
+}
+
+// complete is the tab completion of the interactive shell, it completes the
+// word before pos with a keyword, the table name or one of its columns, and
+// the first word of a line with a meta-command.
+func (s *shell) complete(line string, pos int) (head string, completions []string, tail string) {
+	head = line[:pos]
+	tail = line[pos:]
+	start := strings.LastIndexAny(head, " \t(),=<>!") + 1
+	prefix := head[start:]
+
+	if start == 0 && isMetaCommand(prefix) {
+		return head[:start], completeWord(prefix, metaCommands()), tail
+	}
+	completions = completeWord(prefix, matchCase(prefix, KEYWORDS))
+	completions = append(completions, completeWord(prefix, []string{s.table.Name()})...)
+	completions = append(completions, completeWord(prefix, s.table.Columns())...)
+	return head[:start], completions, tail
+}
+
+// completeWord returns the words starting with prefix, ignoring case.
+func completeWord(prefix string, words []string) []string {
+	completions := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(strings.ToLower(word), strings.ToLower(prefix)) {
+			completions = append(completions, word)
+		}
+	}
+	return completions
+}
+
+// matchCase upper cases the keywords when prefix is typed in upper case.
+func matchCase(prefix string, keywords []string) []string {
+	hasLetter := strings.IndexFunc(prefix, unicode.IsLetter) >= 0
+	if hasLetter == false || prefix != strings.ToUpper(prefix) {
+		return keywords
+	}
+	upper := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		upper[i] = strings.ToUpper(keyword)
+	}
+	return upper
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// The output modes of .mode and -mode, tuple is the format sqlbit always
+// printed rows in.
+const OUTPUT_MODE_TUPLE = "tuple"
+const OUTPUT_MODE_LIST = "list"
+const OUTPUT_MODE_LINE = "line"
+const OUTPUT_MODE_TABLE = "table"
+const OUTPUT_MODE_CSV = "csv"
+const OUTPUT_MODE_TSV = "tsv"
+const OUTPUT_MODE_JSON = "json"
+const OUTPUT_MODE_NDJSON = "ndjson"
+const OUTPUT_MODE_MARKDOWN = "markdown"
+const OUTPUT_MODE_INSERT = "insert"
+
+var OUTPUT_MODES = []string{
+	OUTPUT_MODE_TUPLE,
+	OUTPUT_MODE_LIST,
+	OUTPUT_MODE_LINE,
+	OUTPUT_MODE_TABLE,
+	OUTPUT_MODE_CSV,
+	OUTPUT_MODE_TSV,
+	OUTPUT_MODE_JSON,
+	OUTPUT_MODE_NDJSON,
+	OUTPUT_MODE_MARKDOWN,
+	OUTPUT_MODE_INSERT,
+}
+
+func isOutputMode(mode string) bool {
+	for _, m := range OUTPUT_MODES {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// resultSet is the result of a select ready to be printed, types holds the
+// schema type of every column.
+type resultSet struct {
+	table   string
+	columns []string
+	types   []string
+	rows    [][]interface{}
+}
+
+func newResultSet(table string, schema map[string]string, result *statement.Result) (*resultSet, error) {
+	rs := &resultSet{
+		table:   table,
+		columns: result.Columns,
+		types:   make([]string, len(result.Columns)),
+	}
+	for i, column := range result.Columns {
+		rs.types[i] = schema[column]
+	}
+	for _, row := range result.Rows {
+		values := make([]interface{}, len(result.Columns))
+		for i, column := range result.Columns {
+			value, err := core.GetValueFromRow(row, column)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		rs.rows = append(rs.rows, values)
+	}
+	return rs, nil
+}
+
+// isNumber reports whether column i holds numbers, they are not quoted and
+// aligned to the right.
+func (rs *resultSet) isNumber(i int) bool {
+	return rs.types[i] == "uint32"
+}
+
+func textValues(values []interface{}) []string {
+	text := make([]string, len(values))
+	for i, value := range values {
+		text[i] = fmt.Sprint(value)
+	}
+	return text
+}
+
+// writeResultSet prints the result in mode, headers prints the column names
+// in the modes where they are optional.
+func writeResultSet(w io.Writer, mode string, headers bool, rs *resultSet) {
+	switch mode {
+	case OUTPUT_MODE_LIST:
+		writeSeparated(w, headers, rs, "|", "", "")
+	case OUTPUT_MODE_LINE:
+		writeLines(w, rs)
+	case OUTPUT_MODE_TABLE:
+		writeTable(w, rs)
+	case OUTPUT_MODE_CSV:
+		writeCSV(w, headers, rs, ',')
+	case OUTPUT_MODE_TSV:
+		writeCSV(w, headers, rs, '\t')
+	case OUTPUT_MODE_JSON:
+		writeJSON(w, rs)
+	case OUTPUT_MODE_NDJSON:
+		for _, values := range rs.rows {
+			fmt.Fprintln(w, jsonObject(rs.columns, values))
+		}
+	case OUTPUT_MODE_MARKDOWN:
+		writeMarkdown(w, rs)
+	case OUTPUT_MODE_INSERT:
+		writeInserts(w, headers, rs)
+	default:
+		writeSeparated(w, headers, rs, ", ", "(", ")")
+	}
+}
+
+func writeSeparated(w io.Writer, headers bool, rs *resultSet, separator string, prefix string, suffix string) {
+	if headers {
+		fmt.Fprintln(w, prefix+strings.Join(rs.columns, separator)+suffix)
+	}
+	for _, values := range rs.rows {
+		fmt.Fprintln(w, prefix+strings.Join(textValues(values), separator)+suffix)
+	}
+}
+
+// writeLines prints every column of a row on its own line, rows are
+// separated by an empty line.
+func writeLines(w io.Writer, rs *resultSet) {
+	width := 0
+	for _, column := range rs.columns {
+		if utf8.RuneCountInString(column) > width {
+			width = utf8.RuneCountInString(column)
+		}
+	}
+	for _, values := range rs.rows {
+		for i, value := range textValues(values) {
+			fmt.Fprintf(w, "%s = %s\n", pad(rs.columns[i], width, true), value)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// columnWidths returns the width of every column, wide enough for its name
+// and its values.
+func columnWidths(columns []string, rows [][]string) []int {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for _, values := range rows {
+		for i, value := range values {
+			if utf8.RuneCountInString(value) > widths[i] {
+				widths[i] = utf8.RuneCountInString(value)
+			}
+		}
+	}
+	return widths
+}
+
+func pad(text string, width int, right bool) string {
+	padding := strings.Repeat(" ", width-utf8.RuneCountInString(text))
+	if right {
+		return padding + text
+	}
+	return text + padding
+}
+
+// formatRow pads the values to the column widths between bars, numbers are
+// aligned to the right unless alignNumbers is false.
+func (rs *resultSet) formatRow(values []string, widths []int, alignNumbers bool) string {
+	line := "|"
+	for i, value := range values {
+		line += " " + pad(value, widths[i], alignNumbers && rs.isNumber(i)) + " |"
+	}
+	return line
+}
+
+// writeTable prints the rows in a box with the column names on top.
+func writeTable(w io.Writer, rs *resultSet) {
+	rows := make([][]string, len(rs.rows))
+	for i, values := range rs.rows {
+		rows[i] = textValues(values)
+	}
+	widths := columnWidths(rs.columns, rows)
+
+	border := "+"
+	for _, width := range widths {
+		border += strings.Repeat("-", width+2) + "+"
+	}
+	fmt.Fprintln(w, border)
+	fmt.Fprintln(w, rs.formatRow(rs.columns, widths, false))
+	fmt.Fprintln(w, border)
+	for _, values := range rows {
+		fmt.Fprintln(w, rs.formatRow(values, widths, true))
+	}
+	fmt.Fprintln(w, border)
+}
+
+// writeMarkdown prints the rows as a GitHub flavored markdown table.
+func writeMarkdown(w io.Writer, rs *resultSet) {
+	rows := make([][]string, len(rs.rows))
+	for i, values := range rs.rows {
+		rows[i] = textValues(values)
+		for j, value := range rows[i] {
+			rows[i][j] = strings.Replace(value, "|", "\\|", -1)
+		}
+	}
+	widths := columnWidths(rs.columns, rows)
+
+	fmt.Fprintln(w, rs.formatRow(rs.columns, widths, false))
+	line := "|"
+	for i, width := range widths {
+		if rs.isNumber(i) {
+			line += strings.Repeat("-", width+1) + ":|"
+		} else {
+			line += strings.Repeat("-", width+2) + "|"
+		}
+	}
+	fmt.Fprintln(w, line)
+	for _, values := range rows {
+		fmt.Fprintln(w, rs.formatRow(values, widths, true))
+	}
+}
+
+// writeCSV prints the rows as RFC 4180 records, values containing the comma,
+// quotes or line breaks are quoted.
+func writeCSV(w io.Writer, headers bool, rs *resultSet, comma rune) {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+	if headers {
+		writer.Write(rs.columns)
+	}
+	for _, values := range rs.rows {
+		writer.Write(textValues(values))
+	}
+	writer.Flush()
+}
+
+// writeJSON prints the rows as an array of objects, one object per line.
+func writeJSON(w io.Writer, rs *resultSet) {
+	if len(rs.rows) == 0 {
+		fmt.Fprintln(w, "[]")
+		return
+	}
+	for i, values := range rs.rows {
+		line := jsonObject(rs.columns, values)
+		if i == 0 {
+			line = "[" + line
+		}
+		if i == len(rs.rows)-1 {
+			line += "]"
+		} else {
+			line += ","
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// jsonObject returns the row as a JSON object with the keys in column
+// order, numbers stay numbers.
+func jsonObject(columns []string, values []interface{}) string {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = jsonValue(columns[i]) + ":" + jsonValue(value)
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+func jsonValue(value interface{}) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writeInserts prints an INSERT statement for every row, headers adds the
+// column names to them.
+func writeInserts(w io.Writer, headers bool, rs *resultSet) {
+	into := rs.table
+	if headers {
+		into += " (" + strings.Join(rs.columns, ", ") + ")"
+	}
+	for _, values := range rs.rows {
+		literals := make([]string, len(values))
+		for i, value := range values {
+			literals[i] = sqlLiteral(value)
+		}
+		fmt.Fprintf(w, "INSERT INTO %s VALUES (%s);\n", into, strings.Join(literals, ", "))
+	}
+}
+
+// sqlLiteral quotes strings the way INSERT INTO reads them.
+func sqlLiteral(value interface{}) string {
+	text, ok := value.(string)
+	if ok == false {
+		return fmt.Sprint(value)
+	}
+	return statement.QuoteString(text)
+}
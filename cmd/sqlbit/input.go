@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// errInterrupted is returned by input when the line is aborted by Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// input reads the lines typed into the shell, it returns io.EOF at the end
+// of input.
+type input interface {
+	readLine(prompt string) (string, error)
+	appendHistory(entry string)
+}
+
+// readerInput reads from a file or a pipe, it has no history.
+type readerInput struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func newReaderInput(r io.Reader, out io.Writer) *readerInput {
+	return &readerInput{reader: bufio.NewReader(r), out: out}
+}
+
+func (in *readerInput) readLine(prompt string) (string, error) {
+	fmt.Fprint(in.out, prompt)
+	text, ok := readLine(in.reader)
+	if ok == false {
+		return "", io.EOF
+	}
+	return text, nil
+}
+
+func (in *readerInput) appendHistory(entry string) {
+}
+
+// HISTORY_FILE_NAME is the file in the home directory keeping the history of
+// the interactive shell.
+const HISTORY_FILE_NAME = ".sqlbit_history"
+
+// terminalInput reads from a terminal with line editing, history and tab
+// completion.
+type terminalInput struct {
+	state       *liner.State
+	historyFile string
+}
+
+func newTerminalInput(completer liner.WordCompleter) *terminalInput {
+	state := liner.NewLiner()
+	state.SetCtrlCAborts(true)
+	state.SetTabCompletionStyle(liner.TabPrints)
+	state.SetWordCompleter(completer)
+
+	in := &terminalInput{state: state}
+	home, err := os.UserHomeDir()
+	if err == nil {
+		in.historyFile = filepath.Join(home, HISTORY_FILE_NAME)
+		f, err := os.Open(in.historyFile)
+		if err == nil {
+			state.ReadHistory(f)
+			f.Close()
+		}
+	}
+	return in
+}
+
+func (in *terminalInput) readLine(prompt string) (string, error) {
+	text, err := in.state.Prompt(prompt)
+	if err == liner.ErrPromptAborted {
+		return "", errInterrupted
+	}
+	return text, err
+}
+
+// appendHistory records a meta-command or a whole statement, the lines of a
+// statement are joined so it can be recalled at once.
+func (in *terminalInput) appendHistory(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry != "" {
+		in.state.AppendHistory(entry)
+	}
+}
+
+// close saves the history and restores the terminal.
+func (in *terminalInput) close() error {
+	if in.historyFile != "" {
+		f, err := os.OpenFile(in.historyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err == nil {
+			in.state.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return in.state.Close()
+}
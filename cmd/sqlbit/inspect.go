@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+const INSPECT_USAGE = `Usage: sqlbit inspect [flags] FILE [PAGE...]
+
+Decodes the pages of the database FILE by their type, every page when none
+is given. With -dot the b-tree is printed as a Graphviz graph instead, e.g.
+sqlbit inspect -dot test.db | dot -Tsvg > btree.svg
+
+Flags:
+`
+
+// runInspect prints the pages of a database file, which is opened read only.
+// It returns the exit code.
+func runInspect(args []string, out io.Writer, errOut io.Writer) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), INSPECT_USAGE)
+		flags.PrintDefaults()
+	}
+	dot := flags.Bool("dot", false, "print the b-tree as a Graphviz graph")
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	} else if err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() == 0 || (*dot && flags.NArg() > 1) {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	pageIDs := []uint32{}
+	for _, arg := range flags.Args()[1:] {
+		pageID, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			fmt.Fprintf(errOut, "invalid page number %s\n", arg)
+			return EXIT_USAGE
+		}
+		pageIDs = append(pageIDs, uint32(pageID))
+	}
+
+	table, err := core.OpenTableWithOptions(flags.Arg(0), &core.TableOptions{ReadOnly: true})
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return EXIT_FAILURE
+	}
+	defer table.CloseTable()
+
+	if *dot {
+		graph, err := table.BTreeDOT()
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return EXIT_FAILURE
+		}
+		fmt.Fprint(out, graph)
+		return EXIT_OK
+	}
+	if len(pageIDs) == 0 {
+		stats, err := table.Stats()
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			return EXIT_FAILURE
+		}
+		for pageID := uint32(0); pageID < stats.PageCount; pageID++ {
+			pageIDs = append(pageIDs, pageID)
+		}
+	}
+
+	// a page which can not be decoded is reported and the others are still
+	// printed
+	code := EXIT_OK
+	for _, pageID := range pageIDs {
+		info, err := table.InspectPage(pageID)
+		if err != nil {
+			fmt.Fprintln(errOut, err)
+			code = EXIT_FAILURE
+			continue
+		}
+		fmt.Fprint(out, info)
+	}
+	return code
+}
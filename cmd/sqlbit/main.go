@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/remote"
+)
+
+func printPrompt() {
+	fmt.Print("> ")
+}
+
+// readLine returns the next line without its line break, ok is false at the
+// end of input.
+func readLine(reader *bufio.Reader) (text string, ok bool) {
+	text, err := reader.ReadString('\n')
+	if err != nil && text == "" {
+		return "", false
+	}
+	return strings.TrimRight(text, "\r\n"), true
+}
+
+// Exit codes of sqlbit, EXIT_FAILURE means the database could not be opened
+// or a statement failed.
+const EXIT_OK = 0
+const EXIT_FAILURE = 1
+const EXIT_USAGE = 2
+
+const USAGE = `Usage: sqlbit [flags] [FILE]
+       sqlbit serve [flags]
+       sqlbit client [flags]
+       sqlbit inspect [flags] FILE [PAGE...]
+
+Opens the database FILE, tmp/test.db by default, and runs the statements
+given by -c, -f or piped into stdin, stopping at the first error. Without
+them and with stdin being a terminal an interactive shell is started.
+Statements end with a semicolon, except the last one of the input. The FILE
+:memory: is a database in memory, .backup saves it into a file.
+
+Flags:
+`
+
+func defaultDBFileName() string {
+	dir, _ := os.Getwd()
+	return filepath.Join(dir, "tmp", "test.db")
+}
+
+// prepareDBFileName creates the directory of the default database file.
+func prepareDBFileName(fileName string) error {
+	if fileName != defaultDBFileName() {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(fileName), 0755)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runShell runs the statements given on the command line or stdin, it
+// returns the exit code.
+func runShell(args []string) int {
+	flags := flag.NewFlagSet("sqlbit", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), USAGE)
+		flags.PrintDefaults()
+	}
+	command := flags.String("c", "", "run the statements and exit")
+	script := flags.String("f", "", "run the statements of the file and exit")
+	readOnly := flags.Bool("readonly", false, "open the database read only")
+	bufferPoolSize := flags.Int("buffer-pool", core.DEFAULT_BUFFER_POOL_SIZE, "number of pages kept in memory, a transaction can not touch more pages")
+	pageSize := flags.Int("page-size", core.DEFAULT_PAGE_SIZE, "page size of a new database file")
+	maxSize := flags.Int64("max-size", 0, "maximum size of the database file in bytes, 0 means no limit")
+	mode := flags.String("mode", OUTPUT_MODE_TUPLE, "output mode, one of "+strings.Join(OUTPUT_MODES, ", "))
+	headers := flags.Bool("header", false, "print the column names before the rows")
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return EXIT_OK
+	} else if err != nil {
+		return EXIT_USAGE
+	}
+	if flags.NArg() > 1 || (*command != "" && *script != "") {
+		flags.Usage()
+		return EXIT_USAGE
+	}
+	if isOutputMode(*mode) == false {
+		fmt.Fprintf(flags.Output(), "unknown output mode %s\n", *mode)
+		return EXIT_USAGE
+	}
+
+	fileName := defaultDBFileName()
+	if flags.NArg() == 1 {
+		fileName = flags.Arg(0)
+	}
+	err = prepareDBFileName(fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	sh, err := newShell(fileName, &core.TableOptions{
+		PageSize:       *pageSize,
+		MaxSize:        *maxSize,
+		BufferPoolSize: *bufferPoolSize,
+		ReadOnly:       *readOnly,
+	}, os.Stdout, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+
+	sh.mode = *mode
+	sh.headers = *headers
+	interactive := false
+	sh.bail = true
+	if *command != "" {
+		sh.run(newReaderInput(strings.NewReader(*command), os.Stdout), "", "")
+	} else if *script != "" {
+		sh.read(*script)
+	} else if isTerminal(os.Stdin) == false {
+		sh.run(newReaderInput(os.Stdin, os.Stdout), "", "")
+	} else {
+		interactive = true
+		sh.bail = false
+		fmt.Print("Welcome to sqlbit 0.0.1\n")
+		fmt.Print("Statements end with a semicolon, type .help for the meta-commands\n")
+		in := newTerminalInput(sh.complete)
+		sh.run(in, PROMPT, CONTINUATION_PROMPT)
+		in.close()
+		fmt.Println("bye")
+	}
+
+	err = sh.close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return EXIT_FAILURE
+	}
+	if sh.failed && interactive == false {
+		return EXIT_FAILURE
+	}
+	return EXIT_OK
+}
+
+// runServe serves the database file to Postgres clients until interrupted.
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	address := flags.String("addr", remote.DEFAULT_ADDRESS, "address to listen on")
+	fileName := flags.String("db", defaultDBFileName(), "database file")
+	idleTimeout := flags.Duration("idle-in-transaction-timeout", remote.DEFAULT_IDLE_IN_TRANSACTION_TIMEOUT, "close a session idle inside a transaction for longer, 0 disables it")
+	flags.Parse(args)
+
+	err := prepareDBFileName(*fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(EXIT_FAILURE)
+	}
+	table, err := core.OpenTable(*fileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(EXIT_FAILURE)
+	}
+	server := remote.NewServer(table)
+	server.IdleInTransactionTimeout = *idleTimeout
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		server.Close()
+	}()
+
+	fmt.Printf("sqlbit is serving %s on %s\n", *fileName, *address)
+	err = server.ListenAndServe(*address)
+	if err != remote.ErrServerClosed {
+		fmt.Fprintln(os.Stderr, err)
+		table.CloseTable()
+		os.Exit(EXIT_FAILURE)
+	}
+	err = table.CloseTable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(EXIT_FAILURE)
+	}
+}
+
+// runClient sends queries read from stdin to a sqlbit server.
+func runClient(args []string) {
+	flags := flag.NewFlagSet("client", flag.ExitOnError)
+	address := flags.String("addr", remote.DEFAULT_ADDRESS, "address of the server")
+	flags.Parse(args)
+
+	client, err := remote.Dial(*address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(EXIT_FAILURE)
+	}
+	defer client.Close()
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("Connected to sqlbit on %s\n", *address)
+	for true {
+		printPrompt()
+		text, ok := readLine(reader)
+		if ok == false || text == ".exit" {
+			fmt.Println("bye")
+			return
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		response, err := client.Execute(text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if _, ok := err.(remote.ErrStatement); ok == false {
+				os.Exit(EXIT_FAILURE)
+			}
+			continue
+		}
+		for _, row := range response.Rows {
+			fmt.Println(formatRemoteRow(row))
+		}
+	}
+}
+
+// formatRemoteRow formats the values of a row received from a server.
+func formatRemoteRow(row []*string) string {
+	values := make([]string, len(row))
+	for i, value := range row {
+		if value == nil {
+			values[i] = "NULL"
+		} else {
+			values[i] = *value
+		}
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		runServe(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		runClient(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		os.Exit(runInspect(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	os.Exit(runShell(os.Args[1:]))
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+const META_COMMAND_HELP = `.backup FILE       Copy the database into FILE while it is in use
+.btree             Print the b-tree of the table
+.check             Check the integrity of the database file
+.constants         Print the layout of rows and pages
+.dump [TABLE]      Print the statements recreating the database, which are
+                   replayed into a new database file by .read or stdin
+.exit              Exit the shell
+.headers on|off    Print the column names before the rows
+.help              Print this message
+.import FILE TABLE Import the rows of a CSV or NDJSON file, --csv or --ndjson
+                   before FILE overrides the format told by its extension
+.indexes           List the indexes
+.mode [MODE]       Print rows as tuple, list, line, table, csv, tsv, json,
+                   ndjson, markdown or insert statements
+.open FILE         Close the database and open FILE, :memory: opens a new
+                   database in memory
+.read FILE         Run the statements and commands of FILE
+.schema [TABLE]    Print the schema of the tables
+.stats             Print the database and buffer pool statistics
+.tables            List the tables
+.timer on|off      Print the run time of every statement`
+
+func isMetaCommand(text string) bool {
+	return strings.HasPrefix(text, ".")
+}
+
+// runMetaCommand runs a command starting with a dot, it returns true for
+// .exit.
+func (s *shell) runMetaCommand(text string) bool {
+	args := strings.Fields(text)
+	command := args[0]
+	args = args[1:]
+
+	switch command {
+	case ".exit":
+		return true
+	case ".help":
+		fmt.Fprintln(s.out, META_COMMAND_HELP)
+	case ".backup":
+		if s.checkNoTransaction(command) {
+			s.backup(args)
+		}
+	case ".check":
+		if s.checkNoTransaction(command) {
+			s.runCheck()
+		}
+	case ".tables":
+		fmt.Fprintln(s.out, s.table.Name())
+	case ".schema":
+		s.printSchema(args)
+	case ".dump":
+		if s.checkNoTransaction(command) {
+			s.dump(args)
+		}
+	case ".import":
+		if s.checkNoTransaction(command) {
+			s.importFile(args)
+		}
+	case ".indexes":
+		if s.checkNoTransaction(command) {
+			s.printIndexes()
+		}
+	case ".btree":
+		if s.checkNoTransaction(command) {
+			s.printBTree()
+		}
+	case ".constants":
+		for _, constant := range core.Constants(s.table.PageSize()) {
+			fmt.Fprintf(s.out, "%s: %d\n", constant.Name, constant.Value)
+		}
+	case ".stats":
+		if s.checkNoTransaction(command) {
+			s.printStats()
+		}
+	case ".timer":
+		s.setSwitch(command, args, &s.timer)
+	case ".headers":
+		s.setSwitch(command, args, &s.headers)
+	case ".mode":
+		s.setMode(args)
+	case ".read":
+		if len(args) != 1 {
+			s.fail("Usage: .read FILE")
+			return false
+		}
+		return s.read(args[0])
+	case ".open":
+		if len(args) != 1 {
+			s.fail("Usage: .open FILE")
+			return false
+		}
+		s.open(args[0])
+	default:
+		s.fail("Unrecognized command %s", command)
+	}
+	return false
+}
+
+// checkNoTransaction reports whether the command can run, commands reading
+// the whole table would wait forever for the transaction of the shell.
+func (s *shell) checkNoTransaction(command string) bool {
+	if s.session.InTransaction() {
+		s.fail("can not run %s inside a transaction", command)
+		return false
+	}
+	return true
+}
+
+// backup copies the database into a file, which holds the rows committed
+// so far even though they might not be flushed yet.
+func (s *shell) backup(args []string) {
+	if len(args) != 1 {
+		s.fail("Usage: .backup FILE")
+		return
+	}
+	err := s.table.Backup(args[0])
+	if err != nil {
+		s.fail("%s", err)
+	}
+}
+
+// runCheck prints every integrity problem of the table, or ok when there is
+// none. Problems fail the shell so a script can check a database file.
+func (s *shell) runCheck() {
+	problems, err := s.table.CheckIntegrity()
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	for _, problem := range problems {
+		s.failed = true
+		fmt.Fprintln(s.out, problem)
+	}
+	if len(problems) == 0 {
+		fmt.Fprintln(s.out, "ok")
+	}
+}
+
+func (s *shell) printSchema(args []string) {
+	if len(args) > 0 && args[0] != s.table.Name() {
+		return
+	}
+	fmt.Fprintf(s.out, "%s;\n", statement.CreateTableStatement(s.table))
+}
+
+// dump prints the statements recreating the table, an index is only
+// created for the primary key which CREATE TABLE declares.
+func (s *shell) dump(args []string) {
+	if len(args) > 1 {
+		s.fail("Usage: .dump [TABLE]")
+		return
+	}
+	if len(args) == 1 && args[0] != s.table.Name() {
+		s.fail("no such table: %s", args[0])
+		return
+	}
+	_, err := statement.Dump(s.table, s.out)
+	if err != nil {
+		s.fail("%s", err)
+	}
+}
+
+// printIndexes lists the primary key, which is the only index a table has.
+func (s *shell) printIndexes() {
+	stats, err := s.table.Stats()
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	fmt.Fprintf(s.out, "%s_pkey ON %s (id), root page %d\n", s.table.Name(), s.table.Name(), stats.RootPageNum)
+}
+
+func (s *shell) printBTree() {
+	tree, err := s.table.BTreeString()
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	fmt.Fprint(s.out, tree)
+}
+
+func (s *shell) printStats() {
+	stats, err := s.table.Stats()
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	fmt.Fprintf(s.out, "database: %s\n", s.fileName)
+	fmt.Fprintf(s.out, "rows: %d\n", stats.NumRows)
+	fmt.Fprintf(s.out, "page size: %d\n", stats.PageSize)
+	fmt.Fprintf(s.out, "pages: %d\n", stats.PageCount)
+	fmt.Fprintf(s.out, "change counter: %d\n", stats.ChangeCounter)
+	fmt.Fprintf(s.out, "buffer pool frames: %d/%d\n", stats.BufferPool.Frames, stats.BufferPool.MaxFrames)
+	fmt.Fprintf(s.out, "pinned pages: %d\n", stats.BufferPool.PinnedPages)
+	fmt.Fprintf(s.out, "cache hits: %d\n", stats.BufferPool.Hits)
+	fmt.Fprintf(s.out, "cache misses: %d\n", stats.BufferPool.Misses)
+}
+
+// setSwitch turns an on|off setting of the shell on or off.
+func (s *shell) setSwitch(command string, args []string, value *bool) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		s.fail("Usage: %s on|off", command)
+		return
+	}
+	*value = args[0] == "on"
+}
+
+func (s *shell) setMode(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(s.out, "current output mode: %s\n", s.mode)
+		return
+	}
+	if isOutputMode(args[0]) == false {
+		s.fail("Unknown mode %s, use one of %s", args[0], strings.Join(OUTPUT_MODES, ", "))
+		return
+	}
+	s.mode = args[0]
+}
+
+// importFile imports the rows of a file into the table, rejected rows are
+// reported with their line numbers and fail the shell.
+func (s *shell) importFile(args []string) {
+	format := ""
+	if len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		format = strings.TrimPrefix(args[0], "--")
+		args = args[1:]
+	}
+	if len(args) != 2 {
+		s.fail("Usage: .import [--csv|--ndjson] FILE TABLE")
+		return
+	}
+	fileName := args[0]
+	if args[1] != s.table.Name() {
+		s.fail("no such table: %s", args[1])
+		return
+	}
+	var err error
+	if format == "" {
+		format, err = statement.ImportFormat(fileName)
+		if err != nil {
+			s.fail("%s", err)
+			return
+		}
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	defer f.Close()
+
+	result, err := statement.Import(s.table, f, format)
+	if result != nil {
+		for _, rejected := range result.Rejected {
+			s.fail("%s:%d: %s", fileName, rejected.Line, rejected.Err)
+		}
+		fmt.Fprintf(s.out, "imported %d rows, rejected %d\n", result.Imported, len(result.Rejected))
+	}
+	if err != nil {
+		s.fail("%s", err)
+	}
+}
+
+// read runs the file as if it was typed into the shell, it returns true when
+// the shell should stop.
+func (s *shell) read(fileName string) bool {
+	f, err := os.Open(fileName)
+	if err != nil {
+		s.fail("%s", err)
+		return false
+	}
+	defer f.Close()
+
+	return s.run(newReaderInput(f, s.out), "", "")
+}
+
+// open switches the shell to another database file, the current one is
+// reopened when fileName can not be opened.
+func (s *shell) open(fileName string) {
+	err := s.close()
+	if err != nil {
+		s.fail("%s", err)
+	}
+
+	table, err := core.OpenTableWithOptions(fileName, s.options)
+	if err != nil {
+		s.fail("%s", err)
+		fileName = s.fileName
+		table, err = core.OpenTableWithOptions(fileName, s.options)
+		if err != nil {
+			s.fail("%s", err)
+			return
+		}
+	}
+	s.fileName = fileName
+	s.table = table
+	s.session = statement.NewSession(table)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// shell runs the statements and meta-commands typed into the REPL, rows go
+// to out and errors to errOut.
+type shell struct {
+	out      io.Writer
+	errOut   io.Writer
+	fileName string
+	options  *core.TableOptions
+	table    *core.Table
+	session  *statement.Session
+	// timer prints the run time of every statement
+	timer   bool
+	mode    string
+	headers bool
+	// bail stops the shell at the first error, failed records whether there
+	// was one
+	bail   bool
+	failed bool
+}
+
+func newShell(fileName string, options *core.TableOptions, out io.Writer, errOut io.Writer) (*shell, error) {
+	table, err := core.OpenTableWithOptions(fileName, options)
+	if err != nil {
+		return nil, err
+	}
+	return &shell{
+		out:      out,
+		errOut:   errOut,
+		fileName: fileName,
+		options:  options,
+		table:    table,
+		session:  statement.NewSession(table),
+		mode:     OUTPUT_MODE_TUPLE,
+	}, nil
+}
+
+// fail prints an error, the shell stops after it when bail is set.
+func (s *shell) fail(format string, args ...interface{}) {
+	s.failed = true
+	fmt.Fprintf(s.errOut, format+"\n", args...)
+}
+
+// close rolls back the open transaction and closes the database file.
+func (s *shell) close() error {
+	s.session.Close()
+	return s.table.CloseTable()
+}
+
+// The prompts of the interactive shell, CONTINUATION_PROMPT is printed while
+// a statement is not terminated by a semicolon yet.
+const PROMPT = "sqlbit> "
+const CONTINUATION_PROMPT = "   ...> "
+
+// run reads meta-commands and statements terminated by semicolons until the
+// end of input, a statement can span several lines. It returns true when the
+// shell should stop, after .exit or an error with bail set.
+func (s *shell) run(in input, prompt string, continuationPrompt string) bool {
+	pending := ""
+	for true {
+		linePrompt := prompt
+		if pending != "" {
+			linePrompt = continuationPrompt
+		}
+		text, err := in.readLine(linePrompt)
+		if err == errInterrupted {
+			pending = ""
+			continue
+		}
+		if err != nil {
+			// the last statement of the input does not need a semicolon
+			return s.runStatements(pending)
+		}
+
+		// the lines of a statement are kept as they are, a quoted value
+		// might span them
+		if pending == "" {
+			trimmed := strings.TrimSpace(text)
+			if trimmed == "" {
+				continue
+			}
+			if isMetaCommand(trimmed) {
+				in.appendHistory(trimmed)
+				if s.runMetaCommand(trimmed) || (s.bail && s.failed) {
+					return true
+				}
+				continue
+			}
+			pending = strings.TrimLeft(text, " \t")
+		} else {
+			pending = pending + "\n" + text
+		}
+		if statement.IsComplete(pending) {
+			in.appendHistory(pending)
+			stop := s.runStatements(pending)
+			pending = ""
+			if stop {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runStatements executes the statements of text one by one, it returns true
+// when the shell should stop.
+func (s *shell) runStatements(text string) bool {
+	for _, st := range statement.Split(text) {
+		s.execute(st)
+		if s.bail && s.failed {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *shell) execute(text string) {
+	start := time.Now()
+	result, err := s.session.Execute(text)
+	if err != nil {
+		s.fail("%s", err)
+	} else {
+		s.printResult(result)
+	}
+	if s.timer {
+		fmt.Fprintf(s.out, "Run Time: real %.3fs\n", time.Since(start).Seconds())
+	}
+}
+
+// printResult prints the rows of a select in the current output mode.
+func (s *shell) printResult(result *statement.Result) {
+	if result.Columns == nil {
+		return
+	}
+	rs, err := newResultSet(s.table.Name(), s.table.Schema(), result)
+	if err != nil {
+		s.fail("%s", err)
+		return
+	}
+	writeResultSet(s.out, s.mode, s.headers, rs)
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestShell(t *testing.T) (*shell, *bytes.Buffer, string, func()) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	out := &bytes.Buffer{}
+	sh, err := newShell(filepath.Join(dir, "test.db"), core.DefaultTableOptions(), out, out)
+	assert.Nil(t, err)
+	return sh, out, dir, func() {
+		sh.close()
+		os.RemoveAll(dir)
+	}
+}
+
+// runLines feeds the lines to the shell and returns what it printed.
+func runLines(sh *shell, out *bytes.Buffer, lines ...string) string {
+	out.Reset()
+	sh.run(newReaderInput(strings.NewReader(strings.Join(lines, "\n")), out), "", "")
+	return out.String()
+}
+
+func TestMetaCommandHelpIsAligned(t *testing.T) {
+	// every description starts at the same column
+	for _, line := range strings.Split(META_COMMAND_HELP, "\n") {
+		assert.Equal(t, byte(' '), line[18], line)
+		assert.NotEqual(t, byte(' '), line[19], line)
+	}
+}
+
+func TestShellOutputModes(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+	runLines(sh, out, "insert 1 harry harry@hogwarts.edu;", "insert 2 ron ron@hogwarts.edu;")
+
+	assert.Equal(t, "(1, harry, harry@hogwarts.edu)\n(2, ron, ron@hogwarts.edu)\n", runLines(sh, out, "select * from users;"))
+	assert.Equal(t, "id|username|email\n2|ron|ron@hogwarts.edu\n", runLines(sh, out, ".headers on", ".mode list", "select * from users where id = 2;"))
+	assert.Equal(t, "      id = 1\nusername = harry\n   email = harry@hogwarts.edu\n\n", runLines(sh, out, ".mode line", "select * from users where id = 1;"))
+	assert.Equal(t, "Unknown mode xml, use one of tuple, list, line, table, csv, tsv, json, ndjson, markdown, insert\n", runLines(sh, out, ".mode xml"))
+	assert.Equal(t, "Usage: .headers on|off\n", runLines(sh, out, ".headers yes"))
+}
+
+func TestWriteResultSet(t *testing.T) {
+	rs := &resultSet{
+		table:   "users",
+		columns: []string{"id", "username", "email"},
+		types:   []string{"uint32", "string", "string"},
+		rows: [][]interface{}{
+			{uint32(1), "harry", "harry@hogwarts.edu"},
+			{uint32(12), "o'neil, \"jack\"", "a|b<c>"},
+		},
+	}
+	write := func(mode string, headers bool) string {
+		out := &bytes.Buffer{}
+		writeResultSet(out, mode, headers, rs)
+		return out.String()
+	}
+
+	assert.Equal(t, "+----+----------------+--------------------+\n"+
+		"| id | username       | email              |\n"+
+		"+----+----------------+--------------------+\n"+
+		"|  1 | harry          | harry@hogwarts.edu |\n"+
+		"| 12 | o'neil, \"jack\" | a|b<c>             |\n"+
+		"+----+----------------+--------------------+\n", write(OUTPUT_MODE_TABLE, false))
+	assert.Equal(t, "id,username,email\n1,harry,harry@hogwarts.edu\n12,\"o'neil, \"\"jack\"\"\",a|b<c>\n", write(OUTPUT_MODE_CSV, true))
+	assert.Equal(t, "1\tharry\tharry@hogwarts.edu\n12\t\"o'neil, \"\"jack\"\"\"\ta|b<c>\n", write(OUTPUT_MODE_TSV, false))
+	assert.Equal(t, "[{\"id\":1,\"username\":\"harry\",\"email\":\"harry@hogwarts.edu\"},\n"+
+		"{\"id\":12,\"username\":\"o'neil, \\\"jack\\\"\",\"email\":\"a|b<c>\"}]\n", write(OUTPUT_MODE_JSON, true))
+	assert.Equal(t, "{\"id\":1,\"username\":\"harry\",\"email\":\"harry@hogwarts.edu\"}\n"+
+		"{\"id\":12,\"username\":\"o'neil, \\\"jack\\\"\",\"email\":\"a|b<c>\"}\n", write(OUTPUT_MODE_NDJSON, false))
+	assert.Equal(t, "| id | username       | email              |\n"+
+		"|---:|----------------|--------------------|\n"+
+		"|  1 | harry          | harry@hogwarts.edu |\n"+
+		"| 12 | o'neil, \"jack\" | a\\|b<c>            |\n", write(OUTPUT_MODE_MARKDOWN, false))
+	assert.Equal(t, "INSERT INTO users VALUES (1, 'harry', 'harry@hogwarts.edu');\n"+
+		"INSERT INTO users VALUES (12, 'o''neil, \"jack\"', 'a|b<c>');\n", write(OUTPUT_MODE_INSERT, false))
+	assert.Equal(t, "INSERT INTO users (id, username, email) VALUES (1, 'harry', 'harry@hogwarts.edu');\n", strings.Split(write(OUTPUT_MODE_INSERT, true), "\n")[0]+"\n")
+
+	rs.rows = nil
+	assert.Equal(t, "[]\n", write(OUTPUT_MODE_JSON, false))
+	assert.Equal(t, "", write(OUTPUT_MODE_NDJSON, false))
+}
+
+func TestShellDescribesTable(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+	runLines(sh, out, "insert 1 harry harry@hogwarts.edu;")
+
+	assert.Equal(t, "users\n", runLines(sh, out, ".tables"))
+	assert.Equal(t, "CREATE TABLE users (id uint32 PRIMARY KEY, username string, email string);\n", runLines(sh, out, ".schema users"))
+	assert.Equal(t, "", runLines(sh, out, ".schema books"))
+	assert.Equal(t, "users_pkey ON users (id), root page 1\n", runLines(sh, out, ".indexes"))
+	assert.Equal(t, "LeafNode (1 )\n", runLines(sh, out, ".btree"))
+	assert.Contains(t, runLines(sh, out, ".constants"), "LEAF_NODE_MAX_CELLS: 13\n")
+	assert.Contains(t, runLines(sh, out, ".stats"), "rows: 1\n")
+	assert.Equal(t, "can not run .btree inside a transaction\n", runLines(sh, out, "begin;", ".btree", "rollback;"))
+	assert.Equal(t, "Unrecognized command .foo\n", runLines(sh, out, ".foo"))
+}
+
+func TestShellReadAndOpen(t *testing.T) {
+	sh, out, dir, cleanup := openTestShell(t)
+	defer cleanup()
+	script := filepath.Join(dir, "script.sql")
+	ioutil.WriteFile(script, []byte("insert 1 harry harry@hogwarts.edu;\n.mode list\nselect * from users\n"), 0644)
+
+	assert.Equal(t, "1|harry|harry@hogwarts.edu\n", runLines(sh, out, ".read "+script))
+
+	other := filepath.Join(dir, "other.db")
+	assert.Equal(t, "", runLines(sh, out, ".open "+other, "select * from users;"))
+	assert.Equal(t, other, sh.fileName)
+	assert.Equal(t, "1|harry|harry@hogwarts.edu\n", runLines(sh, out, ".open "+filepath.Join(dir, "test.db"), "select * from users;"))
+}
+
+func TestShellImport(t *testing.T) {
+	sh, out, dir, cleanup := openTestShell(t)
+	defer cleanup()
+	csvFile := filepath.Join(dir, "users.csv")
+	ioutil.WriteFile(csvFile, []byte("id,username,email\n1,harry,harry@hogwarts.edu\n2,ron\n"), 0644)
+	jsonFile := filepath.Join(dir, "users.json")
+	ioutil.WriteFile(jsonFile, []byte(`{"id": 3, "username": "hermione", "email": "hermione@hogwarts.edu"}`), 0644)
+
+	assert.Equal(t, csvFile+":3: expected 3 values, got 2\nimported 1 rows, rejected 1\n", runLines(sh, out, ".import "+csvFile+" users"))
+	assert.True(t, sh.failed)
+	assert.Equal(t, "imported 1 rows, rejected 0\n", runLines(sh, out, ".import --ndjson "+jsonFile+" users"))
+	assert.Equal(t, 2, sh.table.NumRows())
+	assert.Equal(t, "no such table: books\n", runLines(sh, out, ".import "+csvFile+" books"))
+	assert.Contains(t, runLines(sh, out, ".import "+jsonFile+" users"), "can not tell the format")
+}
+
+func TestShellDump(t *testing.T) {
+	sh, out, dir, cleanup := openTestShell(t)
+	defer cleanup()
+	runLines(sh, out, "insert 1 harry harry@hogwarts.edu;", "INSERT INTO users VALUES (2, 'o''reilly', 'a;' || char(0, 10) || 'b');")
+
+	dump := runLines(sh, out, ".dump")
+
+	assert.Equal(t, "CREATE TABLE users (id uint32 PRIMARY KEY, username string, email string);\n"+
+		"INSERT INTO users VALUES\n(1, 'harry', 'harry@hogwarts.edu'),\n(2, 'o''reilly', 'a;' || char(0, 10) || 'b');\n", dump)
+	assert.Equal(t, "no such table: books\n", runLines(sh, out, ".dump books"))
+	rows := runLines(sh, out, ".mode insert", "select * from users;")
+	assert.Equal(t, "INSERT INTO users VALUES (2, 'o''reilly', 'a;' || char(0, 10) || 'b');\n", strings.SplitAfter(rows, "\n")[1])
+
+	runLines(sh, out, ".open "+filepath.Join(dir, "restored.db"))
+	assert.Equal(t, "", runLines(sh, out, strings.Split(dump, "\n")...))
+	assert.Equal(t, dump, runLines(sh, out, ".dump users"))
+	runLines(sh, out, ".open "+filepath.Join(dir, "inserts.db"))
+	assert.Equal(t, "", runLines(sh, out, rows))
+	assert.Equal(t, dump, runLines(sh, out, ".dump"))
+}
+
+func TestShellBackup(t *testing.T) {
+	sh, out, dir, cleanup := openTestShell(t)
+	defer cleanup()
+	backupFile := filepath.Join(dir, "backup.db")
+	runLines(sh, out, "insert 1 harry harry@hogwarts.edu;")
+
+	assert.Equal(t, "", runLines(sh, out, ".backup "+backupFile))
+	assert.Equal(t, "can not run .backup inside a transaction\n", runLines(sh, out, "begin;", ".backup "+backupFile, "rollback;"))
+	assert.Equal(t, "Usage: .backup FILE\n", runLines(sh, out, ".backup"))
+	assert.Equal(t, "(1, harry, harry@hogwarts.edu)\n", runLines(sh, out, ".open "+backupFile, "select * from users;"))
+}
+
+func TestShellMemoryDatabase(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	out := &bytes.Buffer{}
+	sh, err := newShell(core.MEMORY_FILE_NAME, core.DefaultTableOptions(), out, out)
+	assert.Nil(t, err)
+	defer sh.close()
+	snapshot := filepath.Join(dir, "snapshot.db")
+
+	runLines(sh, out, "insert 1 harry harry@hogwarts.edu;", "VACUUM;")
+	assert.Equal(t, "", runLines(sh, out, ".backup "+snapshot))
+	assert.Equal(t, "", runLines(sh, out, ".open :memory:", "select * from users;"))
+	assert.Equal(t, "(1, harry, harry@hogwarts.edu)\n", runLines(sh, out, ".open "+snapshot, "select * from users;"))
+	assert.Equal(t, "can not back up into an in-memory database\n", runLines(sh, out, ".backup :memory:"))
+	_, err = os.Stat(core.MEMORY_FILE_NAME)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestShellTimer(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+
+	assert.Contains(t, runLines(sh, out, ".timer on", "select * from users;"), "Run Time: real ")
+	assert.Equal(t, "", runLines(sh, out, ".timer off", "select * from users;"))
+}
+
+func TestShellBail(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+	sh.bail = true
+
+	output := runLines(sh, out, "insert 1 harry harry@hogwarts.edu;", "insert harry;", "insert 2 ron ron@hogwarts.edu;")
+
+	assert.Equal(t, "PREPARE_SYNTAX_ERROR\n", output)
+	assert.True(t, sh.failed)
+	assert.Equal(t, 1, sh.table.NumRows())
+}
+
+func TestRunShellExitCodes(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "test.db")
+
+	assert.Equal(t, EXIT_USAGE, runShell([]string{"-unknown"}))
+	assert.Equal(t, EXIT_USAGE, runShell([]string{fileName, "other.db"}))
+	assert.Equal(t, EXIT_USAGE, runShell([]string{"-mode", "xml", fileName}))
+	assert.Equal(t, EXIT_OK, runShell([]string{"-c", "insert 1 harry harry@hogwarts.edu", fileName}))
+	assert.Equal(t, EXIT_FAILURE, runShell([]string{"-c", "insert harry", fileName}))
+	assert.Equal(t, EXIT_FAILURE, runShell([]string{"-readonly", "-c", "insert 2 ron ron@hogwarts.edu", fileName}))
+	assert.Equal(t, EXIT_FAILURE, runShell([]string{"-readonly", filepath.Join(dir, "missing.db")}))
+	script := filepath.Join(dir, "script.sql")
+	ioutil.WriteFile(script, []byte("insert 2 ron ron@hogwarts.edu;\n.check\n"), 0644)
+	assert.Equal(t, EXIT_OK, runShell([]string{"-f", script, "-buffer-pool", "20", fileName}))
+}
+
+// scriptedInput returns its lines one by one, an empty string stands for
+// Ctrl-C, and records the prompts and the history.
+type scriptedInput struct {
+	lines   []string
+	prompts []string
+	history []string
+}
+
+func (in *scriptedInput) readLine(prompt string) (string, error) {
+	in.prompts = append(in.prompts, prompt)
+	if len(in.lines) == 0 {
+		return "", io.EOF
+	}
+	line := in.lines[0]
+	in.lines = in.lines[1:]
+	if line == "" {
+		return "", errInterrupted
+	}
+	return line, nil
+}
+
+func (in *scriptedInput) appendHistory(entry string) {
+	in.history = append(in.history, entry)
+}
+
+func TestShellMultiLineStatements(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+
+	assert.Equal(t, "(2, ron, ron@hogwarts.edu)\n", runLines(sh, out,
+		"insert 1 harry harry@hogwarts.edu; insert 2 ron ron@hogwarts.edu;",
+		"select *",
+		"  from users",
+		"  where id = 2;"))
+	assert.Equal(t, "(1, harry, harry@hogwarts.edu)\n", runLines(sh, out, "select * from users where email = 'harry@hogwarts.edu'"))
+
+	in := &scriptedInput{lines: []string{"select *", "", ".tables", "select * from users", "where id = 1;"}}
+	out.Reset()
+	sh.run(in, PROMPT, CONTINUATION_PROMPT)
+
+	assert.Equal(t, "users\n(1, harry, harry@hogwarts.edu)\n", out.String())
+	assert.Equal(t, []string{PROMPT, CONTINUATION_PROMPT, PROMPT, PROMPT, CONTINUATION_PROMPT, PROMPT}, in.prompts)
+	assert.Equal(t, []string{".tables", "select * from users\nwhere id = 1;"}, in.history)
+}
+
+func TestShellMultiLineQuotedValue(t *testing.T) {
+	sh, out, _, cleanup := openTestShell(t)
+	defer cleanup()
+
+	in := &scriptedInput{lines: []string{"insert into users values (1, 'a", "  b ', 'x');", "select * from users;"}}
+	sh.run(in, PROMPT, CONTINUATION_PROMPT)
+
+	assert.Equal(t, "(1, a\n  b , x)\n", out.String())
+	assert.Equal(t, []string{"insert into users values (1, 'a\n  b ', 'x');", "select * from users;"}, in.history)
+}
+
+func TestShellComplete(t *testing.T) {
+	sh, _, _, cleanup := openTestShell(t)
+	defer cleanup()
+
+	head, completions, tail := sh.complete("sel", 3)
+	assert.Equal(t, "", head)
+	assert.Equal(t, []string{"select"}, completions)
+	assert.Equal(t, "", tail)
+
+	_, completions, _ = sh.complete("SEL", 3)
+	assert.Equal(t, []string{"SELECT"}, completions)
+
+	head, completions, tail = sh.complete("select * from u where id = 1", 15)
+	assert.Equal(t, "select * from ", head)
+	assert.Equal(t, []string{"users", "username"}, completions)
+	assert.Equal(t, " where id = 1", tail)
+
+	_, completions, _ = sh.complete(".t", 2)
+	assert.Equal(t, []string{".tables", ".timer"}, completions)
+}
+
+func TestRunInspect(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "test.db")
+	runShell([]string{"-c", "insert 1 harry harry@hogwarts.edu", fileName})
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+
+	assert.Equal(t, EXIT_OK, runInspect([]string{fileName, "1"}, out, errOut))
+	assert.Equal(t, "page 1: leaf node\n  prev leaf: 0\n  next leaf: 0\n  tuples: 1\n    1: username \"harry\", email \"harry@hogwarts.edu\"\n", out.String())
+	out.Reset()
+	assert.Equal(t, EXIT_OK, runInspect([]string{fileName}, out, errOut))
+	assert.True(t, strings.HasPrefix(out.String(), "page 0: table header\n"))
+	assert.Contains(t, out.String(), "page 1: leaf node\n")
+	out.Reset()
+	assert.Equal(t, EXIT_OK, runInspect([]string{"-dot", fileName}, out, errOut))
+	assert.Equal(t, "digraph btree {\n  node [shape=record];\n  page1 [label=\"{leaf 1|1}\"];\n}\n", out.String())
+
+	assert.Equal(t, EXIT_FAILURE, runInspect([]string{fileName, "7"}, out, errOut))
+	assert.Equal(t, "page 7 is beyond the 2 pages of the database\n", errOut.String())
+	assert.Equal(t, EXIT_USAGE, runInspect([]string{fileName, "x"}, out, errOut))
+	assert.Equal(t, EXIT_USAGE, runInspect([]string{}, out, errOut))
+}
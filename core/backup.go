@@ -0,0 +1,164 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// BACKUP_FILE_SUFFIX names the file a backup is written into, it is renamed
+// to the backup file once the copy is complete.
+const BACKUP_FILE_SUFFIX = "-partial"
+
+// Backup copies BACKUP_PAGES_PER_STEP pages at once, and copies the rest in
+// one step after BACKUP_MAX_RESTARTS restarts so busy writers can not keep
+// it from finishing.
+const BACKUP_PAGES_PER_STEP = 64
+const BACKUP_MAX_RESTARTS = 3
+
+// Backup is an online copy of a database file. The pages are read through
+// the buffer pool, so the rows committed but not flushed yet are copied
+// too. Every step holds the table lock for reading, between the steps other
+// sessions read and write. The copy starts over when a commit bumped the
+// change counter since the previous step, so the finished backup is the
+// database as of a single commit.
+type Backup struct {
+	table    *Table
+	fileName string
+	file     *os.File
+	started  bool
+	done     bool
+	restarts int
+	// changeCounter and pageCount are read from the table header when the
+	// copy starts
+	changeCounter uint32
+	pageCount     uint32
+	nextPageID    uint32
+}
+
+// NewBackup starts a backup into fileName, an existing file is replaced once
+// the backup is finished. A backup of an in-memory database is a snapshot of
+// it in a file.
+func (t *Table) NewBackup(fileName string) (*Backup, error) {
+	if fileName == MEMORY_FILE_NAME {
+		return nil, errors.New("can not back up into an in-memory database")
+	}
+	if t.fileName != "" && t.fileName != MEMORY_FILE_NAME && sameFile(t.fileName, fileName) {
+		return nil, errors.New("can not back up a database into itself")
+	}
+	file, err := os.OpenFile(fileName+BACKUP_FILE_SUFFIX, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Backup{table: t, fileName: fileName, file: file}, nil
+}
+
+func sameFile(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// Backup copies the database into fileName, step by step.
+func (t *Table) Backup(fileName string) error {
+	b, err := t.NewBackup(fileName)
+	if err != nil {
+		return err
+	}
+	for true {
+		numPages := BACKUP_PAGES_PER_STEP
+		if b.Restarts() >= BACKUP_MAX_RESTARTS {
+			numPages = -1
+		}
+		done, err := b.Step(numPages)
+		if err != nil {
+			b.Finish()
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return b.Finish()
+}
+
+// Step copies up to numPages pages, a negative numPages copies all the
+// remaining pages. It returns true once every page is copied.
+func (b *Backup) Step(numPages int) (bool, error) {
+	if b.done {
+		return true, nil
+	}
+	t := b.table
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	header, err := readTableHeader(t.bufferPool)
+	if err != nil {
+		return false, err
+	}
+	if b.started == false || header.changeCounter != b.changeCounter {
+		if b.started {
+			b.restarts++
+		}
+		err = b.file.Truncate(0)
+		if err != nil {
+			return false, err
+		}
+		b.started = true
+		b.changeCounter = header.changeCounter
+		b.pageCount = header.pageCount
+		b.nextPageID = 0
+	}
+
+	end := b.pageCount
+	if numPages >= 0 && b.pageCount-b.nextPageID > uint32(numPages) {
+		end = b.nextPageID + uint32(numPages)
+	}
+	for ; b.nextPageID < end; b.nextPageID++ {
+		err = b.copyPage(b.nextPageID)
+		if err != nil {
+			return false, err
+		}
+	}
+	b.done = b.nextPageID == b.pageCount
+	return b.done, nil
+}
+
+// copyPage writes the page with its checksum into the backup file.
+func (b *Backup) copyPage(pageID uint32) error {
+	bufferPool := b.table.bufferPool
+	body, err := bufferPool.FetchPage(pageID)
+	if err != nil {
+		return err
+	}
+	page := newPageBody(len(body))
+	copy(page, body)
+	bufferPool.UnpinPage(pageID, false)
+
+	stampPageChecksum(pageID, page)
+	_, err = b.file.WriteAt(page, int64(pageID)*int64(len(page)))
+	return err
+}
+
+// Restarts returns the number of times the copy started over.
+func (b *Backup) Restarts() int {
+	return b.restarts
+}
+
+// Finish moves a complete backup into place, an incomplete one is removed.
+func (b *Backup) Finish() error {
+	partialFileName := b.file.Name()
+	err := b.file.Sync()
+	closeErr := b.file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && b.done == false {
+		err = errors.New("backup is not complete")
+	}
+	if err != nil {
+		os.Remove(partialFileName)
+		return err
+	}
+	return os.Rename(partialFileName, b.fileName)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestBackupFileName() string {
+	return getTestFileName() + ".backup"
+}
+
+// checkBackup opens the backup and checks it holds numRows intact rows.
+func checkBackup(t *testing.T, numRows int) []*Row {
+	backup, err := OpenTableWithOptions(getTestBackupFileName(), &TableOptions{ReadOnly: true})
+	assert.Nil(t, err)
+	defer backup.CloseTable()
+	problems, err := backup.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	rows := drainRowIterator(t, backup.Scan(nil, nil))
+	assert.Equal(t, numRows, len(rows))
+	assert.Equal(t, numRows, backup.NumRows())
+	return rows
+}
+
+func TestTableBackup(t *testing.T) {
+	removeTestFile()
+	defer os.Remove(getTestBackupFileName())
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 300; i++ {
+		email := "user@test.com"
+		if i%50 == 0 {
+			email = strings.Repeat("e", 2*DEFAULT_PAGE_SIZE)
+		}
+		table.InsertRow(NewRow(uint32(i), "user", email))
+	}
+
+	// the rows are only in the buffer pool until the table is closed
+	err := table.Backup(getTestBackupFileName())
+
+	assert.Nil(t, err)
+	rows := checkBackup(t, 300)
+	assert.Equal(t, drainRowIterator(t, table.Scan(nil, nil)), rows)
+	_, err = os.Stat(getTestBackupFileName() + BACKUP_FILE_SUFFIX)
+	assert.True(t, os.IsNotExist(err))
+	_, err = table.NewBackup(getTestFileName())
+	assert.Equal(t, "can not back up a database into itself", err.Error())
+}
+
+func TestBackupRestartsAfterCommit(t *testing.T) {
+	removeTestFile()
+	defer os.Remove(getTestBackupFileName())
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 100; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	b, _ := table.NewBackup(getTestBackupFileName())
+
+	done, err := b.Step(2)
+	assert.Nil(t, err)
+	assert.False(t, done)
+	table.InsertRow(NewRow(101, "user", "user@test.com"))
+	done, err = b.Step(-1)
+
+	assert.Nil(t, err)
+	assert.True(t, done)
+	assert.Equal(t, 1, b.Restarts())
+	assert.Nil(t, b.Finish())
+	checkBackup(t, 101)
+}
+
+func TestBackupIncompleteIsRemoved(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	b, _ := table.NewBackup(getTestBackupFileName())
+	b.Step(1)
+
+	err := b.Finish()
+
+	assert.Equal(t, "backup is not complete", err.Error())
+	_, err = os.Stat(getTestBackupFileName())
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(getTestBackupFileName() + BACKUP_FILE_SUFFIX)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	removeTestFile()
+	defer os.Remove(getTestBackupFileName())
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 500; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 501; i <= 700; i++ {
+			tx := table.Begin()
+			tx.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+			found, _ := tx.DeleteRow(uint32(i - 500))
+			assert.True(t, found)
+			tx.Commit()
+		}
+	}()
+	err := table.Backup(getTestBackupFileName())
+	wg.Wait()
+
+	// every commit inserts a row and deletes another one
+	assert.Nil(t, err)
+	rows := checkBackup(t, 500)
+	first := rows[0].Id()
+	for idx, row := range rows {
+		assert.Equal(t, first+uint32(idx), row.Id())
+	}
+}
@@ -23,13 +23,16 @@ type InternalNode struct {
 }
 
 const INTERNAL_NODE_NUM_KEYS_SIZE = 4
-const INTERNAL_NODE_HEADER_SIZE = PAGE_TYPE_SIZE + INTERNAL_NODE_NUM_KEYS_SIZE
+const INTERNAL_NODE_HEADER_SIZE = PAGE_HEADER_SIZE + INTERNAL_NODE_NUM_KEYS_SIZE
 const INTERNAL_NODE_CHILD_SIZE = 4
 const INTERNAL_NODE_KEY_SIZE = 4
-const INTERNAL_NODE_KEY_PER_PAGE = (PAGE_SIZE - INTERNAL_NODE_HEADER_SIZE - INTERNAL_NODE_CHILD_SIZE) / (INTERNAL_NODE_CHILD_SIZE + INTERNAL_NODE_KEY_SIZE)
-const INTERNAL_NODE_NUM_KEYS_OFFSET = PAGE_TYPE_SIZE
+const INTERNAL_NODE_NUM_KEYS_OFFSET = PAGE_HEADER_SIZE
 const INTERNAL_NODE_FIRST_CHILD_OFFSET = INTERNAL_NODE_HEADER_SIZE
 
+func internalNodeKeyPerPage(pageSize int) int {
+	return (pageSize - INTERNAL_NODE_HEADER_SIZE - INTERNAL_NODE_CHILD_SIZE) / (INTERNAL_NODE_CHILD_SIZE + INTERNAL_NODE_KEY_SIZE)
+}
+
 func (n *InternalNode) ID() uint32 {
 	return n.id
 }
@@ -74,22 +77,7 @@ func convertUint32ToBytes(num uint32) []byte {
 }
 
 func (n *InternalNode) syncBytes() {
-	numKeys := uint32(len(n.keys))
-	copy(n.page.body[INTERNAL_NODE_NUM_KEYS_OFFSET:INTERNAL_NODE_NUM_KEYS_OFFSET+INTERNAL_NODE_NUM_KEYS_SIZE], convertUint32ToBytes(numKeys))
-	if len(n.children) > 0 {
-		offset := INTERNAL_NODE_FIRST_CHILD_OFFSET
-		end := INTERNAL_NODE_FIRST_CHILD_OFFSET + INTERNAL_NODE_CHILD_SIZE
-		copy(n.page.body[offset:end], convertUint32ToBytes(n.children[0]))
-		for idx, key := range n.keys {
-			offset = end
-			end = end + INTERNAL_NODE_KEY_SIZE
-			copy(n.page.body[offset:end], convertUint32ToBytes(key))
-
-			offset = end
-			end = end + INTERNAL_NODE_CHILD_SIZE
-			copy(n.page.body[offset:end], convertUint32ToBytes(n.children[idx+1]))
-		}
-	}
+	codec.encodeInternalNode(n.page.body, n.keys, n.children)
 }
 
 type LeafNode struct {
@@ -100,7 +88,7 @@ type LeafNode struct {
 	page       *Page
 }
 
-const LEAF_NODE_NUM_TUPLES_OFFSET = PAGE_TYPE_SIZE
+const LEAF_NODE_NUM_TUPLES_OFFSET = PAGE_HEADER_SIZE
 const LEAF_NODE_NUM_TUPLE_SIZE = 4
 
 const LEAF_NODE_PREV_NODE_ID_OFFSET = LEAF_NODE_NUM_TUPLES_OFFSET + LEAF_NODE_NUM_TUPLE_SIZE
@@ -114,7 +102,10 @@ const LEAF_NODE_HEADER_SIZE = LEAF_NODE_NEXT_NODE_ID_OFFSET + LEAF_NODE_NEXT_NOD
 const LEAF_NODE_FIRST_CHILD_OFFSET = LEAF_NODE_HEADER_SIZE
 
 const LEAF_NODE_CHILD_SIZE = ROW_SIZE
-const LEAF_NODE_KEY_PER_PAGE = (PAGE_SIZE - LEAF_NODE_HEADER_SIZE) / LEAF_NODE_CHILD_SIZE
+
+func leafNodeKeyPerPage(pageSize int) int {
+	return (pageSize - LEAF_NODE_HEADER_SIZE) / LEAF_NODE_CHILD_SIZE
+}
 
 func (n *LeafNode) ID() uint32 {
 	return n.id
@@ -129,18 +120,33 @@ func (n *LeafNode) PrevNodeID() uint32 {
 	return n.prevNodeID
 }
 
-func (n *LeafNode) NextNode(noder Noder) *LeafNode {
+// NextNode returns the right sibling, nil for the right most leaf node.
+func (n *LeafNode) NextNode(noder Noder) (*LeafNode, error) {
 	if n.nextNodeID == 0 {
-		return nil
+		return nil, nil
 	}
-	return noder.Read(n.nextNodeID).(*LeafNode)
+	return readLeafNode(n.nextNodeID, noder)
 }
 
-func (n *LeafNode) PrevNode(noder Noder) *LeafNode {
+// PrevNode returns the left sibling, nil for the left most leaf node.
+func (n *LeafNode) PrevNode(noder Noder) (*LeafNode, error) {
 	if n.prevNodeID == 0 {
-		return nil
+		return nil, nil
+	}
+	return readLeafNode(n.prevNodeID, noder)
+}
+
+// readLeafNode reads a node which must be a leaf node.
+func readLeafNode(nodeID uint32, noder Noder) (*LeafNode, error) {
+	node, err := noder.Read(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	leafNode, ok := node.(*LeafNode)
+	if ok == false {
+		return nil, ErrCorruptPage{PageID: nodeID}
 	}
-	return noder.Read(n.prevNodeID).(*LeafNode)
+	return leafNode, nil
 }
 
 func (n *LeafNode) SetID(id uint32) {
@@ -186,18 +192,7 @@ func (n *LeafNode) Update(newTuples []*Tuple, prevNodeID uint32, nextNodeID uint
 }
 
 func (n *LeafNode) syncBytes() {
-	numTuples := uint32(len(n.tuples))
-	copy(n.page.body[LEAF_NODE_NUM_TUPLES_OFFSET:LEAF_NODE_NUM_TUPLES_OFFSET+LEAF_NODE_NUM_TUPLE_SIZE], convertUint32ToBytes(numTuples))
-	copy(n.page.body[LEAF_NODE_PREV_NODE_ID_OFFSET:LEAF_NODE_PREV_NODE_ID_OFFSET+LEAF_NODE_PREV_NODE_ID_SIZE], convertUint32ToBytes(n.prevNodeID))
-	copy(n.page.body[LEAF_NODE_NEXT_NODE_ID_OFFSET:LEAF_NODE_NEXT_NODE_ID_OFFSET+LEAF_NODE_NEXT_NODE_ID_SIZE], convertUint32ToBytes(n.nextNodeID))
-
-	offset := LEAF_NODE_FIRST_CHILD_OFFSET
-	end := LEAF_NODE_FIRST_CHILD_OFFSET
-	for _, tuple := range n.tuples {
-		offset = end
-		end = end + ROW_SIZE
-		copy(n.page.body[offset:end], tuple.value)
-	}
+	codec.encodeLeafNode(n.page.body, n.prevNodeID, n.nextNodeID, n.tuples)
 }
 
 type Tuple struct {
@@ -211,6 +206,16 @@ func (a ByKey) Len() int           { return len(a) }
 func (a ByKey) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByKey) Less(i, j int) bool { return a[i].key < a[j].key }
 
+// ErrDuplicateKey is returned when the key inserted into a b-tree already
+// exists, the tree is left unchanged.
+type ErrDuplicateKey struct {
+	Key uint32
+}
+
+func (e ErrDuplicateKey) Error() string {
+	return fmt.Sprintf("id %d already exists", e.Key)
+}
+
 type BTree struct {
 	// Deprecated
 	rootNode            Node
@@ -218,24 +223,33 @@ type BTree struct {
 	capacityPerLeafNode int
 }
 
+// Noder reads and allocates the nodes of a b-tree, an error aborts the
+// operation on the tree which might be half done.
 type Noder interface {
-	Read(nodeId uint32) Node
-	NewLeafNode(tuples []*Tuple) *LeafNode
-	NewInternalNode(keys []uint32, children []uint32) *InternalNode
+	Read(nodeId uint32) (Node, error)
+	NewLeafNode(tuples []*Tuple) (*LeafNode, error)
+	NewInternalNode(keys []uint32, children []uint32) (*InternalNode, error)
 }
 
-func (t *BTree) RootNode(noder Noder) Node {
+func (t *BTree) RootNode(noder Noder) (Node, error) {
 	return noder.Read(t.rootNodeID)
 }
 
-func (t *BTree) String(noder Noder) string {
+func (t *BTree) String(noder Noder) (string, error) {
 	message := ""
 	nodes := []Node{}
-	node := t.RootNode(noder)
+	node, err := t.RootNode(noder)
+	if err != nil {
+		return "", err
+	}
 	for node != nil {
 		message = message + node.String() + "\n"
 		for _, nodeId := range node.Children() {
-			nodes = append(nodes, t.getNode(nodeId, noder))
+			child, err := t.getNode(nodeId, noder)
+			if err != nil {
+				return "", err
+			}
+			nodes = append(nodes, child)
 		}
 		if len(nodes) > 0 {
 			node, nodes = nodes[0], nodes[1:]
@@ -243,67 +257,101 @@ func (t *BTree) String(noder Noder) string {
 			node = nil
 		}
 	}
-	return message
+	return message, nil
 }
 
-func (t *BTree) getNode(nodeId uint32, noder Noder) Node {
+func (t *BTree) getNode(nodeId uint32, noder Noder) (Node, error) {
 	return noder.Read(nodeId)
 }
 
-func (t *BTree) newRoot(middleKey uint32, children []uint32, noder Noder) {
-	newRoot := noder.NewInternalNode([]uint32{middleKey}, children)
+func (t *BTree) newRoot(middleKey uint32, children []uint32, noder Noder) error {
+	newRoot, err := noder.NewInternalNode([]uint32{middleKey}, children)
+	if err != nil {
+		return err
+	}
 	t.rootNode = newRoot
 	t.rootNodeID = newRoot.ID()
+	return nil
 }
 
-// Find correct leaf L.
-// Put data entry into L in sorted order.
-// If L has enough space done!
-// Else, must split L into L and a new node L2
-//  Redistribute entries evenly, copy up middle key
-//  Insert index entry pointing to L2 into parent of L
-func (t *BTree) Insert(key uint32, value []byte, noder Noder) {
-	rootNode := noder.Read(t.rootNodeID)
-	nodes := t.lookup([]Node{rootNode}, key, noder)
+// Insert puts the tuple into the tree:
+//
+//	Find correct leaf L.
+//	Put data entry into L in sorted order.
+//	If L has enough space done!
+//	Else, must split L into L and a new node L2
+//	 Redistribute entries evenly, copy up middle key
+//	 Insert index entry pointing to L2 into parent of L
+func (t *BTree) Insert(key uint32, value []byte, noder Noder) error {
+	rootNode, err := noder.Read(t.rootNodeID)
+	if err != nil {
+		return err
+	}
+	nodes, err := t.lookup([]Node{rootNode}, key, noder)
+	if err != nil {
+		return err
+	}
 	leafNode := nodes[len(nodes)-1].(*LeafNode)
 	nodes = nodes[:len(nodes)-1]
 	keys := leafNode.Keys()
-	newTuples := append(leafNode.Tuples(), &Tuple{key, value})
+	for _, k := range keys {
+		if k == key {
+			return ErrDuplicateKey{Key: key}
+		}
+	}
+	// copy the tuples, after a split the leaf and its new sibling share the
+	// array of the tuples and an append would overwrite the sibling
+	newTuples := append([]*Tuple{}, leafNode.Tuples()...)
+	newTuples = append(newTuples, &Tuple{key, value})
 	sort.Sort(ByKey(newTuples))
 
 	if len(keys) < t.capacityPerLeafNode {
 		leafNode.Update(newTuples, leafNode.PrevNodeID(), leafNode.NextNodeID())
-	} else {
-		midIdx := len(newTuples) / 2
-		leafNode2 := noder.NewLeafNode(newTuples[midIdx:])
-		leafNode2.Update(newTuples[midIdx:], leafNode.ID(), leafNode.NextNodeID())
-		leafNode.Update(newTuples[0:midIdx], leafNode.PrevNodeID(), leafNode2.ID())
-		nodeID := leafNode2.ID()
-		middleKey := newTuples[midIdx].key
+		return nil
+	}
 
-		if len(nodes) == 0 {
-			t.newRoot(middleKey, []uint32{leafNode.ID(), nodeID}, noder)
+	midIdx := len(newTuples) / 2
+	leafNode2, err := noder.NewLeafNode(newTuples[midIdx:])
+	if err != nil {
+		return err
+	}
+	leafNode2.Update(newTuples[midIdx:], leafNode.ID(), leafNode.NextNodeID())
+	nextNode, err := leafNode.NextNode(noder)
+	if err != nil {
+		return err
+	}
+	if nextNode != nil {
+		nextNode.Update(nextNode.Tuples(), leafNode2.ID(), nextNode.NextNodeID())
+	}
+	leafNode.Update(newTuples[0:midIdx], leafNode.PrevNodeID(), leafNode2.ID())
+	nodeID := leafNode2.ID()
+	middleKey := newTuples[midIdx].key
 
-		} else {
-			for i := len(nodes); i > 0; i-- {
-				parentNode := nodes[i-1].(*InternalNode)
-				result := t.addChildrenToInternalNode(middleKey, nodeID, parentNode, noder)
-				if result.splited == false {
-					break
-				} else {
-					middleKey = result.middleKey
-					nodeID = result.newNodeId
-					if t.RootNode(noder) == parentNode {
-						t.newRoot(middleKey, []uint32{parentNode.id, nodeID}, noder)
-					}
-				}
+	if len(nodes) == 0 {
+		return t.newRoot(middleKey, []uint32{leafNode.ID(), nodeID}, noder)
+	}
+	for i := len(nodes); i > 0; i-- {
+		parentNode := nodes[i-1].(*InternalNode)
+		result, err := t.addChildrenToInternalNode(middleKey, nodeID, parentNode, noder)
+		if err != nil {
+			return err
+		}
+		if result.splited == false {
+			break
+		}
+		middleKey = result.middleKey
+		nodeID = result.newNodeId
+		if parentNode.ID() == t.rootNodeID {
+			err = t.newRoot(middleKey, []uint32{parentNode.id, nodeID}, noder)
+			if err != nil {
+				return err
 			}
 		}
 	}
-
+	return nil
 }
 
-func (t *BTree) addChildrenToInternalNode(key uint32, nodeID uint32, internalNode *InternalNode, noder Noder) AddKeyResult {
+func (t *BTree) addChildrenToInternalNode(key uint32, nodeID uint32, internalNode *InternalNode, noder Noder) (AddKeyResult, error) {
 	idx := 0
 	for _, k := range internalNode.keys {
 		if key < k {
@@ -312,11 +360,13 @@ func (t *BTree) addChildrenToInternalNode(key uint32, nodeID uint32, internalNod
 		idx++
 	}
 
-	keys := append(internalNode.keys, 0)
+	keys := append([]uint32{}, internalNode.keys...)
+	keys = append(keys, 0)
 	copy(keys[idx+1:], keys[idx:])
 	keys[idx] = key
 
-	children := append(internalNode.children, 0)
+	children := append([]uint32{}, internalNode.children...)
+	children = append(children, 0)
 	copy(children[idx+2:], children[idx+1:])
 	children[idx+1] = nodeID
 
@@ -324,17 +374,20 @@ func (t *BTree) addChildrenToInternalNode(key uint32, nodeID uint32, internalNod
 		internalNode.Update(keys, children)
 		return AddKeyResult{
 			splited: false,
-		}
+		}, nil
 	} else {
 		midIdx := len(keys) / 2
 		internalNode.Update(keys[0:midIdx], children[0:midIdx+1])
-		node2 := noder.NewInternalNode(keys[midIdx+1:], children[midIdx+1:])
+		node2, err := noder.NewInternalNode(keys[midIdx+1:], children[midIdx+1:])
+		if err != nil {
+			return AddKeyResult{}, err
+		}
 		middleKey := keys[midIdx]
 		return AddKeyResult{
 			splited:   true,
 			middleKey: middleKey,
 			newNodeId: node2.ID(),
-		}
+		}, nil
 	}
 }
 
@@ -344,8 +397,24 @@ type AddKeyResult struct {
 	newNodeId uint32
 }
 
-func (t *BTree) Delete(key uint32) {
-
+// Delete removes the tuple of key from its leaf node and returns it, nil is
+// returned when key does not exist.
+// Underflowed leaf nodes are not merged with their siblings.
+func (t *BTree) Delete(key uint32, noder Noder) (*Tuple, error) {
+	leafNode, err := t.FindLeafNode(key, noder)
+	if err != nil {
+		return nil, err
+	}
+	tuples := leafNode.Tuples()
+	for idx, tuple := range tuples {
+		if tuple.key == key {
+			newTuples := append([]*Tuple{}, tuples[:idx]...)
+			newTuples = append(newTuples, tuples[idx+1:]...)
+			leafNode.Update(newTuples, leafNode.PrevNodeID(), leafNode.NextNodeID())
+			return tuple, nil
+		}
+	}
+	return nil, nil
 }
 
 func compare(val1 uint32, val2 uint32, operator string) bool {
@@ -366,10 +435,11 @@ func compare(val1 uint32, val2 uint32, operator string) bool {
 }
 
 // FindLeafNodeByCondition return tuple index in the leafNode, return -1 if not found
-func (t *BTree) FindLeafNodeByCondition(key uint32, operator string, noder Noder) (*LeafNode, int) {
-	rootNode := t.RootNode(noder)
-	nodes := t.lookup([]Node{rootNode}, key, noder)
-	leafNode := nodes[len(nodes)-1].(*LeafNode)
+func (t *BTree) FindLeafNodeByCondition(key uint32, operator string, noder Noder) (*LeafNode, int, error) {
+	leafNode, err := t.FindLeafNode(key, noder)
+	if err != nil {
+		return nil, -1, err
+	}
 	idx := -1
 	for i, k := range leafNode.Keys() {
 		if compare(k, key, operator) {
@@ -377,95 +447,101 @@ func (t *BTree) FindLeafNodeByCondition(key uint32, operator string, noder Noder
 			break
 		}
 	}
-
 	if idx != -1 || operator == "=" {
-		return leafNode, idx
-	} else if operator == ">=" || operator == ">" {
-		nextNode := leafNode.NextNode(noder)
-		for idx == -1 && nextNode != nil {
-			for i, k := range nextNode.Keys() {
-				if compare(k, key, operator) {
-					leafNode = nextNode
-					idx = i
-					break
-				}
-			}
-			nextNode = nextNode.NextNode(noder)
+		return leafNode, idx, nil
+	}
+
+	forward := operator == ">=" || operator == ">"
+	node := leafNode
+	for idx == -1 {
+		if forward {
+			node, err = node.NextNode(noder)
+		} else {
+			node, err = node.PrevNode(noder)
 		}
-		return leafNode, idx
-	} else {
-		prevNode := leafNode.PrevNode(noder)
-		for idx == -1 && prevNode != nil {
-			for i, k := range prevNode.Keys() {
-				if compare(k, key, operator) {
-					leafNode = prevNode
-					idx = i
-					break
-				}
+		if err != nil {
+			return nil, -1, err
+		}
+		if node == nil {
+			break
+		}
+		for i, k := range node.Keys() {
+			if compare(k, key, operator) {
+				leafNode = node
+				idx = i
+				break
 			}
-			prevNode = prevNode.PrevNode(noder)
 		}
-		return leafNode, idx
 	}
+	return leafNode, idx, nil
 }
 
-func (t *BTree) FindLeafNode(key uint32, noder Noder) *LeafNode {
-	rootNode := t.RootNode(noder)
-	nodes := t.lookup([]Node{rootNode}, key, noder)
-	return nodes[len(nodes)-1].(*LeafNode)
+func (t *BTree) FindLeafNode(key uint32, noder Noder) (*LeafNode, error) {
+	rootNode, err := t.RootNode(noder)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := t.lookup([]Node{rootNode}, key, noder)
+	if err != nil {
+		return nil, err
+	}
+	return nodes[len(nodes)-1].(*LeafNode), nil
 }
 
-func (t *BTree) Find(key uint32, noder Noder) *Tuple {
-	leafNode := t.FindLeafNode(key, noder)
-	if leafNode == nil {
-		return nil
+func (t *BTree) Find(key uint32, noder Noder) (*Tuple, error) {
+	leafNode, err := t.FindLeafNode(key, noder)
+	if err != nil {
+		return nil, err
 	}
 
 	for _, tuple := range leafNode.Tuples() {
 		if tuple.key == key {
-			return tuple
+			return tuple, nil
 		}
 	}
-	return nil
+	return nil, nil
 }
 
-func (t *BTree) First(noder Noder) *Tuple {
-	node := t.RootNode(noder)
-	for node.NodeType() != "LeafNode" {
-		leftChild := node.Children()[0]
-		node = t.getNode(leftChild, noder)
+func (t *BTree) First(noder Noder) (*Tuple, error) {
+	leafNode, err := t.FirstLeafNode(noder)
+	if err != nil {
+		return nil, err
 	}
-	leafNode := node.(*LeafNode)
-	return leafNode.Tuples()[0]
+	return leafNode.Tuples()[0], nil
 }
 
 // Return left most leaf node
-func (t *BTree) FirstLeafNode(noder Noder) *LeafNode {
-	node := t.RootNode(noder)
+func (t *BTree) FirstLeafNode(noder Noder) (*LeafNode, error) {
+	node, err := t.RootNode(noder)
+	if err != nil {
+		return nil, err
+	}
 	for node.NodeType() != "LeafNode" {
 		leftChild := node.Children()[0]
-		node = t.getNode(leftChild, noder)
+		node, err = t.getNode(leftChild, noder)
+		if err != nil {
+			return nil, err
+		}
 	}
-	leafNode := node.(*LeafNode)
-	return leafNode
+	return node.(*LeafNode), nil
 }
 
 // Return node's right sibling
-func (t *BTree) NextLeafNode(node *LeafNode, noder Noder) *LeafNode {
+func (t *BTree) NextLeafNode(node *LeafNode, noder Noder) (*LeafNode, error) {
 	return node.NextNode(noder)
 }
 
 // Return node's left sibling
-func (t *BTree) PrevLeafNode(node *LeafNode, noder Noder) *LeafNode {
+func (t *BTree) PrevLeafNode(node *LeafNode, noder Noder) (*LeafNode, error) {
 	return node.PrevNode(noder)
 }
 
 // return node and it's ancestor nodes
-func (t *BTree) lookup(nodes []Node, key uint32, noder Noder) []Node {
+func (t *BTree) lookup(nodes []Node, key uint32, noder Noder) ([]Node, error) {
 	node := nodes[len(nodes)-1]
 
 	if len(node.Children()) == 0 {
-		return nodes
+		return nodes, nil
 	}
 
 	idx := 0
@@ -476,6 +552,9 @@ func (t *BTree) lookup(nodes []Node, key uint32, noder Noder) []Node {
 		idx++
 	}
 	nodeId := node.Children()[idx]
-	newNodes := append(nodes, t.getNode(nodeId, noder))
-	return t.lookup(newNodes, key, noder)
+	child, err := t.getNode(nodeId, noder)
+	if err != nil {
+		return nil, err
+	}
+	return t.lookup(append(nodes, child), key, noder)
 }
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// btreeModel is the reference a b-tree is checked against, a map kept in key
+// order by sortedKeys.
+type btreeModel map[uint32][]byte
+
+func (m btreeModel) sortedKeys() []uint32 {
+	keys := []uint32{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+var rangeOperators = []string{"=", ">=", ">", "<=", "<"}
+
+// scanBTreeRange returns the keys matching key and operator the way a cursor
+// reads them, a scan bounded from above starts from the left most leaf.
+func scanBTreeRange(tree *BTree, noder Noder, key uint32, operator string) ([]uint32, error) {
+	var leafNode *LeafNode
+	idx := 0
+	var err error
+	if operator == "<" || operator == "<=" {
+		leafNode, err = tree.FirstLeafNode(noder)
+	} else {
+		leafNode, idx, err = tree.FindLeafNodeByCondition(key, operator, noder)
+	}
+	if err != nil || idx == -1 {
+		return []uint32{}, err
+	}
+
+	keys := []uint32{}
+	for leafNode != nil {
+		for _, k := range leafNode.Keys()[idx:] {
+			if compare(k, key, operator) == false {
+				return keys, nil
+			}
+			keys = append(keys, k)
+		}
+		idx = 0
+		leafNode, err = leafNode.NextNode(noder)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func sameTuples(a []*Tuple, b []*Tuple) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx].key != b[idx].key || string(a[idx].value) != string(b[idx].value) {
+			return false
+		}
+	}
+	return true
+}
+
+// btreeChecker walks a tree from its root and reports the first broken
+// invariant.
+type btreeChecker struct {
+	noder     Noder
+	capacity  int
+	leafDepth int
+	leaves    []*LeafNode
+}
+
+func checkBTree(tree *BTree, noder Noder, model btreeModel) error {
+	c := &btreeChecker{noder: noder, capacity: tree.capacityPerLeafNode, leafDepth: -1}
+	err := c.checkNode(tree.rootNodeID, 0, 0, math.MaxUint32+1)
+	if err != nil {
+		return err
+	}
+
+	for idx, leaf := range c.leaves {
+		prevNodeID := uint32(0)
+		if idx > 0 {
+			prevNodeID = c.leaves[idx-1].id
+		}
+		nextNodeID := uint32(0)
+		if idx < len(c.leaves)-1 {
+			nextNodeID = c.leaves[idx+1].id
+		}
+		if leaf.prevNodeID != prevNodeID || leaf.nextNodeID != nextNodeID {
+			return fmt.Errorf("leaf %d links to %d and %d, expected %d and %d", leaf.id, leaf.prevNodeID, leaf.nextNodeID, prevNodeID, nextNodeID)
+		}
+	}
+
+	tuples := []*Tuple{}
+	for _, leaf := range c.leaves {
+		tuples = append(tuples, leaf.tuples...)
+	}
+	keys := model.sortedKeys()
+	if len(tuples) != len(keys) {
+		return fmt.Errorf("tree has %d tuples, expected %d", len(tuples), len(keys))
+	}
+	for idx, key := range keys {
+		if tuples[idx].key != key || string(tuples[idx].value) != string(model[key]) {
+			return fmt.Errorf("tuple %d is %d %q, expected %d %q", idx, tuples[idx].key, tuples[idx].value, key, model[key])
+		}
+	}
+	return nil
+}
+
+// checkNode checks the keys of the node are ascending within
+// [lowerBound, upperBound), and the page bytes decode into the node.
+func (c *btreeChecker) checkNode(nodeID uint32, depth int, lowerBound uint64, upperBound uint64) error {
+	node, err := c.noder.Read(nodeID)
+	if err != nil {
+		return err
+	}
+	keys := node.Keys()
+	if len(keys) > c.capacity {
+		return fmt.Errorf("node %d has %d keys, capacity is %d", nodeID, len(keys), c.capacity)
+	}
+	for idx, key := range keys {
+		if idx > 0 && keys[idx-1] >= key {
+			return fmt.Errorf("node %d has key %d after key %d", nodeID, key, keys[idx-1])
+		}
+		if uint64(key) < lowerBound || uint64(key) >= upperBound {
+			return fmt.Errorf("node %d has key %d out of [%d, %d)", nodeID, key, lowerBound, upperBound)
+		}
+	}
+
+	switch n := node.(type) {
+	case *InternalNode:
+		if len(n.keys) == 0 || len(n.children) != len(n.keys)+1 {
+			return fmt.Errorf("internal node %d has %d keys and %d children", nodeID, len(n.keys), len(n.children))
+		}
+		decodedKeys, decodedChildren, err := codec.decodeInternalNode(nodeID, n.page.body)
+		if err != nil {
+			return err
+		}
+		if fmt.Sprint(decodedKeys, decodedChildren) != fmt.Sprint(n.keys, n.children) {
+			return fmt.Errorf("internal node %d is not synced to its page", nodeID)
+		}
+		for idx, child := range n.children {
+			childLowerBound := lowerBound
+			if idx > 0 {
+				childLowerBound = uint64(n.keys[idx-1])
+			}
+			childUpperBound := upperBound
+			if idx < len(n.keys) {
+				childUpperBound = uint64(n.keys[idx])
+			}
+			err = c.checkNode(child, depth+1, childLowerBound, childUpperBound)
+			if err != nil {
+				return err
+			}
+		}
+	case *LeafNode:
+		if c.leafDepth == -1 {
+			c.leafDepth = depth
+		} else if c.leafDepth != depth {
+			return fmt.Errorf("leaf %d is at depth %d, expected %d", nodeID, depth, c.leafDepth)
+		}
+		prevNodeID, nextNodeID, tuples, err := codec.decodeLeafNode(nodeID, n.page.body)
+		if err != nil {
+			return err
+		}
+		if prevNodeID != n.prevNodeID || nextNodeID != n.nextNodeID || sameTuples(tuples, n.tuples) == false {
+			return fmt.Errorf("leaf %d is not synced to its page", nodeID)
+		}
+		c.leaves = append(c.leaves, n)
+	}
+	return nil
+}
+
+// runBTreeModel applies random inserts, deletes, finds and range scans to a
+// b-tree and to the model, and checks the tree after every operation. Keys
+// are drawn from [0, keySpace) and the edges of uint32, so inserts collide
+// with existing keys and deletes find them.
+func runBTreeModel(t *testing.T, seed int64, capacity int, keySpace int, numOps int) {
+	r := rand.New(rand.NewSource(seed))
+	tree, noder := createDummyBtree()
+	tree.capacityPerLeafNode = capacity
+	model := btreeModel{}
+	fail := func(step int, operation string, err error) {
+		t.Fatalf("seed %d, capacity %d, step %d, %s: %s", seed, capacity, step, operation, err)
+	}
+
+	for step := 0; step < numOps; step++ {
+		key := uint32(r.Intn(keySpace))
+		if r.Intn(20) == 0 {
+			key = []uint32{0, 1, math.MaxUint32}[r.Intn(3)]
+		}
+
+		var operation string
+		var err error
+		switch n := r.Intn(10); {
+		case n < 5:
+			operation = fmt.Sprintf("insert %d", key)
+			value := NewRow(key, fmt.Sprintf("user-%d", key), fmt.Sprintf("step-%d", step)).Bytes()
+			err = tree.Insert(key, value, noder)
+			if model[key] != nil {
+				if err != (ErrDuplicateKey{Key: key}) {
+					err = fmt.Errorf("got %v, expected a duplicate key error", err)
+				} else {
+					err = nil
+				}
+			} else if err == nil {
+				model[key] = value
+			}
+		case n < 7:
+			operation = fmt.Sprintf("delete %d", key)
+			var tuple *Tuple
+			tuple, err = tree.Delete(key, noder)
+			if err == nil && (tuple == nil) != (model[key] == nil) {
+				err = fmt.Errorf("got %v, expected %q", tuple, model[key])
+			}
+			delete(model, key)
+		case n < 8:
+			operation = fmt.Sprintf("find %d", key)
+			var tuple *Tuple
+			tuple, err = tree.Find(key, noder)
+			if err == nil && (tuple == nil) != (model[key] == nil) {
+				err = fmt.Errorf("got %v, expected %q", tuple, model[key])
+			} else if tuple != nil && string(tuple.value) != string(model[key]) {
+				err = fmt.Errorf("got %q, expected %q", tuple.value, model[key])
+			}
+		default:
+			operator := rangeOperators[r.Intn(len(rangeOperators))]
+			operation = fmt.Sprintf("scan id %s %d", operator, key)
+			expected := []uint32{}
+			for _, k := range model.sortedKeys() {
+				if compare(k, key, operator) {
+					expected = append(expected, k)
+				}
+			}
+			var keys []uint32
+			keys, err = scanBTreeRange(tree, noder, key, operator)
+			if err == nil && fmt.Sprint(keys) != fmt.Sprint(expected) {
+				err = fmt.Errorf("got %v, expected %v", keys, expected)
+			}
+		}
+		if err != nil {
+			fail(step, operation, err)
+		}
+		err = checkBTree(tree, noder, model)
+		if err != nil {
+			fail(step, operation, err)
+		}
+	}
+}
+
+func TestBTreeModel(t *testing.T) {
+	numOps := 1000
+	numSeeds := int64(4)
+	if testing.Short() {
+		numOps = 200
+		numSeeds = 3
+	}
+	for _, capacity := range []int{2, 3, 4, leafNodeKeyPerPage(DEFAULT_PAGE_SIZE)} {
+		for seed := int64(1); seed <= numSeeds; seed++ {
+			runBTreeModel(t, seed, capacity, 64, numOps/4)
+			runBTreeModel(t, seed, capacity, 1000, numOps)
+		}
+	}
+}
+
+func TestBTreeInsertDuplicateKey(t *testing.T) {
+	tree, noder := createDummyBtree()
+	tree.Insert(1, []byte("a"), noder)
+	tree.Insert(2, []byte("b"), noder)
+	tree.Insert(3, []byte("c"), noder)
+
+	err := tree.Insert(2, []byte("x"), noder)
+
+	assert.Equal(t, ErrDuplicateKey{Key: 2}, err)
+	assert.Equal(t, "id 2 already exists", err.Error())
+	tuple, _ := tree.Find(2, noder)
+	assert.Equal(t, []byte("b"), tuple.value)
+}
@@ -21,7 +21,7 @@ func createTuple(key uint32) *Tuple {
 }
 
 func TestInternalNodeUpdate(t *testing.T) {
-	page := EmptyPage()
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	node := &InternalNode{
 		keys:     []uint32{},
 		children: []uint32{},
@@ -36,14 +36,16 @@ func TestInternalNodeUpdate(t *testing.T) {
 	assert.Equal(t, keys, node.keys)
 	assert.Equal(t, children, node.children)
 	assert.Equal(t, true, node.page.isDirty)
-	assert.Equal(t, convertUint32ToBytes(uint32(len(keys))), node.page.body[2:6])
-	assert.Equal(t, convertUint32ToBytes(uint32(4)), node.page.body[6:10])
-	assert.Equal(t, convertUint32ToBytes(uint32(5)), node.page.body[10:14])
-	assert.Equal(t, convertUint32ToBytes(uint32(5)), node.page.body[14:18])
+	from := INTERNAL_NODE_NUM_KEYS_OFFSET
+	assert.Equal(t, convertUint32ToBytes(uint32(len(keys))), node.page.body[from:from+4])
+	from = INTERNAL_NODE_FIRST_CHILD_OFFSET
+	assert.Equal(t, convertUint32ToBytes(uint32(4)), node.page.body[from:from+4])
+	assert.Equal(t, convertUint32ToBytes(uint32(5)), node.page.body[from+4:from+8])
+	assert.Equal(t, convertUint32ToBytes(uint32(5)), node.page.body[from+8:from+12])
 }
 
 func TestLeafNodeUpdate(t *testing.T) {
-	page := EmptyPage()
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	node := &LeafNode{
 		tuples: []*Tuple{},
 		page:   page,
@@ -65,8 +67,13 @@ func TestLeafNodeUpdate(t *testing.T) {
 	assert.Equal(t, nextNodeID, node.NextNodeID())
 }
 
+func readNode(noder Noder, nodeID uint32) Node {
+	node, _ := noder.Read(nodeID)
+	return node
+}
+
 func createDummyBtree() (*BTree, *DummyNoder) {
-	page := EmptyPage()
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	rootNode := &LeafNode{
 		id:     0,
 		tuples: []*Tuple{},
@@ -95,14 +102,18 @@ func TestBtreeInsert(t *testing.T) {
 	tree.Insert(8, []byte("i"), noder)
 	tree.Insert(9, []byte("j"), noder)
 
-	newRootNode := tree.RootNode(noder)
+	newRootNode, err := tree.RootNode(noder)
+	assert.Nil(t, err)
 	assert.Equal(t, newRootNode.Keys(), []uint32{5})
-	assert.Equal(t, tree.getNode(newRootNode.Children()[0], noder).Keys(), []uint32{3})
-	assert.Equal(t, tree.getNode(newRootNode.Children()[1], noder).Keys(), []uint32{7})
+	assert.Equal(t, readNode(noder, newRootNode.Children()[0]).Keys(), []uint32{3})
+	assert.Equal(t, readNode(noder, newRootNode.Children()[1]).Keys(), []uint32{7})
 	for i := 0; i < 9; i++ {
-		assert.NotNil(t, tree.Find(uint32(i+1), noder), noder)
+		tuple, err := tree.Find(uint32(i+1), noder)
+		assert.Nil(t, err)
+		assert.NotNil(t, tuple)
 	}
-	assert.Nil(t, tree.Find(10, noder))
+	tuple, _ := tree.Find(10, noder)
+	assert.Nil(t, tuple)
 }
 
 func TestBtreeInsertWithSibling(t *testing.T) {
@@ -114,10 +125,10 @@ func TestBtreeInsertWithSibling(t *testing.T) {
 	tree.Insert(4, []byte("d"), noder)
 	tree.Insert(5, []byte("f"), noder)
 
-	leafNode := tree.FindLeafNode(3, noder)
-	nextNode := noder.Read(leafNode.NextNodeID())
+	leafNode, _ := tree.FindLeafNode(3, noder)
+	nextNode := readNode(noder, leafNode.NextNodeID())
 	assert.Equal(t, []uint32{4, 5}, nextNode.Keys())
-	prevNode := noder.Read(leafNode.PrevNodeID())
+	prevNode := readNode(noder, leafNode.PrevNodeID())
 	assert.Equal(t, []uint32{2}, prevNode.Keys())
 }
 func TestBtreeNextLeafNode(t *testing.T) {
@@ -126,9 +137,11 @@ func TestBtreeNextLeafNode(t *testing.T) {
 	tree.Insert(2, []byte("b"), noder)
 	tree.Insert(3, []byte("c"), noder)
 	tree.Insert(4, []byte("d"), noder)
-	node := tree.FirstLeafNode(noder)
+	node, _ := tree.FirstLeafNode(noder)
 
-	leafNode := tree.NextLeafNode(node, noder)
+	leafNode, err := tree.NextLeafNode(node, noder)
+
+	assert.Nil(t, err)
 
 	assert.Equal(t, leafNode.Keys(), []uint32{2})
 }
@@ -140,8 +153,9 @@ func TestBtreeFindLeafNode(t *testing.T) {
 	tree.Insert(3, []byte("c"), noder)
 	tree.Insert(4, []byte("d"), noder)
 
-	leafNode := tree.FindLeafNode(uint32(3), noder)
+	leafNode, err := tree.FindLeafNode(uint32(3), noder)
 
+	assert.Nil(t, err)
 	assert.Equal(t, leafNode.Keys(), []uint32{3, 4})
 }
 
@@ -151,9 +165,11 @@ func TestBtreePrevLeafNode(t *testing.T) {
 	tree.Insert(2, []byte("b"), noder)
 	tree.Insert(3, []byte("c"), noder)
 	tree.Insert(4, []byte("d"), noder)
-	node := tree.FindLeafNode(uint32(3), noder)
+	node, _ := tree.FindLeafNode(uint32(3), noder)
+
+	leafNode, err := tree.PrevLeafNode(node, noder)
 
-	leafNode := tree.PrevLeafNode(node, noder)
+	assert.Nil(t, err)
 
 	assert.Equal(t, leafNode.Keys(), []uint32{2})
 }
@@ -166,24 +182,44 @@ func TestFindLeafNodeByCondition(t *testing.T) {
 	tree.Insert(4, []byte("d"), noder)
 	tree.Insert(5, []byte("d"), noder)
 
-	leafNode, idx := tree.FindLeafNodeByCondition(uint32(1), "=", noder)
+	leafNode, idx, err := tree.FindLeafNodeByCondition(uint32(1), "=", noder)
+	assert.Nil(t, err)
 	assert.Equal(t, uint32(1), leafNode.Keys()[idx])
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(2), ">=", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(2), ">=", noder)
 	assert.Equal(t, uint32(2), leafNode.Keys()[idx])
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(3), "<=", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(3), "<=", noder)
 	assert.Equal(t, uint32(3), leafNode.Keys()[idx])
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(3), "<", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(3), "<", noder)
 	assert.Equal(t, uint32(2), leafNode.Keys()[idx])
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(3), ">", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(3), ">", noder)
 	assert.Equal(t, uint32(4), leafNode.Keys()[idx])
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(5), ">", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(5), ">", noder)
 	assert.Equal(t, -1, idx)
 
-	leafNode, idx = tree.FindLeafNodeByCondition(uint32(1), "<", noder)
+	leafNode, idx, _ = tree.FindLeafNodeByCondition(uint32(1), "<", noder)
 	assert.Equal(t, -1, idx)
 }
+
+func TestBtreeDelete(t *testing.T) {
+	tree, noder := createDummyBtree()
+	tree.Insert(1, []byte("a"), noder)
+	tree.Insert(2, []byte("b"), noder)
+	tree.Insert(3, []byte("c"), noder)
+	tree.Insert(4, []byte("d"), noder)
+
+	tuple, err := tree.Delete(3, noder)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("c"), tuple.value)
+	tuple, _ = tree.Find(3, noder)
+	assert.Nil(t, tuple)
+	leafNode, _ := tree.FindLeafNode(4, noder)
+	assert.Equal(t, []uint32{4}, leafNode.Keys())
+	tuple, _ = tree.Delete(3, noder)
+	assert.Nil(t, tuple)
+}
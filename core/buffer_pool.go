@@ -6,19 +6,20 @@ import (
 	"sync/atomic"
 )
 
-type PageBody [PAGE_SIZE]byte
+// PageBody holds the bytes of a page, its length is the page size of the
+// database.
+type PageBody = []byte
 
 type Page struct {
 	id      uint32
-	body    *PageBody
+	body    PageBody
 	isDirty bool
 }
 
-func EmptyPage() *Page {
-	body := emptyPageBody()
+func EmptyPage(pageSize int) *Page {
 	return &Page{
 		id:      uint32(0),
-		body:    &body,
+		body:    newPageBody(pageSize),
 		isDirty: false,
 	}
 }
@@ -28,9 +29,11 @@ func (s *Page) MarkAsDirty() {
 }
 
 type Pager interface {
-	Read(offset int64, page *PageBody) error
-	Write(offset int64, page *PageBody) error
-	IncrementPageID() uint32
+	Read(offset int64, page PageBody) error
+	Write(offset int64, page PageBody) error
+	IncrementPageID() (uint32, error)
+	PageSize() int
+	Close() error
 }
 
 type Replacer interface {
@@ -59,10 +62,15 @@ type BufferPool struct {
 	pageTable        map[uint32]*pageMeta
 	replacer         Replacer
 	freeFrameIndices chan int
-	frames           []*PageBody
+	frames           []PageBody
 	pager            Pager
+	pageSize         int
 	maxPageNum       int
-	lock             sync.Mutex
+	hits             uint64
+	misses           uint64
+	// lock guards the page table, frames and replacer, so sessions can share
+	// the buffer pool.
+	lock sync.Mutex
 }
 
 func NewBufferPool(replacer Replacer, pager Pager, initPageNum, maxPageNum int) *BufferPool {
@@ -72,29 +80,45 @@ func NewBufferPool(replacer Replacer, pager Pager, initPageNum, maxPageNum int)
 		pageTable:        make(map[uint32]*pageMeta),
 		replacer:         replacer,
 		freeFrameIndices: freeFrameIndices,
-		frames:           []*PageBody{},
+		frames:           []PageBody{},
 		pager:            pager,
+		pageSize:         pager.PageSize(),
 		maxPageNum:       maxPageNum,
 	}
 
 	for i := 0; i < initPageNum; i++ {
-		bs := emptyPageBody()
-		b.frames = append(b.frames, &bs)
+		b.frames = append(b.frames, newPageBody(b.pageSize))
 		b.freeFrameIndices <- i
 	}
 
 	return b
 }
 
-func emptyPageBody() PageBody {
-	return [PAGE_SIZE]byte{}
+func newPageBody(pageSize int) PageBody {
+	return make(PageBody, pageSize)
 }
 
-func (b *BufferPool) FetchPage(pageID uint32) (*PageBody, error) {
+// resetPageBody fills the page with zero.
+func resetPageBody(body PageBody) {
+	for i := range body {
+		body[i] = 0
+	}
+}
+
+// PageSize returns the page size of the database.
+func (b *BufferPool) PageSize() int {
+	return b.pageSize
+}
+
+func (b *BufferPool) FetchPage(pageID uint32) (PageBody, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	if b.pageTable[pageID] != nil {
 		meta := b.pageTable[pageID]
 		meta.mu.RLock()
 		if meta.pageID == pageID {
+			b.hits++
 			atomic.AddInt32(&meta.referenceCount, 1)
 			b.replacer.Erase(pageID)
 			return b.frames[meta.frameIdx], nil
@@ -104,6 +128,7 @@ func (b *BufferPool) FetchPage(pageID uint32) (*PageBody, error) {
 		}
 	}
 
+	b.misses++
 	frameIdx, err := b.getFreeFrameIdx()
 	if err != nil {
 		return nil, err
@@ -118,10 +143,47 @@ func (b *BufferPool) FetchPage(pageID uint32) (*PageBody, error) {
 	b.replacer.Erase(pageID)
 
 	frame := b.frames[meta.frameIdx]
-	b.pager.Read(int64(pageID)*int64(PAGE_SIZE), frame)
+	err = b.pager.Read(int64(pageID)*int64(b.pageSize), frame)
+	if err != nil {
+		meta.mu.RUnlock()
+		atomic.AddInt32(&meta.referenceCount, -1)
+		delete(b.pageTable, pageID)
+		b.freeFrameIndices <- meta.frameIdx
+		return nil, err
+	}
 	return frame, nil
 }
 
+// BufferPoolStats counts the page fetches served from a frame (hits) and
+// read from the pager (misses) since the buffer pool was created.
+type BufferPoolStats struct {
+	Frames      int
+	MaxFrames   int
+	PinnedPages int
+	Hits        uint64
+	Misses      uint64
+}
+
+func (b *BufferPool) Stats() BufferPoolStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	pinnedPages := 0
+	for _, meta := range b.pageTable {
+		if atomic.LoadInt32(&meta.referenceCount) > 0 {
+			pinnedPages++
+		}
+	}
+	return BufferPoolStats{
+		Frames:      len(b.frames),
+		MaxFrames:   b.maxPageNum,
+		PinnedPages: pinnedPages,
+		Hits:        b.hits,
+		Misses:      b.misses,
+	}
+}
+
+// getFreeFrameIdx must be called with b.lock held.
 func (b *BufferPool) getFreeFrameIdx() (int, error) {
 	select {
 	case frameIdx := <-b.freeFrameIndices:
@@ -133,27 +195,41 @@ func (b *BufferPool) getFreeFrameIdx() (int, error) {
 				return 0, err
 			}
 			frameIdx := b.pageTable[pageId].frameIdx
-			b.evict(pageId)
+			err = b.evict(pageId)
+			if err != nil {
+				// the page stays in its frame, so it can be evicted later
+				b.replacer.Insert(pageId)
+				return 0, err
+			}
 			return frameIdx, nil
 		}
-		b.lock.Lock()
-		defer b.lock.Unlock()
-		bs := emptyPageBody()
 		frameIdx := len(b.frames)
-		b.frames = append(b.frames, &bs)
+		b.frames = append(b.frames, newPageBody(b.pageSize))
 		return frameIdx, nil
 	}
 }
 
-// lock page before evict it
-func (b *BufferPool) evict(pageId uint32) {
+// lock page before evict it, a dirty page is written back so it can be read
+// again from the pager. The page is not evicted when it can not be written.
+func (b *BufferPool) evict(pageId uint32) error {
 	meta := b.pageTable[pageId]
 	meta.mu.Lock()
+	defer meta.mu.Unlock()
+	if meta.isDirty {
+		err := b.pager.Write(int64(pageId)*int64(b.pageSize), b.frames[meta.frameIdx])
+		if err != nil {
+			return err
+		}
+		meta.isDirty = false
+	}
 	delete(b.pageTable, pageId)
-	meta.mu.Unlock()
+	return nil
 }
 
 func (b *BufferPool) UnpinPage(pageID uint32, isDirty bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	meta := b.pageTable[pageID]
 	if isDirty {
 		meta.isDirty = true
@@ -166,18 +242,27 @@ func (b *BufferPool) UnpinPage(pageID uint32, isDirty bool) {
 }
 
 type PageWithPageID struct {
-	page   *PageBody
+	page   PageBody
 	pageID uint32
 }
 
-func (b BufferPool) NewPage() (*Page, error) {
-	pageID := b.pager.IncrementPageID()
+func (b *BufferPool) NewPage() (*Page, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	pageID, err := b.pager.IncrementPageID()
+	if err != nil {
+		return nil, err
+	}
 	frameIdx, err := b.getFreeFrameIdx()
 	if err != nil {
 		return nil, err
 	}
 
 	meta := newPageMeta(frameIdx, pageID)
+	// the empty page is written when it is evicted, so it can be read back
+	// even if the transaction which created it is rolled back
+	meta.isDirty = true
 	b.pageTable[pageID] = meta
 
 	meta.mu.RLock()
@@ -185,8 +270,7 @@ func (b BufferPool) NewPage() (*Page, error) {
 
 	b.replacer.Erase(pageID)
 
-	bs := emptyPageBody()
-	b.frames[meta.frameIdx] = &bs
+	b.frames[meta.frameIdx] = newPageBody(b.pageSize)
 	result := &Page{
 		id:      pageID,
 		body:    b.frames[meta.frameIdx],
@@ -196,20 +280,52 @@ func (b BufferPool) NewPage() (*Page, error) {
 	return result, nil
 }
 
-// What if flush and unpin page concurrently
-func (b BufferPool) FlushPage(pageID uint32) {
+// FlushPage writes the page to the pager, it stays dirty when the write
+// fails.
+func (b *BufferPool) FlushPage(pageID uint32) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	meta := b.pageTable[pageID]
 	frame := b.frames[meta.frameIdx]
-	b.pager.Write(int64(pageID)*int64(PAGE_SIZE), frame)
+	err := b.pager.Write(int64(pageID)*int64(b.pageSize), frame)
+	if err != nil {
+		return err
+	}
 	meta.isDirty = false
+	return nil
 }
 
-func (b BufferPool) FlushAllPage() {
+// FlushAllPage writes every dirty page to the pager and returns the first
+// error, a page which can not be written stays dirty.
+func (b *BufferPool) FlushAllPage() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var firstErr error
 	for pageID, meta := range b.pageTable {
 		frame := b.frames[meta.frameIdx]
 		if meta.isDirty {
-			b.pager.Write(int64(pageID)*int64(PAGE_SIZE), frame)
+			err := b.pager.Write(int64(pageID)*int64(b.pageSize), frame)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
 			meta.isDirty = false
 		}
 	}
+	return firstErr
+}
+
+// Close flushes every dirty page and closes the pager, it returns the first
+// error of the two.
+func (b *BufferPool) Close() error {
+	err := b.FlushAllPage()
+	closeErr := b.pager.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
 }
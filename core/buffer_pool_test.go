@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,23 +11,32 @@ type DummyPager struct {
 	body []byte
 }
 
-func (d *DummyPager) Read(offset int64, bs *PageBody) error {
-	copy(bs[:], d.body[offset:offset+int64(PAGE_SIZE)])
+func (d *DummyPager) Read(offset int64, bs PageBody) error {
+	copy(bs, d.body[offset:offset+int64(DEFAULT_PAGE_SIZE)])
 	return nil
 }
 
-func (d *DummyPager) Write(offset int64, bs *PageBody) error {
-	tmp := d.body
-	d.body = append(tmp[:offset], append(bs[:], tmp[offset+PAGE_SIZE:]...)...)
+func (d *DummyPager) Write(offset int64, bs PageBody) error {
+	copy(d.body[offset:offset+int64(DEFAULT_PAGE_SIZE)], bs)
 	return nil
 }
 
-func (d *DummyPager) IncrementPageID() uint32 {
-	return uint32(len(d.body) / PAGE_SIZE)
+func (d *DummyPager) IncrementPageID() (uint32, error) {
+	id := uint32(len(d.body) / DEFAULT_PAGE_SIZE)
+	d.body = append(d.body, make([]byte, DEFAULT_PAGE_SIZE)...)
+	return id, nil
+}
+
+func (d *DummyPager) PageSize() int {
+	return DEFAULT_PAGE_SIZE
+}
+
+func (d *DummyPager) Close() error {
+	return nil
 }
 
 func createPageFromSlice(slice []byte) PageBody {
-	page := emptyPageBody()
+	page := newPageBody(DEFAULT_PAGE_SIZE)
 	for i, b := range slice {
 		page[i] = b
 	}
@@ -38,7 +48,7 @@ func TestFetchPage(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	bs := emptyPageBody()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(bs[:], expectedPage[:]...)}
 	pool := NewBufferPool(replacer, pager, 5, 100)
@@ -47,7 +57,7 @@ func TestFetchPage(t *testing.T) {
 	page, err := pool.FetchPage(pageID)
 
 	assert.Nil(t, err)
-	assert.Equal(t, expectedPage, *page)
+	assert.Equal(t, expectedPage, page)
 }
 
 func TestFetchPageWhenNewFrame(t *testing.T) {
@@ -55,7 +65,7 @@ func TestFetchPageWhenNewFrame(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	bs := emptyPageBody()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(bs[:], expectedPage[:]...)}
 	pool := NewBufferPool(replacer, pager, 0, 100)
@@ -64,7 +74,7 @@ func TestFetchPageWhenNewFrame(t *testing.T) {
 	page, err := pool.FetchPage(pageID)
 
 	assert.Nil(t, err)
-	assert.Equal(t, PageBody(expectedPage), *page)
+	assert.Equal(t, expectedPage, page)
 }
 
 func TestFetchPageWithEvictPage(t *testing.T) {
@@ -72,7 +82,7 @@ func TestFetchPageWithEvictPage(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	bs := emptyPageBody()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(bs[:], expectedPage[:]...)}
 	pool := NewBufferPool(replacer, pager, 1, 1)
@@ -84,7 +94,7 @@ func TestFetchPageWithEvictPage(t *testing.T) {
 	page, err := pool.FetchPage(pageID2)
 
 	assert.Nil(t, err)
-	assert.Equal(t, PageBody(expectedPage), *page)
+	assert.Equal(t, expectedPage, page)
 }
 
 func TestFetchPageWithEvictPageFailed(t *testing.T) {
@@ -93,7 +103,7 @@ func TestFetchPageWithEvictPageFailed(t *testing.T) {
 		pinnedIdxMap: make(map[uint32]bool),
 	}
 	bs := make([]byte, 4096)
-	expectedPage := append([]byte{1, 2, 3, 4, 5}, make([]byte, PAGE_SIZE-5)...)
+	expectedPage := append([]byte{1, 2, 3, 4, 5}, make([]byte, DEFAULT_PAGE_SIZE-5)...)
 	pager := &DummyPager{body: append(bs, expectedPage...)}
 	pool := NewBufferPool(replacer, pager, 1, 1)
 	pageID1 := uint32(0)
@@ -121,8 +131,108 @@ func TestFlushPage(t *testing.T) {
 		page[i] = b
 	}
 	pool.UnpinPage(pageID, true)
-	pool.FlushPage(pageID)
+	err := pool.FlushPage(pageID)
 
+	assert.Nil(t, err)
 	assert.Equal(t, []byte{1, 2, 3, 4, 5}, pager.body[:5])
 	assert.Equal(t, false, pool.pageTable[pageID].isDirty)
 }
+
+func TestEvictDirtyPage(t *testing.T) {
+	replacer := NewDummyReplacer()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
+	pager := &DummyPager{body: append(bs[:], bs[:]...)}
+	pool := NewBufferPool(replacer, pager, 1, 1)
+	page, _ := pool.FetchPage(0)
+	page[0] = 42
+	pool.UnpinPage(0, true)
+
+	_, err := pool.FetchPage(1)
+
+	assert.Nil(t, err)
+	assert.Equal(t, byte(42), pager.body[0])
+}
+
+// FailingPager fails every write while writeErr is set.
+type FailingPager struct {
+	DummyPager
+	writeErr error
+}
+
+func (f *FailingPager) Write(offset int64, bs PageBody) error {
+	if f.writeErr != nil {
+		return f.writeErr
+	}
+	return f.DummyPager.Write(offset, bs)
+}
+
+func TestEvictDirtyPageWithWriteError(t *testing.T) {
+	replacer := NewDummyReplacer()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
+	pager := &FailingPager{DummyPager: DummyPager{body: append(bs[:], bs[:]...)}, writeErr: errors.New("disk is gone")}
+	pool := NewBufferPool(replacer, pager, 1, 1)
+	page, _ := pool.FetchPage(0)
+	page[0] = 42
+	pool.UnpinPage(0, true)
+
+	_, err := pool.FetchPage(1)
+
+	assert.Equal(t, "disk is gone", err.Error())
+	assert.Equal(t, true, pool.pageTable[0].isDirty)
+	assert.Nil(t, pool.pageTable[1])
+	page, _ = pool.FetchPage(0)
+	assert.Equal(t, byte(42), page[0])
+	pool.UnpinPage(0, false)
+
+	pager.writeErr = nil
+	_, err = pool.FetchPage(1)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(42), pager.body[0])
+}
+
+func TestFlushWithWriteError(t *testing.T) {
+	replacer := NewDummyReplacer()
+	bs := newPageBody(DEFAULT_PAGE_SIZE)
+	pager := &FailingPager{DummyPager: DummyPager{body: append(bs[:], bs[:]...)}, writeErr: errors.New("disk is gone")}
+	pool := NewBufferPool(replacer, pager, 2, 2)
+	page, _ := pool.FetchPage(0)
+	page[0] = 42
+	pool.UnpinPage(0, true)
+
+	assert.Equal(t, "disk is gone", pool.FlushPage(0).Error())
+	assert.Equal(t, "disk is gone", pool.FlushAllPage().Error())
+	assert.Equal(t, true, pool.pageTable[0].isDirty)
+	assert.Equal(t, "disk is gone", pool.Close().Error())
+	assert.Equal(t, byte(0), pager.body[0])
+}
+
+func TestDummyReplacerSkipsErasedFrame(t *testing.T) {
+	replacer := NewDummyReplacer()
+	replacer.Insert(1)
+	replacer.Insert(2)
+
+	replacer.Erase(1)
+	victim, _ := replacer.Victim()
+	_, err := replacer.Victim()
+
+	assert.Equal(t, uint32(2), victim)
+	assert.Equal(t, "no victim to evict", err.Error())
+}
+
+func TestTableStats(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	table.InsertRow(NewRow(1, "harry", "harry@hogwarts.edu"))
+
+	stats, err := table.Stats()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, stats.NumRows)
+	assert.Equal(t, DEFAULT_PAGE_SIZE, stats.PageSize)
+	assert.Equal(t, uint32(2), stats.PageCount)
+	assert.Equal(t, uint32(1), stats.ChangeCounter)
+	assert.Equal(t, 0, stats.BufferPool.PinnedPages)
+	assert.Equal(t, uint64(2), stats.BufferPool.Misses)
+	assert.True(t, stats.BufferPool.Hits > 0)
+	table.CloseTable()
+}
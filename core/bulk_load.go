@@ -0,0 +1,311 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The fill factor is the percentage of a node BulkLoad fills, leaving room
+// lets the rows inserted later go into a node without splitting it.
+const DEFAULT_FILL_FACTOR = 100
+const MIN_FILL_FACTOR = 10
+
+// ErrTableNotEmpty is returned by BulkLoad, a b-tree is only built bottom-up
+// from scratch.
+var ErrTableNotEmpty = errors.New("table is not empty")
+
+// bulkEntry is a node written by the bulk loader, key is its smallest key.
+type bulkEntry struct {
+	key    uint32
+	pageID uint32
+}
+
+// bulkLoader builds a b-tree bottom-up. The pages are written by a chain of
+// transactions, each committed once it pins half of the buffer pool, so the
+// size of the tree is not limited by the buffer pool. The tree is only
+// reachable from the table header once it is complete.
+type bulkLoader struct {
+	table *Table
+	tx    *Transaction
+	noder *TransactionNoder
+	// leafCapacity is the number of tuples of a leaf and fanout the number
+	// of children of an internal node, except for the last nodes of a level
+	leafCapacity int
+	fanout       int
+	// written are the pages of the committed transactions, they are released
+	// when loading fails
+	written    map[uint32]bool
+	prevLeafID uint32
+}
+
+// BulkLoad fills an empty table with rows sorted by id, next returns nil
+// after the last row. Leaves are packed with rows and the internal nodes are
+// built on top of them, which is much faster than inserting the rows one by
+// one. It returns the number of rows loaded, nothing is loaded when it fails.
+func (t *Table) BulkLoad(next func() (*Row, error)) (int, error) {
+	return t.BulkLoadWithFillFactor(next, DEFAULT_FILL_FACTOR)
+}
+
+// BulkLoadWithFillFactor is BulkLoad filling fillFactor percent of every
+// node.
+func (t *Table) BulkLoadWithFillFactor(next func() (*Row, error), fillFactor int) (int, error) {
+	err := t.tryLockWrites()
+	if err != nil {
+		return 0, err
+	}
+	defer t.unlockWrites()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.bulkLoad(next, fillFactor)
+}
+
+// bulkLoad must be called with the write lock and the table lock held.
+func (t *Table) bulkLoad(next func() (*Row, error), fillFactor int) (int, error) {
+	if fillFactor < MIN_FILL_FACTOR || fillFactor > 100 {
+		message := fmt.Sprintf("fill factor must be between %d and 100", MIN_FILL_FACTOR)
+		return 0, errors.New(message)
+	}
+	if t.readOnly {
+		return 0, ErrReadOnly
+	}
+	empty, err := t.isEmpty()
+	if err != nil {
+		return 0, err
+	}
+	if empty == false {
+		return 0, ErrTableNotEmpty
+	}
+
+	// an internal node holds as many keys as a leaf holds tuples
+	capacity := t.btree.capacityPerLeafNode * fillFactor / 100
+	l := &bulkLoader{
+		table:        t,
+		leafCapacity: maxInt(capacity, 1),
+		fanout:       maxInt(capacity, 2) + 1,
+		written:      map[uint32]bool{},
+	}
+	l.begin()
+	numRows, err := l.load(next)
+	if err != nil {
+		return 0, l.abort(err)
+	}
+	return numRows, nil
+}
+
+// isEmpty reports whether the b-tree is a single leaf without rows, the
+// leaves emptied by deletes are not merged so an empty table might still
+// have a deeper tree.
+func (t *Table) isEmpty() (bool, error) {
+	if t.numRows != 0 {
+		return false, nil
+	}
+	tx := t.newTransaction()
+	defer tx.Rollback()
+	node, err := (&TransactionNoder{transaction: tx}).Read(t.btree.rootNodeID)
+	if err != nil {
+		return false, err
+	}
+	leafNode, ok := node.(*LeafNode)
+	return ok && len(leafNode.tuples) == 0, nil
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (l *bulkLoader) begin() {
+	l.tx = l.table.newTransaction()
+	l.noder = &TransactionNoder{transaction: l.tx}
+}
+
+// commitBatch commits the transaction once it pins half of the buffer pool,
+// the table header still points to the old root.
+func (l *bulkLoader) commitBatch() error {
+	if l.tx.full() == false {
+		return nil
+	}
+	tx := l.tx
+	l.tx = nil
+	err := l.table.commit(tx)
+	if err != nil {
+		return err
+	}
+	for pageID := range tx.pageTable {
+		if pageID != 0 {
+			l.written[pageID] = true
+		}
+	}
+	l.begin()
+	return nil
+}
+
+func (l *bulkLoader) load(next func() (*Row, error)) (int, error) {
+	entries := []bulkEntry{}
+	tuples := []*Tuple{}
+	numRows := 0
+	for true {
+		row, err := next()
+		if err != nil {
+			return 0, err
+		}
+		if row == nil {
+			break
+		}
+		if numRows > 0 && row.Id() <= tuples[len(tuples)-1].key {
+			message := fmt.Sprintf("rows must be sorted by id, %d comes after %d", row.Id(), tuples[len(tuples)-1].key)
+			return 0, errors.New(message)
+		}
+		numRows++
+
+		err = l.commitBatch()
+		if err != nil {
+			return 0, err
+		}
+		if len(tuples) == l.leafCapacity {
+			entry, err := l.writeLeaf(tuples)
+			if err != nil {
+				return 0, err
+			}
+			entries = append(entries, entry)
+			tuples = []*Tuple{}
+		}
+		tuple, err := l.newTuple(row)
+		if err != nil {
+			return 0, err
+		}
+		tuples = append(tuples, tuple)
+	}
+	if numRows == 0 {
+		l.table.rollback(l.tx)
+		l.tx = nil
+		return 0, nil
+	}
+
+	entry, err := l.writeLeaf(tuples)
+	if err != nil {
+		return 0, err
+	}
+	entries = append(entries, entry)
+	for len(entries) > 1 {
+		entries, err = l.writeLevel(entries)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return numRows, l.finish(entries[0].pageID, numRows)
+}
+
+// newTuple encodes the row, a long username or email is written into
+// overflow pages.
+func (l *bulkLoader) newTuple(row *Row) (*Tuple, error) {
+	overflowPageID, err := writeOverflow(l.tx, codec.encodeRowOverflow(row))
+	if err != nil {
+		return nil, err
+	}
+	return &Tuple{key: row.Id(), value: codec.encodeRow(row, overflowPageID)}, nil
+}
+
+// writeLeaf writes the tuples into a new leaf and links it to the previous
+// one.
+func (l *bulkLoader) writeLeaf(tuples []*Tuple) (bulkEntry, error) {
+	leafNode, err := l.noder.NewLeafNode(tuples)
+	if err != nil {
+		return bulkEntry{}, err
+	}
+	if l.prevLeafID != 0 {
+		prevLeafNode, err := readLeafNode(l.prevLeafID, l.noder)
+		if err != nil {
+			return bulkEntry{}, err
+		}
+		prevLeafNode.Update(prevLeafNode.tuples, prevLeafNode.prevNodeID, leafNode.id)
+		leafNode.Update(tuples, l.prevLeafID, 0)
+	}
+	l.prevLeafID = leafNode.id
+	return bulkEntry{key: tuples[0].key, pageID: leafNode.id}, nil
+}
+
+// writeLevel writes the parents of the nodes of a level, a parent has at
+// most fanout and at least 2 children.
+func (l *bulkLoader) writeLevel(entries []bulkEntry) ([]bulkEntry, error) {
+	parents := []bulkEntry{}
+	for start := 0; start < len(entries); {
+		end := start + l.fanout
+		if end > len(entries) {
+			end = len(entries)
+		}
+		// leave 2 children for the last parent
+		if len(entries)-end == 1 {
+			end--
+		}
+
+		err := l.commitBatch()
+		if err != nil {
+			return nil, err
+		}
+		keys := []uint32{}
+		children := []uint32{}
+		for idx, entry := range entries[start:end] {
+			if idx > 0 {
+				keys = append(keys, entry.key)
+			}
+			children = append(children, entry.pageID)
+		}
+		node, err := l.noder.NewInternalNode(keys, children)
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, bulkEntry{key: entries[start].key, pageID: node.id})
+		start = end
+	}
+	return parents, nil
+}
+
+// finish replaces the empty root leaf with the new tree and records the row
+// count.
+func (l *bulkLoader) finish(rootPageID uint32, numRows int) error {
+	header, err := l.tx.ReadPage(uint32(0))
+	if err != nil {
+		return err
+	}
+	err = freePage(l.tx, l.table.btree.rootNodeID)
+	if err != nil {
+		return err
+	}
+	writeTableHeaderField(header, TABLE_HEADER_ROW_COUNT_OFFSET, uint32(numRows))
+
+	tx := l.tx
+	l.tx = nil
+	l.table.btree.rootNodeID = rootPageID
+	err = l.table.commit(tx)
+	if err != nil {
+		return err
+	}
+	l.table.numRows = numRows
+	return nil
+}
+
+// abort rolls back the running transaction and releases the pages written by
+// the committed ones.
+func (l *bulkLoader) abort(err error) error {
+	if l.tx != nil {
+		l.table.rollback(l.tx)
+	}
+	tx := l.table.newTransaction()
+	for pageID := range l.written {
+		if tx.full() {
+			l.table.commit(tx)
+			tx = l.table.newTransaction()
+		}
+		freeErr := freePage(tx, pageID)
+		if freeErr != nil {
+			l.table.rollback(tx)
+			message := fmt.Sprintf("%s, the pages written could not be released: %s", err, freeErr)
+			return errors.New(message)
+		}
+	}
+	l.table.commit(tx)
+	return err
+}
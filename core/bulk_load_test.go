@@ -0,0 +1,116 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rowSource returns the rows one by one, then nil.
+func rowSource(rows []*Row) func() (*Row, error) {
+	return func() (*Row, error) {
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+func sortedRows(numRows int) []*Row {
+	rows := []*Row{}
+	for i := 1; i <= numRows; i++ {
+		email := "user@test.com"
+		if i%100 == 0 {
+			email = strings.Repeat("e", 3*DEFAULT_PAGE_SIZE)
+		}
+		rows = append(rows, NewRow(uint32(2*i), "user", email))
+	}
+	return rows
+}
+
+func TestTableBulkLoad(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTableWithOptions(getTestFileName(), &TableOptions{BufferPoolSize: 2 * MIN_BUFFER_POOL_SIZE})
+	rows := sortedRows(3000)
+
+	numRows, err := table.BulkLoad(rowSource(rows))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3000, numRows)
+	assert.Equal(t, 3000, table.NumRows())
+	problems, err := table.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	assert.Equal(t, rows, drainRowIterator(t, table.Scan(nil, nil)))
+	// the leaves are packed, besides them there are the internal nodes and
+	// the overflow pages of 30 long emails
+	stats, _ := table.Stats()
+	capacity := leafNodeKeyPerPage(DEFAULT_PAGE_SIZE)
+	numLeaves := (3000 + capacity - 1) / capacity
+	assert.True(t, int(stats.PageCount) < 1+numLeaves+numLeaves/10+30*4)
+
+	err = table.InsertRow(NewRow(3, "user", "user@test.com"))
+	assert.Nil(t, err)
+	found, err := table.DeleteRow(3000)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	problems, _ = table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+
+	_, err = table.BulkLoad(rowSource(rows))
+	assert.Equal(t, ErrTableNotEmpty, err)
+}
+
+func TestTableBulkLoadFailureReleasesPages(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTableWithOptions(getTestFileName(), &TableOptions{BufferPoolSize: 2 * MIN_BUFFER_POOL_SIZE})
+	rows := sortedRows(500)
+	rows = append(rows, NewRow(1, "user", "user@test.com"))
+
+	_, err := table.BulkLoad(rowSource(rows))
+
+	assert.Equal(t, "rows must be sorted by id, 1 comes after 1000", err.Error())
+	assert.Equal(t, 0, table.NumRows())
+	assert.Equal(t, 0, len(drainRowIterator(t, table.Scan(nil, nil))))
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+
+	numRows, err := table.BulkLoad(rowSource(sortedRows(20)))
+	assert.Nil(t, err)
+	assert.Equal(t, 20, numRows)
+	problems, _ = table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestTableBulkLoadWithFillFactor(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTableWithOptions(getTestFileName(), &TableOptions{BufferPoolSize: 2 * MIN_BUFFER_POOL_SIZE})
+	rows := []*Row{}
+	for i := 1; i <= 1000; i++ {
+		rows = append(rows, NewRow(uint32(2*i), "user", "user@test.com"))
+	}
+
+	_, err := table.BulkLoadWithFillFactor(rowSource(rows), 5)
+	assert.Equal(t, "fill factor must be between 10 and 100", err.Error())
+
+	numRows, err := table.BulkLoadWithFillFactor(rowSource(rows), 50)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, numRows)
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	assert.Equal(t, rows, drainRowIterator(t, table.Scan(nil, nil)))
+	// half filled leaves leave room for the rows inserted between them
+	stats, _ := table.Stats()
+	capacity := leafNodeKeyPerPage(DEFAULT_PAGE_SIZE) / 2
+	assert.True(t, int(stats.PageCount) > 1000/capacity)
+	for i := 1; i <= 1000; i += 2 {
+		err = table.InsertRow(NewRow(uint32(2*i+1), "user", "user@test.com"))
+		assert.Nil(t, err)
+	}
+	stats2, _ := table.Stats()
+	assert.Equal(t, stats.PageCount, stats2.PageCount)
+}
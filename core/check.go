@@ -0,0 +1,271 @@
+package core
+
+import "fmt"
+
+// integrityChecker walks every page reachable from the table header and
+// records the problems it finds.
+type integrityChecker struct {
+	bufferPool *BufferPool
+	pageCount  uint32
+	referenced map[uint32]bool
+	leaves     []*LeafNode
+	leafDepth  int
+	// corrupt is set once a page can not be read, the rows of a corrupt
+	// leaf can not be counted
+	corrupt  bool
+	problems []string
+}
+
+// CheckIntegrity verifies the b-tree starting from the root recorded in the
+// table header: key ordering within and across nodes, separator key bounds,
+// sibling links, uniform leaf depth, the row count, and that every page is referenced by
+// exactly one owner. It returns the problems found, an empty slice means the
+// file is consistent. There is no secondary index to verify yet.
+func (t *Table) CheckIntegrity() ([]string, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	header, err := readTableHeader(t.bufferPool)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &integrityChecker{
+		bufferPool: t.bufferPool,
+		pageCount:  header.pageCount,
+		referenced: map[uint32]bool{0: true},
+		leafDepth:  -1,
+		problems:   []string{},
+	}
+	if header.rootPageNum != t.btree.rootNodeID {
+		c.report("table header records root %d, but the b-tree root is %d", header.rootPageNum, t.btree.rootNodeID)
+	}
+
+	err = c.checkNode(header.rootPageNum, 0, 0, 1<<32)
+	if err != nil {
+		return nil, err
+	}
+	c.checkSiblingLinks()
+	c.checkRowCount(header.rowCount)
+	err = c.checkFreeList(header.freeListHead)
+	if err != nil {
+		return nil, err
+	}
+	for pageID := uint32(1); pageID < c.pageCount; pageID++ {
+		if c.referenced[pageID] == false {
+			c.report("page %d is orphaned", pageID)
+		}
+	}
+	return c.problems, nil
+}
+
+func (c *integrityChecker) report(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// reference marks the page as used, it returns false when the page can not be
+// read because it is out of range or already used by another owner.
+func (c *integrityChecker) reference(pageID uint32, owner string) bool {
+	if pageID == 0 || pageID >= c.pageCount {
+		c.report("%s references page %d, which is out of range", owner, pageID)
+		return false
+	}
+	if c.referenced[pageID] {
+		c.report("%s references page %d, which is referenced twice", owner, pageID)
+		return false
+	}
+	c.referenced[pageID] = true
+	return true
+}
+
+// readPage copies the page out of the buffer pool, so walking a large file
+// does not keep every page pinned. A corrupt page is reported and nil is
+// returned.
+func (c *integrityChecker) readPage(pageID uint32) (*Page, error) {
+	body, err := c.bufferPool.FetchPage(pageID)
+	if err != nil {
+		if _, ok := err.(ErrCorruptPage); ok {
+			c.corrupt = true
+			c.report("%s", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	page := EmptyPage(len(body))
+	page.id = pageID
+	copy(page.body, body)
+	c.bufferPool.UnpinPage(pageID, false)
+	return page, nil
+}
+
+// checkNode verifies the subtree of pageID, every key of it must be within
+// [lowerBound, upperBound).
+func (c *integrityChecker) checkNode(pageID uint32, depth int, lowerBound uint64, upperBound uint64) error {
+	owner := "table header"
+	if depth > 0 {
+		owner = fmt.Sprintf("internal node at depth %d", depth-1)
+	}
+	if c.reference(pageID, owner) == false {
+		return nil
+	}
+	page, err := c.readPage(pageID)
+	if err != nil || page == nil {
+		return err
+	}
+
+	pageType := codec.pageType(page.body)
+	switch pageType {
+	case PAGE_TYPE_INTERNAL_NODE:
+		node, err := deserializeInternalNodeFromPage(pageID, page)
+		if err != nil {
+			c.report("%s", err)
+			return nil
+		}
+		c.checkKeys(pageID, node.keys, lowerBound, upperBound)
+		for idx, child := range node.children {
+			childLowerBound := lowerBound
+			if idx > 0 {
+				childLowerBound = uint64(node.keys[idx-1])
+			}
+			childUpperBound := upperBound
+			if idx < len(node.keys) {
+				childUpperBound = uint64(node.keys[idx])
+			}
+			err = c.checkNode(child, depth+1, childLowerBound, childUpperBound)
+			if err != nil {
+				return err
+			}
+		}
+	case PAGE_TYPE_LEAF_NODE:
+		node, err := deserializeLeafNodeFromPage(pageID, page)
+		if err != nil {
+			c.report("%s", err)
+			return nil
+		}
+		if c.leafDepth == -1 {
+			c.leafDepth = depth
+		} else if c.leafDepth != depth {
+			c.report("leaf node %d is at depth %d, expected %d", pageID, depth, c.leafDepth)
+		}
+		c.leaves = append(c.leaves, node)
+		c.checkKeys(pageID, node.Keys(), lowerBound, upperBound)
+		for _, tuple := range node.tuples {
+			err = c.checkOverflow(pageID, tuple)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		c.report("page %d has page type %d, expected a b-tree node", pageID, pageType)
+	}
+	return nil
+}
+
+func (c *integrityChecker) checkKeys(pageID uint32, keys []uint32, lowerBound uint64, upperBound uint64) {
+	for idx, key := range keys {
+		if idx > 0 && keys[idx-1] >= key {
+			c.report("node %d has key %d after key %d", pageID, key, keys[idx-1])
+		}
+		if uint64(key) < lowerBound || uint64(key) >= upperBound {
+			c.report("node %d has key %d, which is out of [%d, %d)", pageID, key, lowerBound, upperBound)
+		}
+	}
+}
+
+// checkOverflow verifies the overflow chain of a row holds exactly the bytes
+// which do not fit inline.
+func (c *integrityChecker) checkOverflow(leafID uint32, tuple *Tuple) error {
+	_, overflow, err := codec.decodeRow(tuple.value)
+	if err != nil {
+		c.report("leaf node %d: %s", leafID, err)
+		return nil
+	}
+	expected := overflow.size()
+	pageID := overflow.pageID
+
+	size := 0
+	owner := fmt.Sprintf("row %d in leaf node %d", tuple.key, leafID)
+	for pageID != 0 {
+		if c.reference(pageID, owner) == false {
+			return nil
+		}
+		page, err := c.readPage(pageID)
+		if err != nil || page == nil {
+			return err
+		}
+		pageType := codec.pageType(page.body)
+		if pageType != PAGE_TYPE_OVERFLOW {
+			c.report("page %d has page type %d, expected an overflow page", pageID, pageType)
+			return nil
+		}
+		next, data, err := codec.decodeOverflowPage(pageID, page.body)
+		if err != nil {
+			c.report("%s", err)
+			return nil
+		}
+		size = size + len(data)
+		pageID = next
+	}
+	if size != expected {
+		c.report("%s has %d overflow bytes, expected %d", owner, size, expected)
+	}
+	return nil
+}
+
+// checkSiblingLinks verifies the leaves found from left to right are chained
+// by their prevNodeID and nextNodeID.
+func (c *integrityChecker) checkSiblingLinks() {
+	for idx, leaf := range c.leaves {
+		prevNodeID := uint32(0)
+		if idx > 0 {
+			prevNodeID = c.leaves[idx-1].id
+		}
+		nextNodeID := uint32(0)
+		if idx < len(c.leaves)-1 {
+			nextNodeID = c.leaves[idx+1].id
+		}
+		if leaf.prevNodeID != prevNodeID {
+			c.report("leaf node %d has previous node %d, expected %d", leaf.id, leaf.prevNodeID, prevNodeID)
+		}
+		if leaf.nextNodeID != nextNodeID {
+			c.report("leaf node %d has next node %d, expected %d", leaf.id, leaf.nextNodeID, nextNodeID)
+		}
+	}
+}
+
+func (c *integrityChecker) checkRowCount(rowCount uint32) {
+	if c.corrupt {
+		return
+	}
+	numTuples := 0
+	for _, leaf := range c.leaves {
+		numTuples = numTuples + len(leaf.tuples)
+	}
+	if uint32(numTuples) != rowCount {
+		c.report("table header records %d rows, but the leaves have %d", rowCount, numTuples)
+	}
+}
+
+func (c *integrityChecker) checkFreeList(pageID uint32) error {
+	owner := "free list"
+	for pageID != 0 {
+		if c.reference(pageID, owner) == false {
+			return nil
+		}
+		page, err := c.readPage(pageID)
+		if err != nil || page == nil {
+			return err
+		}
+		pageType := codec.pageType(page.body)
+		if pageType != PAGE_TYPE_FREE {
+			c.report("page %d has page type %d, expected a free page", pageID, pageType)
+			return nil
+		}
+		owner = fmt.Sprintf("free page %d", pageID)
+		pageID, err = codec.decodeFreePage(pageID, page.body)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
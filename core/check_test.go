@@ -0,0 +1,92 @@
+package core
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckIntegrityAfterRandomWrites(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	r := rand.New(rand.NewSource(1))
+	ids := r.Perm(400)
+	for _, id := range ids {
+		email := "user@test.com"
+		if id%50 == 0 {
+			email = strings.Repeat("a", COLUMN_EMAIL_LENGTH+DEFAULT_PAGE_SIZE)
+		}
+		table.InsertRow(NewRow(uint32(id), "user", email))
+	}
+	for _, id := range ids[:100] {
+		table.DeleteRow(uint32(id))
+	}
+
+	problems, err := table.CheckIntegrity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	rows, _ := table.IndexScan(&IndexCondition{Target: uint32(ids[200]), Operator: "="}, nil)
+	assert.Equal(t, 1, len(rows))
+}
+
+func TestCheckIntegrityReportsUnorderedKeys(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{createTuple(42), createTuple(17)})
+	table, _ := OpenTable(fileName)
+
+	problems, err := table.CheckIntegrity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node 1 has key 17 after key 42"}, problems)
+}
+
+func TestCheckIntegrityReportsBrokenSiblingLink(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	for i := 1; i <= 20; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	tx := table.newTransaction()
+	noder := &TransactionNoder{transaction: tx}
+	leafNode, _ := table.btree.FirstLeafNode(noder)
+	nextNodeID := leafNode.NextNodeID()
+	leafNode.Update(leafNode.Tuples(), leafNode.PrevNodeID(), 0)
+	tx.Commit()
+
+	problems, err := table.CheckIntegrity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(problems))
+	assert.Contains(t, problems[0], "has next node 0, expected")
+	assert.NotEqual(t, uint32(0), nextNodeID)
+}
+
+func TestCheckIntegrityReportsOrphanedPage(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	tx := table.newTransaction()
+	allocatePage(tx)
+	table.commit(tx)
+
+	problems, err := table.CheckIntegrity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"page 2 is orphaned"}, problems)
+}
+
+func TestCheckIntegrityReportsCorruptPage(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{createTuple(17)})
+	flipByte(fileName, DEFAULT_PAGE_SIZE+LEAF_NODE_FIRST_CHILD_OFFSET)
+	table, _ := OpenTable(fileName)
+
+	problems, err := table.CheckIntegrity()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"page 1 is corrupted"}, problems)
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Every page except the table header starts with
+// PAGE_TYPE(2 bytes), CHECKSUM(4 bytes)
+// the table header keeps its checksum in TABLE_HEADER_CHECKSUM instead.
+const PAGE_CHECKSUM_OFFSET = PAGE_TYPE_SIZE
+const PAGE_CHECKSUM_SIZE = 4
+const PAGE_HEADER_SIZE = PAGE_CHECKSUM_OFFSET + PAGE_CHECKSUM_SIZE
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorruptPage is returned when a page read from disk does not match its
+// checksum or can not be decoded.
+type ErrCorruptPage struct {
+	PageID uint32
+}
+
+func (e ErrCorruptPage) Error() string {
+	return fmt.Sprintf("page %d is corrupted", e.PageID)
+}
+
+func pageChecksumOffset(pageID uint32) int {
+	if pageID == 0 {
+		return TABLE_HEADER_CHECKSUM_OFFSET
+	}
+	return PAGE_CHECKSUM_OFFSET
+}
+
+// pageChecksum is the CRC32C of the page without its checksum field.
+func pageChecksum(pageID uint32, bs []byte) uint32 {
+	offset := pageChecksumOffset(pageID)
+	checksum := crc32.Update(0, crc32cTable, bs[:offset])
+	return crc32.Update(checksum, crc32cTable, bs[offset+PAGE_CHECKSUM_SIZE:])
+}
+
+func stampPageChecksum(pageID uint32, bs []byte) {
+	offset := pageChecksumOffset(pageID)
+	binary.LittleEndian.PutUint32(bs[offset:], pageChecksum(pageID, bs))
+}
+
+func verifyPageChecksum(pageID uint32, bs []byte) error {
+	offset := pageChecksumOffset(pageID)
+	if binary.LittleEndian.Uint32(bs[offset:]) != pageChecksum(pageID, bs) {
+		return ErrCorruptPage{PageID: pageID}
+	}
+	return nil
+}
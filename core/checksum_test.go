@@ -0,0 +1,73 @@
+package core
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyPageChecksum(t *testing.T) {
+	page := createPageFromSlice([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	stampPageChecksum(1, page[:])
+
+	assert.Nil(t, verifyPageChecksum(1, page[:]))
+	page[100] = 1
+	assert.Equal(t, ErrCorruptPage{PageID: 1}, verifyPageChecksum(1, page[:]))
+}
+
+func TestVerifyPageChecksumOfTableHeader(t *testing.T) {
+	header := &TableHeader{formatVersion: FORMAT_VERSION, pageSize: DEFAULT_PAGE_SIZE, pageCount: 2, rootPageNum: 1}
+	page := newPageBody(DEFAULT_PAGE_SIZE)
+	copy(page[:], header.Bytes())
+	stampPageChecksum(0, page[:])
+
+	assert.Nil(t, verifyPageChecksum(0, page[:]))
+	assert.Equal(t, header.Bytes()[:TABLE_HEADER_CHECKSUM_OFFSET], page[:TABLE_HEADER_CHECKSUM_OFFSET])
+}
+
+func flipByte(fileName string, offset int64) {
+	f, _ := os.OpenFile(fileName, os.O_RDWR, 0644)
+	bs := make([]byte, 1)
+	f.ReadAt(bs, offset)
+	bs[0] = bs[0] ^ 0x10
+	f.WriteAt(bs, offset)
+	f.Close()
+}
+
+func TestFetchCorruptPage(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{createTuple(17), createTuple(42)})
+	flipByte(fileName, DEFAULT_PAGE_SIZE+LEAF_NODE_NUM_TUPLES_OFFSET)
+	table, _ := OpenTable(fileName)
+
+	page, err := table.bufferPool.FetchPage(1)
+
+	assert.Nil(t, page)
+	assert.Equal(t, ErrCorruptPage{PageID: 1}, err)
+	assert.Nil(t, table.bufferPool.pageTable[1])
+}
+
+func TestOpenTableWithCorruptHeader(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	flipByte(fileName, TABLE_HEADER_CHANGE_COUNTER_OFFSET)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, table)
+	assert.Equal(t, ErrCorruptPage{PageID: 0}, err)
+}
+
+func TestFetchPageBeyondEndOfFile(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+
+	_, err := table.bufferPool.FetchPage(5)
+
+	assert.Equal(t, ErrCorruptPage{PageID: 5}, err)
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// pageCodec owns the byte layout of every page and record in a database file.
+// Each layout is encoded and decoded by a pair of methods kept next to each
+// other, so the two directions can not drift apart. The layouts are
+// described by the *_OFFSET and *_SIZE constants. A decode method checks
+// every count and length before slicing, because a page read from disk can
+// hold anything.
+type pageCodec struct{}
+
+var codec = pageCodec{}
+
+// pageType reads the PAGE_TYPE_* of a page.
+func (pageCodec) pageType(body []byte) int {
+	return int(binary.LittleEndian.Uint16(body[:PAGE_TYPE_SIZE]))
+}
+
+// Row
+// ID, USERNAME_LENGTH, USERNAME(COLUMN_USERNAME_LENGTH bytes), EMAIL_LENGTH,
+// EMAIL(COLUMN_EMAIL_LENGTH bytes), OVERFLOW_PAGE_ID
+// only the first COLUMN_USERNAME_LENGTH bytes of the username and the first
+// COLUMN_EMAIL_LENGTH bytes of the email are inline, the remaining bytes live
+// in the overflow chain starting from OVERFLOW_PAGE_ID, see encodeRowOverflow.
+func (pageCodec) encodeRow(row *Row, overflowPageID uint32) []byte {
+	bs := make([]byte, ROW_SIZE)
+	binary.LittleEndian.PutUint32(bs[ROW_ID_OFFSET:], row.id)
+
+	binary.LittleEndian.PutUint32(bs[ROW_USERNAME_LENGTH_OFFSET:], uint32(len(row.username)))
+	copy(bs[ROW_USERNAME_OFFSET:ROW_USERNAME_OFFSET+COLUMN_USERNAME_LENGTH], row.username)
+
+	binary.LittleEndian.PutUint32(bs[ROW_EMAIL_LENGTH_OFFSET:], uint32(len(row.email)))
+	copy(bs[ROW_EMAIL_OFFSET:ROW_EMAIL_OFFSET+COLUMN_EMAIL_LENGTH], row.email)
+	binary.LittleEndian.PutUint32(bs[ROW_EMAIL_OVERFLOW_PAGE_ID_OFFSET:], overflowPageID)
+	return bs
+}
+
+// rowOverflow describes the bytes of a row which are not inline, they are
+// stored in the overflow chain starting from pageID.
+type rowOverflow struct {
+	pageID         uint32
+	usernameLength int
+	emailLength    int
+}
+
+// overflowLength returns how many bytes of a column with length bytes do not
+// fit into inlineLength bytes.
+func overflowLength(length int, inlineLength int) int {
+	if length > inlineLength {
+		return length - inlineLength
+	}
+	return 0
+}
+
+// size returns the number of bytes in the overflow chain.
+func (o rowOverflow) size() int {
+	return overflowLength(o.usernameLength, COLUMN_USERNAME_LENGTH) + overflowLength(o.emailLength, COLUMN_EMAIL_LENGTH)
+}
+
+// complete appends data read from the overflow chain to the inline username
+// and email of row.
+func (o rowOverflow) complete(row *Row, data []byte) {
+	usernameLength := overflowLength(o.usernameLength, COLUMN_USERNAME_LENGTH)
+	row.username = row.username + string(data[:usernameLength])
+	row.email = row.email + string(data[usernameLength:])
+}
+
+// Row Overflow
+// USERNAME(bytes after COLUMN_USERNAME_LENGTH), EMAIL(bytes after COLUMN_EMAIL_LENGTH)
+// it is empty when both columns fit inline.
+func (pageCodec) encodeRowOverflow(row *Row) []byte {
+	bs := []byte{}
+	if len(row.username) > COLUMN_USERNAME_LENGTH {
+		bs = append(bs, row.username[COLUMN_USERNAME_LENGTH:]...)
+	}
+	if len(row.email) > COLUMN_EMAIL_LENGTH {
+		bs = append(bs, row.email[COLUMN_EMAIL_LENGTH:]...)
+	}
+	return bs
+}
+
+// decodeRow decodes a leaf tuple, username and email only contain their
+// inline part when overflow.size() > 0.
+func (c pageCodec) decodeRow(bs []byte) (row *Row, overflow rowOverflow, err error) {
+	if len(bs) < ROW_SIZE {
+		message := fmt.Sprintf("tuple has %d bytes, expected %d", len(bs), ROW_SIZE)
+		return nil, rowOverflow{}, errors.New(message)
+	}
+	id := binary.LittleEndian.Uint32(bs[ROW_ID_OFFSET:])
+
+	overflow = rowOverflow{
+		pageID:         c.rowOverflowPageID(bs),
+		usernameLength: int(binary.LittleEndian.Uint32(bs[ROW_USERNAME_LENGTH_OFFSET:])),
+		emailLength:    int(binary.LittleEndian.Uint32(bs[ROW_EMAIL_LENGTH_OFFSET:])),
+	}
+	if overflow.usernameLength < 0 {
+		message := fmt.Sprintf("tuple %d has username length %d", id, overflow.usernameLength)
+		return nil, rowOverflow{}, errors.New(message)
+	}
+	if overflow.emailLength < 0 {
+		message := fmt.Sprintf("tuple %d has email length %d", id, overflow.emailLength)
+		return nil, rowOverflow{}, errors.New(message)
+	}
+	if overflow.size() > 0 && overflow.pageID == 0 {
+		message := fmt.Sprintf("tuple %d has %d overflow bytes but no overflow page", id, overflow.size())
+		return nil, rowOverflow{}, errors.New(message)
+	}
+
+	usernameLength := overflow.usernameLength - overflowLength(overflow.usernameLength, COLUMN_USERNAME_LENGTH)
+	username := string(bs[ROW_USERNAME_OFFSET : ROW_USERNAME_OFFSET+usernameLength])
+	emailLength := overflow.emailLength - overflowLength(overflow.emailLength, COLUMN_EMAIL_LENGTH)
+	email := string(bs[ROW_EMAIL_OFFSET : ROW_EMAIL_OFFSET+emailLength])
+	return NewRow(id, username, email), overflow, nil
+}
+
+// rowKey returns the id of a leaf tuple.
+func (pageCodec) rowKey(bs []byte) uint32 {
+	return binary.LittleEndian.Uint32(bs[ROW_ID_OFFSET:])
+}
+
+// rowOverflowPageID returns the first overflow page of a leaf tuple, 0 when
+// the whole row is stored inline.
+func (pageCodec) rowOverflowPageID(bs []byte) uint32 {
+	return binary.LittleEndian.Uint32(bs[ROW_EMAIL_OVERFLOW_PAGE_ID_OFFSET:])
+}
+
+// encodeTableHeader encodes the header into the beginning of a page, the
+// checksum is stamped when the page is written.
+func (pageCodec) encodeTableHeader(h *TableHeader) []byte {
+	bs := make([]byte, TABLE_HEADER_HEADER_SIZE)
+	copy(bs[TABLE_HEADER_MAGIC_OFFSET:], TABLE_HEADER_MAGIC)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_FORMAT_VERSION_OFFSET:], h.formatVersion)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_PAGE_SIZE_OFFSET:], h.pageSize)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_PAGE_COUNT_OFFSET:], h.pageCount)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_ROOT_PAGE_NUM_OFFSET:], h.rootPageNum)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_FREE_LIST_HEAD_OFFSET:], h.freeListHead)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_CHANGE_COUNTER_OFFSET:], h.changeCounter)
+	binary.LittleEndian.PutUint32(bs[TABLE_HEADER_ROW_COUNT_OFFSET:], h.rowCount)
+	return bs
+}
+
+// decodeTableHeader decodes the header at the beginning of bs, it fails when
+// bs does not start with TABLE_HEADER_MAGIC.
+func (pageCodec) decodeTableHeader(bs []byte) (*TableHeader, error) {
+	if hasTableHeaderMagic(bs) == false {
+		return nil, errors.New("file is not a sqlbit database")
+	}
+	return &TableHeader{
+		formatVersion: binary.LittleEndian.Uint32(bs[TABLE_HEADER_FORMAT_VERSION_OFFSET:]),
+		pageSize:      binary.LittleEndian.Uint32(bs[TABLE_HEADER_PAGE_SIZE_OFFSET:]),
+		pageCount:     binary.LittleEndian.Uint32(bs[TABLE_HEADER_PAGE_COUNT_OFFSET:]),
+		rootPageNum:   binary.LittleEndian.Uint32(bs[TABLE_HEADER_ROOT_PAGE_NUM_OFFSET:]),
+		freeListHead:  binary.LittleEndian.Uint32(bs[TABLE_HEADER_FREE_LIST_HEAD_OFFSET:]),
+		changeCounter: binary.LittleEndian.Uint32(bs[TABLE_HEADER_CHANGE_COUNTER_OFFSET:]),
+		rowCount:      binary.LittleEndian.Uint32(bs[TABLE_HEADER_ROW_COUNT_OFFSET:]),
+	}, nil
+}
+
+// tableHeaderField reads a single uint32 field of the table header, offset is
+// one of the TABLE_HEADER_*_OFFSET.
+func (pageCodec) tableHeaderField(body []byte, offset int) uint32 {
+	return binary.LittleEndian.Uint32(body[offset : offset+4])
+}
+
+func (pageCodec) setTableHeaderField(body []byte, offset int, value uint32) {
+	binary.LittleEndian.PutUint32(body[offset:offset+4], value)
+}
+
+// Internal Node
+// PAGE_TYPE, CHECKSUM, NUM_KEYS, CHILD, then KEY, CHILD for every key.
+func (pageCodec) encodeInternalNode(body []byte, keys []uint32, children []uint32) {
+	binary.LittleEndian.PutUint16(body[:PAGE_TYPE_SIZE], uint16(PAGE_TYPE_INTERNAL_NODE))
+	binary.LittleEndian.PutUint32(body[INTERNAL_NODE_NUM_KEYS_OFFSET:], uint32(len(keys)))
+	if len(children) == 0 {
+		return
+	}
+	offset := INTERNAL_NODE_FIRST_CHILD_OFFSET
+	binary.LittleEndian.PutUint32(body[offset:], children[0])
+	offset = offset + INTERNAL_NODE_CHILD_SIZE
+	for idx, key := range keys {
+		binary.LittleEndian.PutUint32(body[offset:], key)
+		offset = offset + INTERNAL_NODE_KEY_SIZE
+		binary.LittleEndian.PutUint32(body[offset:], children[idx+1])
+		offset = offset + INTERNAL_NODE_CHILD_SIZE
+	}
+}
+
+func (pageCodec) decodeInternalNode(pageID uint32, body []byte) (keys []uint32, children []uint32, err error) {
+	numKeys := binary.LittleEndian.Uint32(body[INTERNAL_NODE_NUM_KEYS_OFFSET:])
+	if int(numKeys) > internalNodeKeyPerPage(len(body)) {
+		return nil, nil, ErrCorruptPage{PageID: pageID}
+	}
+	keys = make([]uint32, 0, numKeys)
+	children = make([]uint32, 0, numKeys+1)
+	offset := INTERNAL_NODE_FIRST_CHILD_OFFSET
+	children = append(children, binary.LittleEndian.Uint32(body[offset:]))
+	offset = offset + INTERNAL_NODE_CHILD_SIZE
+	for i := 0; i < int(numKeys); i++ {
+		keys = append(keys, binary.LittleEndian.Uint32(body[offset:]))
+		offset = offset + INTERNAL_NODE_KEY_SIZE
+		children = append(children, binary.LittleEndian.Uint32(body[offset:]))
+		offset = offset + INTERNAL_NODE_CHILD_SIZE
+	}
+	return keys, children, nil
+}
+
+// Leaf Node
+// PAGE_TYPE, CHECKSUM, NUM_TUPLES, PREV_NODE_ID, NEXT_NODE_ID, then a tuple
+// of ROW_SIZE bytes for every row.
+func (pageCodec) encodeLeafNode(body []byte, prevNodeID uint32, nextNodeID uint32, tuples []*Tuple) {
+	binary.LittleEndian.PutUint16(body[:PAGE_TYPE_SIZE], uint16(PAGE_TYPE_LEAF_NODE))
+	binary.LittleEndian.PutUint32(body[LEAF_NODE_NUM_TUPLES_OFFSET:], uint32(len(tuples)))
+	binary.LittleEndian.PutUint32(body[LEAF_NODE_PREV_NODE_ID_OFFSET:], prevNodeID)
+	binary.LittleEndian.PutUint32(body[LEAF_NODE_NEXT_NODE_ID_OFFSET:], nextNodeID)
+	offset := LEAF_NODE_FIRST_CHILD_OFFSET
+	for _, tuple := range tuples {
+		copy(body[offset:offset+ROW_SIZE], tuple.value)
+		offset = offset + ROW_SIZE
+	}
+}
+
+// decodeLeafNode copies the tuples out of the page, encodeLeafNode rewrites
+// the page in place after the tuples are reordered.
+func (c pageCodec) decodeLeafNode(pageID uint32, body []byte) (prevNodeID uint32, nextNodeID uint32, tuples []*Tuple, err error) {
+	numTuples := binary.LittleEndian.Uint32(body[LEAF_NODE_NUM_TUPLES_OFFSET:])
+	if int(numTuples) > leafNodeKeyPerPage(len(body)) {
+		return 0, 0, nil, ErrCorruptPage{PageID: pageID}
+	}
+	prevNodeID = binary.LittleEndian.Uint32(body[LEAF_NODE_PREV_NODE_ID_OFFSET:])
+	nextNodeID = binary.LittleEndian.Uint32(body[LEAF_NODE_NEXT_NODE_ID_OFFSET:])
+	tuples = make([]*Tuple, 0, numTuples)
+	offset := LEAF_NODE_FIRST_CHILD_OFFSET
+	for i := 0; i < int(numTuples); i++ {
+		bs := make([]byte, ROW_SIZE)
+		copy(bs, body[offset:offset+ROW_SIZE])
+		tuples = append(tuples, &Tuple{key: c.rowKey(bs), value: bs})
+		offset = offset + ROW_SIZE
+	}
+	return prevNodeID, nextNodeID, tuples, nil
+}
+
+// Overflow Page
+// PAGE_TYPE, CHECKSUM, NEXT_PAGE_ID, DATA_SIZE, DATA
+// data must fit into overflowPageCapacity.
+func (pageCodec) encodeOverflowPage(body []byte, nextPageID uint32, data []byte) {
+	binary.LittleEndian.PutUint16(body[:PAGE_TYPE_SIZE], uint16(PAGE_TYPE_OVERFLOW))
+	binary.LittleEndian.PutUint32(body[OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET:], nextPageID)
+	binary.LittleEndian.PutUint32(body[OVERFLOW_PAGE_DATA_SIZE_OFFSET:], uint32(len(data)))
+	copy(body[OVERFLOW_PAGE_HEADER_SIZE:], data)
+}
+
+// decodeOverflowPage returns the next page of the chain and the data of the
+// page, the data is not copied.
+func (c pageCodec) decodeOverflowPage(pageID uint32, body []byte) (nextPageID uint32, data []byte, err error) {
+	if c.pageType(body) != PAGE_TYPE_OVERFLOW {
+		message := fmt.Sprintf("page %d is not an overflow page", pageID)
+		return 0, nil, errors.New(message)
+	}
+	nextPageID = binary.LittleEndian.Uint32(body[OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET:])
+	size := binary.LittleEndian.Uint32(body[OVERFLOW_PAGE_DATA_SIZE_OFFSET:])
+	if int(size) > overflowPageCapacity(len(body)) {
+		message := fmt.Sprintf("overflow page %d has invalid data size %d", pageID, size)
+		return 0, nil, errors.New(message)
+	}
+	return nextPageID, body[OVERFLOW_PAGE_HEADER_SIZE : OVERFLOW_PAGE_HEADER_SIZE+int(size)], nil
+}
+
+// Free Page
+// PAGE_TYPE, CHECKSUM, NEXT_PAGE_ID
+func (pageCodec) encodeFreePage(body []byte, nextPageID uint32) {
+	binary.LittleEndian.PutUint16(body[:PAGE_TYPE_SIZE], uint16(PAGE_TYPE_FREE))
+	binary.LittleEndian.PutUint32(body[FREE_PAGE_NEXT_PAGE_ID_OFFSET:], nextPageID)
+}
+
+func (c pageCodec) decodeFreePage(pageID uint32, body []byte) (uint32, error) {
+	if c.pageType(body) != PAGE_TYPE_FREE {
+		return 0, ErrCorruptPage{PageID: pageID}
+	}
+	return binary.LittleEndian.Uint32(body[FREE_PAGE_NEXT_PAGE_ID_OFFSET:]), nil
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomBytes(r *rand.Rand, n int) string {
+	bs := make([]byte, n)
+	r.Read(bs)
+	return string(bs)
+}
+
+// randomRows returns rows with random ids and random bytes, followed by the
+// rows at the edges: ids 1 and math.MaxUint32, the longest username and
+// emails around COLUMN_EMAIL_LENGTH.
+func randomRows(r *rand.Rand, n int) []*Row {
+	rows := []*Row{}
+	for i := 0; i < n; i++ {
+		username := randomBytes(r, r.Intn(2*COLUMN_USERNAME_LENGTH))
+		email := randomBytes(r, r.Intn(2*COLUMN_EMAIL_LENGTH))
+		rows = append(rows, NewRow(r.Uint32(), username, email))
+	}
+	for _, id := range []uint32{1, math.MaxUint32} {
+		for _, usernameLength := range []int{0, COLUMN_USERNAME_LENGTH, COLUMN_USERNAME_LENGTH + 1} {
+			for _, emailLength := range []int{0, COLUMN_EMAIL_LENGTH - 1, COLUMN_EMAIL_LENGTH, COLUMN_EMAIL_LENGTH + 1} {
+				rows = append(rows, NewRow(id, strings.Repeat("u", usernameLength), strings.Repeat("e", emailLength)))
+			}
+		}
+	}
+	return rows
+}
+
+func TestCodecRowRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	for _, row := range randomRows(r, 1000) {
+		overflowPageID := uint32(0)
+		data := codec.encodeRowOverflow(row)
+		if len(data) > 0 {
+			overflowPageID = r.Uint32()
+		}
+		inlineUsername := row.username
+		if len(row.username) > COLUMN_USERNAME_LENGTH {
+			inlineUsername = row.username[:COLUMN_USERNAME_LENGTH]
+		}
+		inlineEmail := row.email
+		if len(row.email) > COLUMN_EMAIL_LENGTH {
+			inlineEmail = row.email[:COLUMN_EMAIL_LENGTH]
+		}
+
+		bs := codec.encodeRow(row, overflowPageID)
+		actual, overflow, err := codec.decodeRow(bs)
+
+		assert.Nil(t, err)
+		assert.Equal(t, ROW_SIZE, len(bs))
+		assert.Equal(t, row.id, codec.rowKey(bs))
+		assert.Equal(t, NewRow(row.id, inlineUsername, inlineEmail), actual)
+		assert.Equal(t, rowOverflow{pageID: overflowPageID, usernameLength: len(row.username), emailLength: len(row.email)}, overflow)
+		assert.Equal(t, len(data), overflow.size())
+		overflow.complete(actual, data)
+		assert.Equal(t, row, actual)
+	}
+}
+
+func TestCodecDecodeRowWithCorruptLength(t *testing.T) {
+	bs := codec.encodeRow(NewRow(7, "Harry", "harry@hogwarts.edu"), 0)
+
+	_, _, err := codec.decodeRow(bs[:ROW_SIZE-1])
+	assert.Equal(t, fmt.Sprintf("tuple has %d bytes, expected %d", ROW_SIZE-1, ROW_SIZE), err.Error())
+
+	binary.LittleEndian.PutUint32(bs[ROW_EMAIL_LENGTH_OFFSET:], 1<<31)
+	_, _, err = codec.decodeRow(bs)
+	assert.Equal(t, fmt.Sprintf("tuple 7 has %d overflow bytes but no overflow page", 1<<31-COLUMN_EMAIL_LENGTH), err.Error())
+}
+
+func TestCodecTableHeaderRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	for i := 0; i < 100; i++ {
+		header := &TableHeader{
+			formatVersion: r.Uint32(),
+			pageSize:      r.Uint32(),
+			pageCount:     r.Uint32(),
+			rootPageNum:   r.Uint32(),
+			freeListHead:  r.Uint32(),
+			changeCounter: r.Uint32(),
+			rowCount:      r.Uint32(),
+		}
+		body := newPageBody(MIN_PAGE_SIZE)
+		copy(body, codec.encodeTableHeader(header))
+
+		actual, err := codec.decodeTableHeader(body)
+
+		assert.Nil(t, err)
+		assert.Equal(t, header, actual)
+		assert.Equal(t, header.rowCount, codec.tableHeaderField(body, TABLE_HEADER_ROW_COUNT_OFFSET))
+	}
+}
+
+func randomKeys(r *rand.Rand, n int) []uint32 {
+	keys := make([]uint32, n)
+	for i := range keys {
+		keys[i] = r.Uint32()
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func TestCodecInternalNodeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	for pageSize := MIN_PAGE_SIZE; pageSize <= MAX_PAGE_SIZE; pageSize *= 2 {
+		for _, numKeys := range []int{0, 1, r.Intn(internalNodeKeyPerPage(pageSize)), internalNodeKeyPerPage(pageSize)} {
+			keys := randomKeys(r, numKeys)
+			children := randomKeys(r, numKeys+1)
+			body := newPageBody(pageSize)
+			codec.encodeInternalNode(body, keys, children)
+
+			actualKeys, actualChildren, err := codec.decodeInternalNode(3, body)
+
+			assert.Nil(t, err)
+			assert.Equal(t, PAGE_TYPE_INTERNAL_NODE, codec.pageType(body))
+			assert.Equal(t, keys, actualKeys)
+			assert.Equal(t, children, actualChildren)
+		}
+	}
+}
+
+func TestCodecLeafNodeRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	rows := randomRows(r, MAX_PAGE_SIZE/ROW_SIZE)
+	for pageSize := MIN_PAGE_SIZE; pageSize <= MAX_PAGE_SIZE; pageSize *= 2 {
+		for _, numTuples := range []int{0, 1, leafNodeKeyPerPage(pageSize)} {
+			tuples := []*Tuple{}
+			for _, row := range rows[:numTuples] {
+				tuples = append(tuples, &Tuple{key: row.id, value: codec.encodeRow(row, r.Uint32())})
+			}
+			prevNodeID := r.Uint32()
+			nextNodeID := r.Uint32()
+			body := newPageBody(pageSize)
+			codec.encodeLeafNode(body, prevNodeID, nextNodeID, tuples)
+
+			actualPrevNodeID, actualNextNodeID, actualTuples, err := codec.decodeLeafNode(3, body)
+
+			assert.Nil(t, err)
+			assert.Equal(t, PAGE_TYPE_LEAF_NODE, codec.pageType(body))
+			assert.Equal(t, prevNodeID, actualPrevNodeID)
+			assert.Equal(t, nextNodeID, actualNextNodeID)
+			assert.Equal(t, tuples, actualTuples)
+		}
+	}
+}
+
+func TestCodecOverflowPageRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(47))
+	for pageSize := MIN_PAGE_SIZE; pageSize <= MAX_PAGE_SIZE; pageSize *= 2 {
+		capacity := overflowPageCapacity(pageSize)
+		for _, size := range []int{1, r.Intn(capacity) + 1, capacity} {
+			data := []byte(randomBytes(r, size))
+			nextPageID := r.Uint32()
+			body := newPageBody(pageSize)
+			codec.encodeOverflowPage(body, nextPageID, data)
+
+			actualNextPageID, actualData, err := codec.decodeOverflowPage(3, body)
+
+			assert.Nil(t, err)
+			assert.Equal(t, nextPageID, actualNextPageID)
+			assert.Equal(t, data, actualData)
+		}
+	}
+
+	body := newPageBody(DEFAULT_PAGE_SIZE)
+	codec.encodeOverflowPage(body, 0, nil)
+	binary.LittleEndian.PutUint32(body[OVERFLOW_PAGE_DATA_SIZE_OFFSET:], uint32(overflowPageCapacity(DEFAULT_PAGE_SIZE)+1))
+	_, _, err := codec.decodeOverflowPage(3, body)
+	assert.Equal(t, "overflow page 3 has invalid data size 4083", err.Error())
+
+	codec.encodeFreePage(body, 0)
+	_, _, err = codec.decodeOverflowPage(3, body)
+	assert.Equal(t, "page 3 is not an overflow page", err.Error())
+}
+
+func TestCodecFreePageRoundTrip(t *testing.T) {
+	body := newPageBody(DEFAULT_PAGE_SIZE)
+	for _, nextPageID := range []uint32{0, 1, math.MaxUint32} {
+		codec.encodeFreePage(body, nextPageID)
+
+		actual, err := codec.decodeFreePage(3, body)
+
+		assert.Nil(t, err)
+		assert.Equal(t, nextPageID, actual)
+	}
+
+	codec.encodeLeafNode(body, 0, 0, nil)
+	_, err := codec.decodeFreePage(3, body)
+	assert.Equal(t, ErrCorruptPage{PageID: 3}, err)
+}
+
+// TestTableRowRoundTrip writes the rows through the b-tree and the overflow
+// pages, and reads them back before and after the file is reopened.
+func TestTableRowRoundTrip(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+	r := rand.New(rand.NewSource(47))
+	expected := map[uint32]*Row{}
+	for _, row := range randomRows(r, 100) {
+		if expected[row.id] != nil {
+			continue
+		}
+		expected[row.id] = row
+		err := table.InsertRow(row)
+		assert.Nil(t, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		rows, err := table.SeqScan(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, len(expected), len(rows))
+		for _, row := range rows {
+			assert.Equal(t, expected[row.id], row)
+		}
+
+		assert.Nil(t, table.CloseTable())
+		table, err = OpenTable(fileName)
+		assert.Nil(t, err)
+	}
+	table.CloseTable()
+}
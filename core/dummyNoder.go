@@ -4,8 +4,8 @@ type DummyNoder struct {
 	nodes []Node
 }
 
-func (n *DummyNoder) Read(nodeId uint32) Node {
-	return n.nodes[nodeId]
+func (n *DummyNoder) Read(nodeId uint32) (Node, error) {
+	return n.nodes[nodeId], nil
 }
 
 func (n *DummyNoder) add(node Node) uint32 {
@@ -15,19 +15,20 @@ func (n *DummyNoder) add(node Node) uint32 {
 	return idx
 }
 
-func (n *DummyNoder) NewLeafNode(tuples []*Tuple) *LeafNode {
-	page := EmptyPage()
+func (n *DummyNoder) NewLeafNode(tuples []*Tuple) (*LeafNode, error) {
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	node := &LeafNode{
 		tuples: tuples,
 		page:   page,
 	}
 	id := n.add(node)
 	node.SetID(id)
-	return node
+	node.syncBytes()
+	return node, nil
 }
 
-func (n *DummyNoder) NewInternalNode(keys []uint32, children []uint32) *InternalNode {
-	page := EmptyPage()
+func (n *DummyNoder) NewInternalNode(keys []uint32, children []uint32) (*InternalNode, error) {
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	node := &InternalNode{
 		keys:     keys,
 		children: children,
@@ -36,7 +37,8 @@ func (n *DummyNoder) NewInternalNode(keys []uint32, children []uint32) *Internal
 
 	id := n.add(node)
 	node.SetID(id)
-	return node
+	node.syncBytes()
+	return node, nil
 }
 
 func (n *DummyNoder) Clean(nodeId uint32, isDirty bool) {
@@ -15,6 +15,8 @@ func NewDummyReplacer() *DummyReplacer {
 }
 
 func (d *DummyReplacer) Insert(frameIdx uint32) {
+	delete(d.pinnedIdxMap, frameIdx)
+	d.remove(frameIdx)
 	d.frameIndices = append(d.frameIndices, frameIdx)
 }
 
@@ -27,6 +29,18 @@ func (d *DummyReplacer) Victim() (uint32, error) {
 	return 0, errors.New("no victim to evict")
 }
 
+// Erase removes the frame from the victim candidates, a pinned frame must
+// never be evicted.
 func (d *DummyReplacer) Erase(frameIdx uint32) {
 	d.pinnedIdxMap[frameIdx] = true
+	d.remove(frameIdx)
+}
+
+func (d *DummyReplacer) remove(frameIdx uint32) {
+	for i, idx := range d.frameIndices {
+		if idx == frameIdx {
+			d.frameIndices = append(d.frameIndices[:i], d.frameIndices[i+1:]...)
+			return
+		}
+	}
 }
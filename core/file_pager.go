@@ -2,72 +2,161 @@ package core
 
 import (
 	"bufio"
-	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"sync/atomic"
 )
 
+// MAX_PAGE_COUNT is the number of pages a database file can hold, page ids
+// are uint32 and page 0 is the table header.
+const MAX_PAGE_COUNT = math.MaxUint32
+
+// ErrDatabaseFull is returned when a new page would make the database file
+// larger than its maximum size.
+var ErrDatabaseFull = errors.New("database is full")
+
 type FilePager struct {
-	file     *os.File
-	numPages int64
+	file         *os.File
+	numPages     int64
+	pageSize     int
+	maxPageCount int64
 }
 
-func NewFilePager(fileName string) (*FilePager, error) {
-	_, err := os.Stat(fileName)
-	if os.IsNotExist(err) {
-		err = createDBFile(fileName)
+// NewFilePager opens the database file, options.PageSize is only used when
+// the file has to be created, an existing file keeps the page size in its
+// header. The file can grow up to options.MaxSize bytes, 0 means
+// MAX_PAGE_COUNT pages. A read only file is neither created nor upgraded.
+func NewFilePager(fileName string, options *TableOptions) (*FilePager, error) {
+	if options.ReadOnly {
+		err := checkReadOnlyFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := prepareFile(fileName, options.PageSize)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	f, err := os.OpenFile(fileName, os.O_RDWR, 0644)
+	flag := os.O_RDWR
+	if options.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	f, err := os.OpenFile(fileName, flag, 0644)
 
 	if err != nil {
 		return nil, err
 	}
 
+	header, err := readTableHeaderFromFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
 	fi, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
-	numPages := fi.Size() / PAGE_SIZE
+	if fi.Size() < int64(header.pageCount)*int64(header.pageSize) {
+		f.Close()
+		return nil, errors.New("database file is truncated")
+	}
 
 	pager := &FilePager{
-		file:     f,
-		numPages: numPages,
+		file:         f,
+		numPages:     int64(header.pageCount),
+		pageSize:     int(header.pageSize),
+		maxPageCount: maxPageCount(options.MaxSize, int(header.pageSize)),
 	}
 	return pager, nil
 }
 
-func createDBFile(fileName string) error {
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0644)
+// maxPageCount is the number of pages which fit into maxSize bytes, 0 means
+// MAX_PAGE_COUNT pages.
+func maxPageCount(maxSize int64, pageSize int) int64 {
+	if maxSize > 0 && maxSize/int64(pageSize) < MAX_PAGE_COUNT {
+		return maxSize / int64(pageSize)
+	}
+	return MAX_PAGE_COUNT
+}
+
+// prepareFile creates the database file when it does not exist, and
+// upgrades it when it has an older format version. A pageSize of 0 means
+// DEFAULT_PAGE_SIZE.
+func prepareFile(fileName string, pageSize int) error {
+	if pageSize == 0 {
+		pageSize = DEFAULT_PAGE_SIZE
+	}
+	fi, err := os.Stat(fileName)
+	if os.IsNotExist(err) || (err == nil && fi.Size() == 0) {
+		err = createDBFile(fileName, pageSize)
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return upgradeFile(fileName)
+}
+
+// checkReadOnlyFile fails when the file would have to be written before it
+// can be read.
+func checkReadOnlyFile(fileName string) error {
+	version, err := readFormatVersion(fileName)
 	if err != nil {
 		return err
 	}
+	if version < FORMAT_VERSION {
+		message := fmt.Sprintf("format version %d has to be upgraded, open the database for writing once", version)
+		return errors.New(message)
+	}
+	return nil
+}
 
-	//Prepare Table Header
-	w := bufio.NewWriter(f)
-	bs := make([]byte, 2)
-	binary.LittleEndian.PutUint16(bs, uint16(PAGE_TYPE_TABLE_HEADER))
-	rootPageNum := uint32(1)
-	b := make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, rootPageNum)
-	bs = append(bs, b...)
-	bs = append(bs, make([]byte, 4096-len(bs))...)
+func readTableHeaderFromFile(f *os.File) (*TableHeader, error) {
+	bs := make([]byte, TABLE_HEADER_HEADER_SIZE)
+	_, err := f.ReadAt(bs, 0)
+	if err == io.EOF {
+		return nil, errors.New("file is not a sqlbit database")
+	} else if err != nil {
+		return nil, err
+	}
+	header, err := deserializeTableHeader(bs)
+	if err != nil {
+		return nil, err
+	}
+	err = header.validate()
+	if err != nil {
+		return nil, err
+	}
 
-	//Prepare Leaf Node
-	b = make([]byte, 2)
-	binary.LittleEndian.PutUint16(b, uint16(PAGE_TYPE_LEAF_NODE))
-	bs = append(bs, b...)
-	numTuples := uint32(0)
-	b = make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, numTuples)
-	bs = append(bs, b...)
+	bs = make([]byte, header.pageSize)
+	_, err = f.ReadAt(bs, 0)
+	if err == io.EOF {
+		return nil, ErrCorruptPage{PageID: 0}
+	} else if err != nil {
+		return nil, err
+	}
+	return header, verifyPageChecksum(0, bs)
+}
 
-	b = make([]byte, LEAF_NODE_HEADER_SIZE-(4+2))
-	bs = append(bs, b...)
+func createDBFile(fileName string, pageSize int) error {
+	bs, err := newDatabasePages(pageSize)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
 
+	w := bufio.NewWriter(f)
 	_, err = w.Write(bs)
 	if err != nil {
 		return err
@@ -81,34 +170,80 @@ func createDBFile(fileName string) error {
 	return f.Close()
 }
 
-func (p *FilePager) Read(offset int64, bs *PageBody) error {
-	_, err := p.file.Seek(offset, 0)
-	if err != nil {
-		return err
+// newDatabasePages returns the pages of an empty database, the table header
+// and an empty leaf node as the root of the b-tree.
+func newDatabasePages(pageSize int) (PageBody, error) {
+	if isValidPageSize(pageSize) == false {
+		message := fmt.Sprintf("page size must be a power of two between %d and %d", MIN_PAGE_SIZE, MAX_PAGE_SIZE)
+		return nil, errors.New(message)
 	}
 
-	_, err = p.file.Read(bs[:])
-	return err
+	//Prepare Table Header
+	header := &TableHeader{
+		formatVersion: FORMAT_VERSION,
+		pageSize:      uint32(pageSize),
+		pageCount:     2,
+		rootPageNum:   1,
+	}
+	page0 := newPageBody(pageSize)
+	copy(page0, header.Bytes())
+	stampPageChecksum(0, page0)
+
+	//Prepare Leaf Node
+	page1 := newPageBody(pageSize)
+	leafNode := &LeafNode{
+		id:     1,
+		tuples: []*Tuple{},
+		page:   &Page{id: 1, body: page1},
+	}
+	leafNode.syncBytes()
+	stampPageChecksum(1, page1)
+
+	return append(page0, page1...), nil
 }
 
-func (p *FilePager) Write(offset int64, bs *PageBody) error {
-	_, err := p.file.Seek(offset, 0)
-	if err != nil {
+// Read loads a page from the file, a page which is beyond the end of file or
+// does not match its checksum is reported as ErrCorruptPage.
+func (p *FilePager) Read(offset int64, bs PageBody) error {
+	pageID := uint32(offset / int64(p.pageSize))
+	n, err := p.file.ReadAt(bs, offset)
+	if n < len(bs) {
+		if err == nil || err == io.EOF {
+			return ErrCorruptPage{PageID: pageID}
+		}
 		return err
 	}
 
-	w := bufio.NewWriter(p.file)
-	_, err = w.Write(bs[:])
-	if err != nil {
-		return err
+	return verifyPageChecksum(pageID, bs)
+}
+
+// Write stamps the checksum of the page before writing it to the file.
+func (p *FilePager) Write(offset int64, bs PageBody) error {
+	pageID := uint32(offset / int64(p.pageSize))
+	page := newPageBody(len(bs))
+	copy(page, bs)
+	stampPageChecksum(pageID, page)
+
+	_, err := p.file.WriteAt(page, offset)
+	return err
+}
+
+// IncrementPageID reserves a page at the end of the file, it returns
+// ErrDatabaseFull once the file has maxPageCount pages.
+func (p *FilePager) IncrementPageID() (uint32, error) {
+	for {
+		numPages := atomic.LoadInt64(&p.numPages)
+		if numPages >= p.maxPageCount {
+			return 0, ErrDatabaseFull
+		}
+		if atomic.CompareAndSwapInt64(&p.numPages, numPages, numPages+1) {
+			return uint32(numPages), nil
+		}
 	}
-	return w.Flush()
 }
 
-func (p *FilePager) IncrementPageID() uint32 {
-	id := atomic.AddInt64(&p.numPages, 1)
-	// TODO: avoid id > max uint32
-	return uint32(id)
+func (p *FilePager) PageSize() int {
+	return p.pageSize
 }
 
 func (p *FilePager) Close() error {
@@ -0,0 +1,107 @@
+package core
+
+// Free pages are chained through their first bytes, the head of the chain is
+// recorded in the table header.
+const FREE_PAGE_NEXT_PAGE_ID_OFFSET = PAGE_HEADER_SIZE
+const FREE_PAGE_NEXT_PAGE_ID_SIZE = 4
+
+func readFreeListHead(tx *Transaction) (*Page, uint32, error) {
+	header, err := tx.ReadPage(uint32(0))
+	if err != nil {
+		return nil, 0, err
+	}
+	return header, readTableHeaderField(header, TABLE_HEADER_FREE_LIST_HEAD_OFFSET), nil
+}
+
+func writeFreeListHead(header *Page, pageID uint32) {
+	writeTableHeaderField(header, TABLE_HEADER_FREE_LIST_HEAD_OFFSET, pageID)
+}
+
+// allocatePage reuses a page from the free list, a new page is appended to
+// the file when the free list is empty.
+func allocatePage(tx *Transaction) (*Page, error) {
+	header, head, err := readFreeListHead(tx)
+	if err != nil {
+		return nil, err
+	}
+	if head == 0 {
+		page, err := tx.NewPage()
+		if err != nil {
+			return nil, err
+		}
+		pageCount := readTableHeaderField(header, TABLE_HEADER_PAGE_COUNT_OFFSET)
+		if page.id >= pageCount {
+			writeTableHeaderField(header, TABLE_HEADER_PAGE_COUNT_OFFSET, page.id+1)
+		}
+		return page, nil
+	}
+
+	page, err := tx.ReadPage(head)
+	if err != nil {
+		return nil, err
+	}
+	next, err := codec.decodeFreePage(head, page.body)
+	if err != nil {
+		return nil, err
+	}
+	writeFreeListHead(header, next)
+
+	resetPageBody(page.body)
+	page.MarkAsDirty()
+	return page, nil
+}
+
+// freePage pushes the page to the head of the free list.
+func freePage(tx *Transaction, pageID uint32) error {
+	header, head, err := readFreeListHead(tx)
+	if err != nil {
+		return err
+	}
+
+	page, err := tx.ReadPage(pageID)
+	if err != nil {
+		return err
+	}
+	resetPageBody(page.body)
+	codec.encodeFreePage(page.body, head)
+	page.MarkAsDirty()
+
+	writeFreeListHead(header, pageID)
+	return nil
+}
+
+// freeNewPages pushes pages appended to the database by a rolled back
+// transaction onto the free list.
+func (t *Table) freeNewPages(pageIDs []uint32) error {
+	tx := t.newTransaction()
+	for _, pageID := range pageIDs {
+		if tx.full() {
+			err := t.commit(tx)
+			if err != nil {
+				return err
+			}
+			tx = t.newTransaction()
+		}
+		err := freeNewPage(tx, pageID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return t.commit(tx)
+}
+
+// freeNewPage pushes a page appended to the database onto the free list,
+// the page count of the table header is raised to cover it since the pager
+// does not hand it out again.
+func freeNewPage(tx *Transaction, pageID uint32) error {
+	header, err := tx.ReadPage(uint32(0))
+	if err != nil {
+		return err
+	}
+	pageCount := readTableHeaderField(header, TABLE_HEADER_PAGE_COUNT_OFFSET)
+	if pageID >= pageCount {
+		writeTableHeaderField(header, TABLE_HEADER_PAGE_COUNT_OFFSET, pageID+1)
+	}
+	return freePage(tx, pageID)
+}
@@ -0,0 +1,299 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TableHeaderInfo is the decoded table header.
+type TableHeaderInfo struct {
+	FormatVersion uint32
+	PageSize      uint32
+	PageCount     uint32
+	RootPageNum   uint32
+	FreeListHead  uint32
+	ChangeCounter uint32
+	RowCount      uint32
+}
+
+// TupleInfo is a decoded leaf tuple, Username and Email only hold the inline
+// part of a username longer than COLUMN_USERNAME_LENGTH and of an email
+// longer than COLUMN_EMAIL_LENGTH.
+type TupleInfo struct {
+	Key            uint32
+	UsernameLength int
+	Username       string
+	EmailLength    int
+	Email          string
+	OverflowPageID uint32
+}
+
+// PageInfo is a page decoded by its PAGE_TYPE_*, only the fields of its type
+// are set. NextPageID is the next leaf, overflow page or free page.
+type PageInfo struct {
+	PageID     uint32
+	PageType   int
+	Header     *TableHeaderInfo
+	Keys       []uint32
+	Children   []uint32
+	Tuples     []TupleInfo
+	PrevPageID uint32
+	NextPageID uint32
+	DataSize   int
+}
+
+// PageTypeName names a PAGE_TYPE_*.
+func PageTypeName(pageType int) string {
+	switch pageType {
+	case PAGE_TYPE_TABLE_HEADER:
+		return "table header"
+	case PAGE_TYPE_INTERNAL_NODE:
+		return "internal node"
+	case PAGE_TYPE_LEAF_NODE:
+		return "leaf node"
+	case PAGE_TYPE_OVERFLOW:
+		return "overflow"
+	case PAGE_TYPE_FREE:
+		return "free"
+	default:
+		return fmt.Sprintf("unknown type %d", pageType)
+	}
+}
+
+// InspectPage decodes a page the way it is committed, which might not be
+// flushed to the file yet.
+func (t *Table) InspectPage(pageID uint32) (*PageInfo, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.inspectPage(pageID)
+}
+
+// inspectPage reads the page in a transaction of its own, so walking a large
+// tree does not pin every page.
+func (t *Table) inspectPage(pageID uint32) (*PageInfo, error) {
+	tx := t.newTransaction()
+	defer tx.Rollback()
+	if pageID != 0 {
+		header, err := tx.ReadPage(uint32(0))
+		if err != nil {
+			return nil, err
+		}
+		pageCount := readTableHeaderField(header, TABLE_HEADER_PAGE_COUNT_OFFSET)
+		if pageID >= pageCount {
+			message := fmt.Sprintf("page %d is beyond the %d pages of the database", pageID, pageCount)
+			return nil, errors.New(message)
+		}
+	}
+	page, err := tx.ReadPage(pageID)
+	if err != nil {
+		return nil, err
+	}
+	return decodePage(pageID, page)
+}
+
+func decodePage(pageID uint32, page *Page) (*PageInfo, error) {
+	if pageID == 0 {
+		header, err := deserializeTableHeader(page.body)
+		if err != nil {
+			return nil, err
+		}
+		return &PageInfo{
+			PageID:   pageID,
+			PageType: PAGE_TYPE_TABLE_HEADER,
+			Header: &TableHeaderInfo{
+				FormatVersion: header.formatVersion,
+				PageSize:      header.pageSize,
+				PageCount:     header.pageCount,
+				RootPageNum:   header.rootPageNum,
+				FreeListHead:  header.freeListHead,
+				ChangeCounter: header.changeCounter,
+				RowCount:      header.rowCount,
+			},
+		}, nil
+	}
+
+	info := &PageInfo{
+		PageID:   pageID,
+		PageType: codec.pageType(page.body),
+	}
+	switch info.PageType {
+	case PAGE_TYPE_TABLE_HEADER:
+		// only the first page is a table header
+		return nil, ErrCorruptPage{PageID: pageID}
+	case PAGE_TYPE_INTERNAL_NODE:
+		node, err := deserializeInternalNodeFromPage(pageID, page)
+		if err != nil {
+			return nil, err
+		}
+		info.Keys = node.keys
+		info.Children = node.children
+	case PAGE_TYPE_LEAF_NODE:
+		node, err := deserializeLeafNodeFromPage(pageID, page)
+		if err != nil {
+			return nil, err
+		}
+		info.PrevPageID = node.prevNodeID
+		info.NextPageID = node.nextNodeID
+		for _, tuple := range node.tuples {
+			tupleInfo, err := decodeTuple(pageID, tuple)
+			if err != nil {
+				return nil, err
+			}
+			info.Keys = append(info.Keys, tuple.key)
+			info.Tuples = append(info.Tuples, tupleInfo)
+		}
+	case PAGE_TYPE_OVERFLOW:
+		next, data, err := codec.decodeOverflowPage(pageID, page.body)
+		if err != nil {
+			return nil, err
+		}
+		info.NextPageID = next
+		info.DataSize = len(data)
+	case PAGE_TYPE_FREE:
+		next, err := codec.decodeFreePage(pageID, page.body)
+		if err != nil {
+			return nil, err
+		}
+		info.NextPageID = next
+	}
+	return info, nil
+}
+
+func decodeTuple(pageID uint32, tuple *Tuple) (TupleInfo, error) {
+	row, overflow, err := codec.decodeRow(tuple.value)
+	if err != nil {
+		message := fmt.Sprintf("page %d: %s", pageID, err)
+		return TupleInfo{}, errors.New(message)
+	}
+	return TupleInfo{
+		Key:            tuple.key,
+		UsernameLength: overflow.usernameLength,
+		Username:       row.username,
+		EmailLength:    overflow.emailLength,
+		Email:          row.email,
+		OverflowPageID: overflow.pageID,
+	}, nil
+}
+
+// String prints the fields of the page, one per line.
+func (p *PageInfo) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "page %d: %s\n", p.PageID, PageTypeName(p.PageType))
+	switch p.PageType {
+	case PAGE_TYPE_TABLE_HEADER:
+		h := p.Header
+		fmt.Fprintf(&b, "  format version: %d\n", h.FormatVersion)
+		fmt.Fprintf(&b, "  page size: %d\n", h.PageSize)
+		fmt.Fprintf(&b, "  page count: %d\n", h.PageCount)
+		fmt.Fprintf(&b, "  root page: %d\n", h.RootPageNum)
+		fmt.Fprintf(&b, "  free list head: %d\n", h.FreeListHead)
+		fmt.Fprintf(&b, "  change counter: %d\n", h.ChangeCounter)
+		fmt.Fprintf(&b, "  row count: %d\n", h.RowCount)
+	case PAGE_TYPE_INTERNAL_NODE:
+		fmt.Fprintf(&b, "  keys: %s\n", joinUint32(p.Keys))
+		fmt.Fprintf(&b, "  children: %s\n", joinUint32(p.Children))
+	case PAGE_TYPE_LEAF_NODE:
+		fmt.Fprintf(&b, "  prev leaf: %d\n", p.PrevPageID)
+		fmt.Fprintf(&b, "  next leaf: %d\n", p.NextPageID)
+		fmt.Fprintf(&b, "  tuples: %d\n", len(p.Tuples))
+		for _, tuple := range p.Tuples {
+			fmt.Fprintf(&b, "    %d: username %q, email %q", tuple.Key, tuple.Username, tuple.Email)
+			if tuple.OverflowPageID != 0 {
+				fmt.Fprintf(&b, ", %d and %d bytes with overflow page %d", tuple.UsernameLength, tuple.EmailLength, tuple.OverflowPageID)
+			}
+			fmt.Fprintln(&b)
+		}
+	case PAGE_TYPE_OVERFLOW:
+		fmt.Fprintf(&b, "  next page: %d\n", p.NextPageID)
+		fmt.Fprintf(&b, "  data size: %d\n", p.DataSize)
+	case PAGE_TYPE_FREE:
+		fmt.Fprintf(&b, "  next free page: %d\n", p.NextPageID)
+	}
+	return b.String()
+}
+
+func joinUint32(values []uint32) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		texts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(texts, " ")
+}
+
+// BTreeDOT renders the b-tree as a Graphviz graph. Solid edges lead to the
+// children, dashed ones to the next leaf and dotted ones through the
+// overflow chains.
+func (t *Table) BTreeDOT() (string, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "digraph btree {")
+	fmt.Fprintln(&b, "  node [shape=record];")
+	// visited stops at the pages which are reached twice in a corrupted tree
+	visited := map[uint32]bool{}
+	pageIDs := []uint32{t.btree.rootNodeID}
+	for len(pageIDs) > 0 {
+		pageID := pageIDs[0]
+		pageIDs = pageIDs[1:]
+		if visited[pageID] {
+			continue
+		}
+		visited[pageID] = true
+		info, err := t.inspectPage(pageID)
+		if err != nil {
+			return "", err
+		}
+
+		switch info.PageType {
+		case PAGE_TYPE_INTERNAL_NODE:
+			fmt.Fprintf(&b, "  page%d [label=\"{internal %d|%s}\"];\n", info.PageID, info.PageID, joinUint32(info.Keys))
+			for _, child := range info.Children {
+				fmt.Fprintf(&b, "  page%d -> page%d;\n", info.PageID, child)
+			}
+			pageIDs = append(pageIDs, info.Children...)
+		case PAGE_TYPE_LEAF_NODE:
+			fmt.Fprintf(&b, "  page%d [label=\"{leaf %d|%s}\"];\n", info.PageID, info.PageID, joinUint32(info.Keys))
+			if info.NextPageID != 0 {
+				fmt.Fprintf(&b, "  page%d -> page%d [style=dashed, constraint=false];\n", info.PageID, info.NextPageID)
+			}
+			for _, tuple := range info.Tuples {
+				if tuple.OverflowPageID != 0 {
+					err = t.writeOverflowDOT(&b, visited, info.PageID, tuple.OverflowPageID)
+					if err != nil {
+						return "", err
+					}
+				}
+			}
+		default:
+			message := fmt.Sprintf("page %d is a %s page, not a node", info.PageID, PageTypeName(info.PageType))
+			return "", errors.New(message)
+		}
+	}
+	fmt.Fprintln(&b, "}")
+	return b.String(), nil
+}
+
+func (t *Table) writeOverflowDOT(b *strings.Builder, visited map[uint32]bool, fromPageID uint32, pageID uint32) error {
+	for pageID != 0 {
+		if visited[pageID] {
+			fmt.Fprintf(b, "  page%d -> page%d [style=dotted];\n", fromPageID, pageID)
+			return nil
+		}
+		visited[pageID] = true
+		info, err := t.inspectPage(pageID)
+		if err != nil {
+			return err
+		}
+		if info.PageType != PAGE_TYPE_OVERFLOW {
+			message := fmt.Sprintf("page %d is a %s page, not an overflow page", pageID, PageTypeName(info.PageType))
+			return errors.New(message)
+		}
+		fmt.Fprintf(b, "  page%d [shape=box, label=\"overflow %d\"];\n", pageID, pageID)
+		fmt.Fprintf(b, "  page%d -> page%d [style=dotted];\n", fromPageID, pageID)
+		fromPageID = pageID
+		pageID = info.NextPageID
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableInspectPage(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 20; i++ {
+		email := "user@test.com"
+		if i >= 19 {
+			email = strings.Repeat("e", DEFAULT_PAGE_SIZE)
+		}
+		table.InsertRow(NewRow(uint32(i), "user", email))
+	}
+	table.DeleteRow(19)
+
+	header, err := table.InspectPage(0)
+	assert.Nil(t, err)
+	assert.Equal(t, PAGE_TYPE_TABLE_HEADER, header.PageType)
+	assert.Equal(t, uint32(19), header.Header.RowCount)
+	assert.Contains(t, header.String(), "page 0: table header\n  format version: 3\n  page size: 4096\n")
+
+	root, err := table.InspectPage(header.Header.RootPageNum)
+	assert.Nil(t, err)
+	assert.Equal(t, PAGE_TYPE_INTERNAL_NODE, root.PageType)
+	assert.Equal(t, len(root.Keys)+1, len(root.Children))
+
+	last, err := table.InspectPage(root.Children[len(root.Children)-1])
+	assert.Nil(t, err)
+	assert.Equal(t, PAGE_TYPE_LEAF_NODE, last.PageType)
+	assert.Equal(t, uint32(0), last.NextPageID)
+	assert.NotEqual(t, uint32(0), last.PrevPageID)
+	tuple := last.Tuples[len(last.Tuples)-1]
+	assert.Equal(t, TupleInfo{Key: 20, UsernameLength: 4, Username: "user", EmailLength: DEFAULT_PAGE_SIZE, Email: strings.Repeat("e", COLUMN_EMAIL_LENGTH), OverflowPageID: tuple.OverflowPageID}, tuple)
+	assert.Contains(t, last.String(), "    18: username \"user\", email \"user@test.com\"\n")
+
+	overflow, err := table.InspectPage(tuple.OverflowPageID)
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprintf("page %d: overflow\n  next page: 0\n  data size: 3841\n", tuple.OverflowPageID), overflow.String())
+	free, err := table.InspectPage(header.Header.FreeListHead)
+	assert.Nil(t, err)
+	assert.Equal(t, PAGE_TYPE_FREE, free.PageType)
+
+	_, err = table.InspectPage(header.Header.PageCount)
+	assert.Contains(t, err.Error(), "is beyond the")
+}
+
+func TestTableBTreeDOT(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 14; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+
+	dot, err := table.BTreeDOT()
+
+	assert.Nil(t, err)
+	assert.Equal(t, `digraph btree {
+  node [shape=record];
+  page3 [label="{internal 3|8}"];
+  page3 -> page1;
+  page3 -> page2;
+  page1 [label="{leaf 1|1 2 3 4 5 6 7}"];
+  page1 -> page2 [style=dashed, constraint=false];
+  page2 [label="{leaf 2|8 9 10 11 12 13 14}"];
+}
+`, dot)
+}
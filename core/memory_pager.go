@@ -0,0 +1,117 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// MEMORY_FILE_NAME opens a database which lives in memory instead of a file,
+// it is empty when it is opened and gone once it is closed. Backup copies it
+// into a file.
+const MEMORY_FILE_NAME = ":memory:"
+
+var ErrMemoryDatabaseClosed = errors.New("in-memory database is closed")
+
+// MemoryPager keeps the pages of a database in memory, a page is stored with
+// its checksum like in a database file so the buffer pool reads it the same
+// way.
+type MemoryPager struct {
+	mu           sync.RWMutex
+	pages        []PageBody
+	pageSize     int
+	maxPageCount int64
+	closed       bool
+}
+
+// NewMemoryPager creates an empty database in memory, options.PageSize 0
+// means DEFAULT_PAGE_SIZE and options.MaxSize limits its size in bytes like
+// the size of a database file.
+func NewMemoryPager(options *TableOptions) (*MemoryPager, error) {
+	if options.ReadOnly {
+		return nil, errors.New("an in-memory database can not be read only")
+	}
+	pageSize := options.PageSize
+	if pageSize == 0 {
+		pageSize = DEFAULT_PAGE_SIZE
+	}
+	bs, err := newDatabasePages(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	pager := &MemoryPager{
+		pages:        []PageBody{},
+		pageSize:     pageSize,
+		maxPageCount: maxPageCount(options.MaxSize, pageSize),
+	}
+	for offset := 0; offset < len(bs); offset += pageSize {
+		pager.pages = append(pager.pages, bs[offset:offset+pageSize])
+	}
+	return pager, nil
+}
+
+// Read copies a page into bs, a page which was reserved but never written is
+// reported as ErrCorruptPage like a page beyond the end of a database file.
+func (p *MemoryPager) Read(offset int64, bs PageBody) error {
+	pageID := uint32(offset / int64(p.pageSize))
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrMemoryDatabaseClosed
+	}
+	if int(pageID) >= len(p.pages) || p.pages[pageID] == nil {
+		return ErrCorruptPage{PageID: pageID}
+	}
+	copy(bs, p.pages[pageID])
+	return verifyPageChecksum(pageID, bs)
+}
+
+// Write stamps the checksum of the page before storing a copy of it.
+func (p *MemoryPager) Write(offset int64, bs PageBody) error {
+	pageID := uint32(offset / int64(p.pageSize))
+	page := newPageBody(len(bs))
+	copy(page, bs)
+	stampPageChecksum(pageID, page)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrMemoryDatabaseClosed
+	}
+	for int(pageID) >= len(p.pages) {
+		p.pages = append(p.pages, nil)
+	}
+	p.pages[pageID] = page
+	return nil
+}
+
+// IncrementPageID reserves a page after the last one, it returns
+// ErrDatabaseFull once the database has maxPageCount pages.
+func (p *MemoryPager) IncrementPageID() (uint32, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, ErrMemoryDatabaseClosed
+	}
+	numPages := int64(len(p.pages))
+	if numPages >= p.maxPageCount {
+		return 0, ErrDatabaseFull
+	}
+	p.pages = append(p.pages, nil)
+	return uint32(numPages), nil
+}
+
+func (p *MemoryPager) PageSize() int {
+	return p.pageSize
+}
+
+// Close releases the pages, the database can not be read anymore.
+func (p *MemoryPager) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pages = nil
+	p.closed = true
+	return nil
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMemoryTable(t *testing.T) {
+	table, err := OpenTableWithOptions(MEMORY_FILE_NAME, &TableOptions{
+		PageSize:       MIN_PAGE_SIZE,
+		BufferPoolSize: 2 * MIN_BUFFER_POOL_SIZE,
+	})
+	assert.Nil(t, err)
+
+	// more pages than the buffer pool holds, so pages are evicted into the
+	// pager and read back
+	for i := 1; i <= 300; i++ {
+		email := "user@test.com"
+		if i%50 == 0 {
+			email = strings.Repeat("e", 2*MIN_PAGE_SIZE)
+		}
+		err = table.InsertRow(NewRow(uint32(i), "user", email))
+		assert.Nil(t, err)
+	}
+	for i := 1; i <= 300; i += 3 {
+		table.DeleteRow(uint32(i))
+	}
+
+	assert.Equal(t, 200, table.NumRows())
+	rows := drainRowIterator(t, table.Scan(nil, nil))
+	assert.Equal(t, 200, len(rows))
+	assert.Equal(t, uint32(2), rows[0].Id())
+	for _, row := range rows {
+		if row.Id()%50 == 0 {
+			assert.Equal(t, 2*MIN_PAGE_SIZE, len(row.Email()))
+		}
+	}
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	stats, _ := table.Stats()
+	assert.True(t, stats.PageCount > uint32(stats.BufferPool.MaxFrames))
+	_, err = os.Stat(MEMORY_FILE_NAME)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, table.CloseTable())
+	table, _ = OpenTable(MEMORY_FILE_NAME)
+	defer table.CloseTable()
+	assert.Equal(t, 0, table.NumRows())
+}
+
+func TestOpenMemoryTableWithOptions(t *testing.T) {
+	_, err := OpenTableWithOptions(MEMORY_FILE_NAME, &TableOptions{ReadOnly: true})
+	assert.Equal(t, "an in-memory database can not be read only", err.Error())
+	_, err = OpenTableWithOptions(MEMORY_FILE_NAME, &TableOptions{PageSize: 1000})
+	assert.Equal(t, "page size must be a power of two between 1024 and 65536", err.Error())
+
+	table, _ := OpenTableWithOptions(MEMORY_FILE_NAME, &TableOptions{
+		PageSize: DEFAULT_PAGE_SIZE,
+		MaxSize:  4 * DEFAULT_PAGE_SIZE,
+	})
+	defer table.CloseTable()
+	err = nil
+	for i := 1; i <= 100 && err == nil; i++ {
+		err = table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	assert.Equal(t, "statement is rolled back: database is full", err.Error())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestMemoryTableVacuum(t *testing.T) {
+	table, _ := OpenTable(MEMORY_FILE_NAME)
+	defer table.CloseTable()
+	for i := 1; i <= 600; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	for i := 1; i <= 600; i++ {
+		if i%10 != 0 {
+			table.DeleteRow(uint32(i))
+		}
+	}
+	rows := drainRowIterator(t, table.Scan(nil, nil))
+	before, _ := table.Stats()
+
+	err := table.Vacuum()
+
+	assert.Nil(t, err)
+	after, _ := table.Stats()
+	assert.True(t, after.PageCount < before.PageCount/3)
+	assert.Equal(t, before.ChangeCounter+1, after.ChangeCounter)
+	assert.Equal(t, rows, drainRowIterator(t, table.Scan(nil, nil)))
+	assert.Nil(t, table.InsertRow(NewRow(1, "user", "user@test.com")))
+	assert.Equal(t, 61, table.NumRows())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	_, err = os.Stat(MEMORY_FILE_NAME + VACUUM_FILE_SUFFIX)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMemoryTableBackup(t *testing.T) {
+	removeTestFile()
+	defer os.Remove(getTestBackupFileName())
+	table, _ := OpenTable(MEMORY_FILE_NAME)
+	defer table.CloseTable()
+	for i := 1; i <= 300; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+
+	err := table.Backup(getTestBackupFileName())
+
+	assert.Nil(t, err)
+	rows := checkBackup(t, 300)
+	assert.Equal(t, drainRowIterator(t, table.Scan(nil, nil)), rows)
+	_, err = table.NewBackup(MEMORY_FILE_NAME)
+	assert.Equal(t, "can not back up into an in-memory database", err.Error())
+}
+
+func TestMemoryPagerClose(t *testing.T) {
+	pager, _ := NewMemoryPager(DefaultTableOptions())
+	page := newPageBody(DEFAULT_PAGE_SIZE)
+	pageID, _ := pager.IncrementPageID()
+	assert.Equal(t, uint32(2), pageID)
+	assert.Equal(t, ErrCorruptPage{PageID: 2}, pager.Read(2*DEFAULT_PAGE_SIZE, page))
+
+	pager.Close()
+
+	assert.Equal(t, ErrMemoryDatabaseClosed, pager.Read(0, page))
+	assert.Equal(t, ErrMemoryDatabaseClosed, pager.Write(0, page))
+}
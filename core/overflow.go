@@ -0,0 +1,102 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Overflow Page
+// PAGE_TYPE(2 bytes), CHECKSUM(4 bytes), NEXT_PAGE_ID(4 bytes), DATA_SIZE(4 bytes), DATA
+const OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET = PAGE_HEADER_SIZE
+const OVERFLOW_PAGE_NEXT_PAGE_ID_SIZE = 4
+const OVERFLOW_PAGE_DATA_SIZE_OFFSET = OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET + OVERFLOW_PAGE_NEXT_PAGE_ID_SIZE
+const OVERFLOW_PAGE_DATA_SIZE_SIZE = 4
+const OVERFLOW_PAGE_HEADER_SIZE = OVERFLOW_PAGE_DATA_SIZE_OFFSET + OVERFLOW_PAGE_DATA_SIZE_SIZE
+
+func overflowPageCapacity(pageSize int) int {
+	return pageSize - OVERFLOW_PAGE_HEADER_SIZE
+}
+
+// writeOverflow stores data into a chain of overflow pages and returns the id
+// of the first page.
+func writeOverflow(tx *Transaction, data []byte) (uint32, error) {
+	firstPageID := uint32(0)
+	var prevPage *Page
+	var prevData []byte
+	for len(data) > 0 {
+		page, err := allocatePage(tx)
+		if err != nil {
+			return 0, err
+		}
+
+		size := len(data)
+		capacity := overflowPageCapacity(len(page.body))
+		if size > capacity {
+			size = capacity
+		}
+		codec.encodeOverflowPage(page.body, 0, data[:size])
+		page.MarkAsDirty()
+
+		if prevPage == nil {
+			firstPageID = page.id
+		} else {
+			codec.encodeOverflowPage(prevPage.body, page.id, prevData)
+		}
+		prevPage = page
+		prevData = data[:size]
+		data = data[size:]
+	}
+	return firstPageID, nil
+}
+
+func readOverflowPage(tx *Transaction, pageID uint32) (uint32, []byte, error) {
+	page, err := tx.ReadPage(pageID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return codec.decodeOverflowPage(pageID, page.body)
+}
+
+// readOverflow follows the overflow chain from pageID and returns size bytes.
+// The size comes from the tuple, so the bytes are collected page by page
+// and a chain which loops is rejected, a corrupt size can not allocate more
+// than the chain holds.
+func readOverflow(tx *Transaction, pageID uint32, size int) ([]byte, error) {
+	bs := []byte{}
+	visited := make(map[uint32]bool)
+	for pageID != 0 && len(bs) < size {
+		if visited[pageID] {
+			message := fmt.Sprintf("overflow chain visits page %d twice", pageID)
+			return nil, errors.New(message)
+		}
+		visited[pageID] = true
+		next, data, err := readOverflowPage(tx, pageID)
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, data...)
+		pageID = next
+	}
+
+	if len(bs) != size {
+		message := fmt.Sprintf("overflow chain has %d bytes, expected %d", len(bs), size)
+		return nil, errors.New(message)
+	}
+	return bs, nil
+}
+
+// freeOverflow releases every page of the overflow chain starting from pageID.
+func freeOverflow(tx *Transaction, pageID uint32) error {
+	for pageID != 0 {
+		next, _, err := readOverflowPage(tx, pageID)
+		if err != nil {
+			return err
+		}
+		err = freePage(tx, pageID)
+		if err != nil {
+			return err
+		}
+		pageID = next
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func prepareOverflowTransaction() (*Transaction, *BufferPool) {
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
+	pager := &DummyPager{body: page0[:]}
+	replacer := NewDummyReplacer()
+	bufferPool := NewBufferPool(replacer, pager, 5, 100)
+	return NewTransaction(1, bufferPool), bufferPool
+}
+
+func TestWriteOverflow(t *testing.T) {
+	tx, _ := prepareOverflowTransaction()
+	data := []byte(strings.Repeat("x", overflowPageCapacity(DEFAULT_PAGE_SIZE)*2+10))
+
+	pageID, err := writeOverflow(tx, data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), pageID)
+	bs, err := readOverflow(tx, pageID, len(data))
+	assert.Nil(t, err)
+	assert.Equal(t, data, bs)
+}
+
+func TestReadOverflowWithWrongPageType(t *testing.T) {
+	tx, _ := prepareOverflowTransaction()
+	page, _ := tx.NewPage()
+
+	_, err := readOverflow(tx, page.id, 10)
+
+	assert.Equal(t, "page 1 is not an overflow page", err.Error())
+}
+
+func TestFreeOverflow(t *testing.T) {
+	tx, _ := prepareOverflowTransaction()
+	data := []byte(strings.Repeat("x", overflowPageCapacity(DEFAULT_PAGE_SIZE)+10))
+	pageID, _ := writeOverflow(tx, data)
+
+	err := freeOverflow(tx, pageID)
+
+	assert.Nil(t, err)
+	_, head, _ := readFreeListHead(tx)
+	assert.Equal(t, uint32(2), head)
+	page, _ := allocatePage(tx)
+	assert.Equal(t, uint32(2), page.id)
+	assert.Equal(t, newPageBody(DEFAULT_PAGE_SIZE), page.body)
+	page, _ = allocatePage(tx)
+	assert.Equal(t, uint32(1), page.id)
+	page, _ = allocatePage(tx)
+	assert.Equal(t, uint32(3), page.id)
+}
+
+func TestReadOverflowWithCorruptSize(t *testing.T) {
+	tx, _ := prepareOverflowTransaction()
+	data := []byte(strings.Repeat("x", overflowPageCapacity(DEFAULT_PAGE_SIZE)+10))
+	pageID, _ := writeOverflow(tx, data)
+
+	_, err := readOverflow(tx, pageID, 1<<31)
+	assert.Equal(t, fmt.Sprintf("overflow chain has %d bytes, expected %d", len(data), 1<<31), err.Error())
+
+	// the last page links back to the first one
+	page, _ := tx.ReadPage(pageID + 1)
+	_, tail, _ := codec.decodeOverflowPage(pageID+1, page.body)
+	codec.encodeOverflowPage(page.body, pageID, tail)
+	_, err = readOverflow(tx, pageID, 1<<31)
+	assert.Equal(t, fmt.Sprintf("overflow chain visits page %d twice", pageID), err.Error())
+}
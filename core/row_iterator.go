@@ -0,0 +1,176 @@
+package core
+
+// SCAN_BATCH_SIZE is the number of rows a RowIterator visits at once, the
+// rows rejected by the filter are counted too.
+const SCAN_BATCH_SIZE = 64
+
+// batchScanner reads the rows after a key, Table and TableTransaction
+// implement it.
+type batchScanner interface {
+	scanBatch(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error)
+}
+
+// scanBatch is a batch of rows, lastKey is the last key visited by the
+// cursor which might belong to a row rejected by the filter.
+type scanBatch struct {
+	rows    []*Row
+	lastKey uint32
+	done    bool
+}
+
+// RowIterator streams the rows of a scan. Outside of a transaction the table
+// lock is only held while a batch is read, so writers are not blocked by a
+// slow reader and the rows committed between two batches might be seen.
+type RowIterator struct {
+	scanner        batchScanner
+	indexCondition *IndexCondition
+	filter         Filter
+	batch          []*Row
+	lastKey        *uint32
+	done           bool
+}
+
+// Scan returns an iterator over the rows passing filter, indexCondition can
+// be nil.
+func (t *Table) Scan(indexCondition *IndexCondition, filter Filter) *RowIterator {
+	return &RowIterator{scanner: t, indexCondition: indexCondition, filter: filter}
+}
+
+// Scan returns an iterator over the rows passing filter which reads through
+// the transaction, it must not be used after the transaction ends.
+func (tt *TableTransaction) Scan(indexCondition *IndexCondition, filter Filter) *RowIterator {
+	return &RowIterator{scanner: tt, indexCondition: indexCondition, filter: filter}
+}
+
+// ScanLocked calls fn with an iterator over the rows passing filter, the
+// table lock is held until fn returns so every row comes from the same
+// commit. Commits wait meanwhile, fn must not call other methods of the
+// table.
+func (t *Table) ScanLocked(indexCondition *IndexCondition, filter Filter, fn func(rows *RowIterator) error) error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return fn(&RowIterator{scanner: lockedTable{table: t}, indexCondition: indexCondition, filter: filter})
+}
+
+// lockedTable reads the batches of a table whose lock is held by ScanLocked.
+type lockedTable struct {
+	table *Table
+}
+
+func (l lockedTable) scanBatch(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	return l.table.scanBatchLocked(indexCondition, after, filter)
+}
+
+// Next returns the next row, or nil at the end of the scan.
+func (it *RowIterator) Next() (*Row, error) {
+	for len(it.batch) == 0 {
+		if it.done {
+			return nil, nil
+		}
+		batch, err := it.scanner.scanBatch(it.indexCondition, it.lastKey, it.filter)
+		if err != nil {
+			it.done = true
+			return nil, err
+		}
+		it.batch = batch.rows
+		it.lastKey = &batch.lastKey
+		it.done = batch.done
+	}
+
+	row := it.batch[0]
+	it.batch = it.batch[1:]
+	return row, nil
+}
+
+func (t *Table) scanBatch(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.scanBatchLocked(indexCondition, after, filter)
+}
+
+// scanBatchLocked must be called with the table lock held.
+func (t *Table) scanBatchLocked(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	tx := t.newTransaction()
+	defer tx.Rollback()
+	return readBatch(t.btree, tx, indexCondition, after, filter)
+}
+
+// scanBatch reads the committed rows until the transaction writes, and its
+// own b-tree afterwards.
+func (tt *TableTransaction) scanBatch(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	err := tt.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	if tt.btree == nil {
+		return tt.table.scanBatch(indexCondition, after, filter)
+	}
+	return readBatch(tt.btree, tt.tx, indexCondition, after, filter)
+}
+
+// newCursorAfter creates a cursor at the first row after the key, or at the
+// first row of the scan when after is nil.
+func newCursorAfter(btree *BTree, tx *Transaction, indexCondition *IndexCondition, after *uint32) (*Cursor, error) {
+	if after == nil {
+		if indexCondition == nil {
+			return newCursorFromStart(btree, tx)
+		}
+		return newCursorForIndexScan(btree, tx, indexCondition)
+	}
+
+	c, err := newCursorForIndexScan(btree, tx, &IndexCondition{
+		ColumnName: "id",
+		Target:     *after,
+		Operator:   ">",
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.indexCond = indexCondition
+	err = c.checkCondition()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readBatch visits up to SCAN_BATCH_SIZE rows after the key and keeps the
+// ones passing filter. The batch ends early once the transaction pins half
+// of the buffer pool, the leaves emptied by deletes are not merged so a
+// batch might walk through many of them.
+func readBatch(btree *BTree, tx *Transaction, indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	c, err := newCursorAfter(btree, tx, indexCondition, after)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &scanBatch{rows: []*Row{}}
+	for visited := 0; c.endOfTable == false && visited < SCAN_BATCH_SIZE; visited++ {
+		if visited > 0 && tx.full() {
+			break
+		}
+		row, err := c.value()
+		if err != nil {
+			return nil, err
+		}
+		batch.lastKey = row.Id()
+
+		pass := true
+		if filter != nil {
+			pass, err = filter.Test(row)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if pass {
+			batch.rows = append(batch.rows, row)
+		}
+
+		err = c.advance()
+		if err != nil {
+			return nil, err
+		}
+	}
+	batch.done = c.endOfTable
+	return batch, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rowIDs(rows []*Row) []uint32 {
+	ids := []uint32{}
+	for _, row := range rows {
+		ids = append(ids, row.Id())
+	}
+	return ids
+}
+
+func drainRowIterator(t *testing.T, it *RowIterator) []*Row {
+	rows := []*Row{}
+	for {
+		row, err := it.Next()
+		assert.Nil(t, err)
+		if row == nil {
+			return rows
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestRowIteratorMatchesScans(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 300; i++ {
+		table.InsertRow(NewRow(uint32(i), fmt.Sprintf("user%d", i%7), "user@test.com"))
+	}
+	filter, _ := NewStringFilter("username", "user3", "=")
+
+	expected, _ := table.SeqScan(nil)
+	assert.Equal(t, expected, drainRowIterator(t, table.Scan(nil, nil)))
+	expected, _ = table.SeqScan(filter)
+	assert.Equal(t, expected, drainRowIterator(t, table.Scan(nil, filter)))
+	for _, operator := range []string{"<", "<=", "=", ">=", ">"} {
+		for _, target := range []uint32{0, 1, 100, 200, 300, 301} {
+			condition := &IndexCondition{ColumnName: "id", Target: target, Operator: operator}
+			expected, _ = table.IndexScan(condition, nil)
+			assert.Equal(t, expectedIndexScan(table, condition), rowIDs(expected))
+			message := fmt.Sprintf("id %s %d", operator, target)
+			assert.Equal(t, rowIDs(expected), rowIDs(drainRowIterator(t, table.Scan(condition, nil))), message)
+		}
+	}
+}
+
+// expectedIndexScan returns the ids of the rows matching condition.
+func expectedIndexScan(table *Table, condition *IndexCondition) []uint32 {
+	ids := []uint32{}
+	rows, _ := table.SeqScan(nil)
+	for _, row := range rows {
+		shouldEnd, _ := condition.ShouldEnd(row.Id())
+		if shouldEnd == false {
+			ids = append(ids, row.Id())
+		}
+	}
+	return ids
+}
+
+func TestRowIteratorDoesNotBlockWriters(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= SCAN_BATCH_SIZE+1; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	it := table.Scan(nil, nil)
+	row, _ := it.Next()
+	assert.Equal(t, uint32(1), row.Id())
+
+	assert.Nil(t, table.InsertRow(NewRow(1000, "user", "user@test.com")))
+	rows := drainRowIterator(t, it)
+
+	assert.Equal(t, uint32(1000), rows[len(rows)-1].Id())
+}
+
+func TestTransactionRowIterator(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	tx := table.Begin()
+	tx.InsertRow(NewRow(1, "user", "user@test.com"))
+
+	rows := drainRowIterator(t, tx.Scan(nil, nil))
+	tx.Rollback()
+
+	assert.Equal(t, 1, len(rows))
+	_, err := tx.Scan(nil, nil).Next()
+	assert.Equal(t, "transaction is already closed", err.Error())
+}
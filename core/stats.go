@@ -0,0 +1,71 @@
+package core
+
+// TableStats describes the database file and the buffer pool of a table.
+type TableStats struct {
+	NumRows       int
+	PageSize      int
+	PageCount     uint32
+	RootPageNum   uint32
+	ChangeCounter uint32
+	BufferPool    BufferPoolStats
+}
+
+// Stats reads the table header as of the last commit.
+func (t *Table) Stats() (*TableStats, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	header, err := readTableHeader(t.bufferPool)
+	if err != nil {
+		return nil, err
+	}
+	return &TableStats{
+		NumRows:       t.numRows,
+		PageSize:      int(header.pageSize),
+		PageCount:     header.pageCount,
+		RootPageNum:   header.rootPageNum,
+		ChangeCounter: header.changeCounter,
+		BufferPool:    t.bufferPool.Stats(),
+	}, nil
+}
+
+// PageSize returns the page size of the database file.
+func (t *Table) PageSize() int {
+	return t.bufferPool.PageSize()
+}
+
+// BTreeString prints the nodes of the b-tree level by level, one node per
+// line.
+func (t *Table) BTreeString() (string, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	tx := t.newTransaction()
+	defer tx.Rollback()
+	return t.btree.String(&TransactionNoder{transaction: tx})
+}
+
+// Constant is a size or an offset of the on disk layout.
+type Constant struct {
+	Name  string
+	Value int
+}
+
+// Constants returns the layout of rows and pages for a page size.
+func Constants(pageSize int) []Constant {
+	return []Constant{
+		{"ROW_SIZE", ROW_SIZE},
+		{"COLUMN_USERNAME_LENGTH", COLUMN_USERNAME_LENGTH},
+		{"COLUMN_EMAIL_LENGTH", COLUMN_EMAIL_LENGTH},
+		{"PAGE_SIZE", pageSize},
+		{"PAGE_HEADER_SIZE", PAGE_HEADER_SIZE},
+		{"TABLE_HEADER_HEADER_SIZE", TABLE_HEADER_HEADER_SIZE},
+		{"INTERNAL_NODE_HEADER_SIZE", INTERNAL_NODE_HEADER_SIZE},
+		{"INTERNAL_NODE_MAX_KEYS", internalNodeKeyPerPage(pageSize)},
+		{"LEAF_NODE_HEADER_SIZE", LEAF_NODE_HEADER_SIZE},
+		{"LEAF_NODE_CELL_SIZE", LEAF_NODE_CHILD_SIZE},
+		{"LEAF_NODE_MAX_CELLS", leafNodeKeyPerPage(pageSize)},
+		{"OVERFLOW_PAGE_HEADER_SIZE", OVERFLOW_PAGE_HEADER_SIZE},
+		{"OVERFLOW_PAGE_CAPACITY", overflowPageCapacity(pageSize)},
+	}
+}
@@ -1,14 +1,19 @@
 package core
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// COLUMN_USERNAME_LENGTH is the number of username bytes kept inline in the
+// leaf tuple, the rest of a longer username is spilled into overflow pages.
 const COLUMN_USERNAME_LENGTH = 32
+
+// COLUMN_EMAIL_LENGTH is the number of email bytes kept inline in the leaf
+// tuple, the rest of a longer email is spilled into overflow pages.
 const COLUMN_EMAIL_LENGTH = 255
 
 type Row struct {
@@ -26,18 +31,7 @@ func NewRow(id uint32, username string, email string) *Row {
 }
 
 func (r *Row) Bytes() []byte {
-	bs := make([]byte, 4)
-	binary.LittleEndian.PutUint32(bs, r.id)
-
-	bs2 := make([]byte, COLUMN_USERNAME_LENGTH)
-	copy(bs2[:], r.username)
-	bs = append(bs, bs2...)
-
-	bs3 := make([]byte, COLUMN_EMAIL_LENGTH)
-	copy(bs3[:], r.email)
-	bs = append(bs, bs3...)
-
-	return bs
+	return codec.encodeRow(r, 0)
 }
 
 func (r *Row) Id() uint32 {
@@ -66,74 +60,156 @@ const PAGE_TYPE_SIZE = 2
 const PAGE_TYPE_TABLE_HEADER = 0
 const PAGE_TYPE_INTERNAL_NODE = 1
 const PAGE_TYPE_LEAF_NODE = 2
-
-const PAGE_SIZE = 4096
-
-// 4 + 32 + 255
-const ROW_SIZE = 291
-const ROW_PER_PAGE = PAGE_SIZE / ROW_SIZE
-
-const TABLE_MAX_PAGES = 100
-const TABLE_MAX_ROWS = TABLE_MAX_PAGES * ROW_PER_PAGE
+const PAGE_TYPE_OVERFLOW = 3
+const PAGE_TYPE_FREE = 4
+
+// The page size is chosen when a database file is created and recorded in
+// its table header, it is a power of two between MIN_PAGE_SIZE and
+// MAX_PAGE_SIZE.
+const DEFAULT_PAGE_SIZE = 4096
+const MIN_PAGE_SIZE = 1024
+const MAX_PAGE_SIZE = 65536
+
+const ROW_ID_OFFSET = 0
+const ROW_ID_SIZE = 4
+const ROW_USERNAME_LENGTH_OFFSET = ROW_ID_OFFSET + ROW_ID_SIZE
+const ROW_USERNAME_LENGTH_SIZE = 4
+const ROW_USERNAME_OFFSET = ROW_USERNAME_LENGTH_OFFSET + ROW_USERNAME_LENGTH_SIZE
+const ROW_EMAIL_LENGTH_OFFSET = ROW_USERNAME_OFFSET + COLUMN_USERNAME_LENGTH
+const ROW_EMAIL_LENGTH_SIZE = 4
+const ROW_EMAIL_OFFSET = ROW_EMAIL_LENGTH_OFFSET + ROW_EMAIL_LENGTH_SIZE
+const ROW_EMAIL_OVERFLOW_PAGE_ID_OFFSET = ROW_EMAIL_OFFSET + COLUMN_EMAIL_LENGTH
+const ROW_EMAIL_OVERFLOW_PAGE_ID_SIZE = 4
+
+// 4 + 4 + 32 + 4 + 255 + 4
+const ROW_SIZE = ROW_EMAIL_OVERFLOW_PAGE_ID_OFFSET + ROW_EMAIL_OVERFLOW_PAGE_ID_SIZE
 
 type Table struct {
+	fileName string
+	options  *TableOptions
+	// numRows is the row count recorded in the table header by the last
+	// commit
 	numRows           int
 	btree             *BTree
 	bufferPool        *BufferPool
 	lastTransactionID int32
+	readOnly          bool
+	// lock lets scans run concurrently, it is held exclusively while a
+	// transaction commits and by operations which replace the whole b-tree.
+	lock sync.RWMutex
+	// writeLock is held by the TableTransaction which writes, from its first
+	// write until it ends, so there is a single writer at a time.
+	writeLock chan struct{}
 }
 
-func OpenTable(fileName string) (*Table, error) {
-	replacer := &DummyReplacer{
-		frameIndices: []uint32{},
-		pinnedIdxMap: make(map[uint32]bool),
-	}
-	pager, err := NewFilePager(fileName)
-	if err != nil {
-		return nil, err
+// DEFAULT_BUFFER_POOL_SIZE is the number of pages the buffer pool keeps in
+// memory, a transaction pins every page it reads so it also limits the size
+// of a transaction.
+const DEFAULT_BUFFER_POOL_SIZE = 100
+
+// MIN_BUFFER_POOL_SIZE leaves room for the pages a single b-tree split pins.
+const MIN_BUFFER_POOL_SIZE = 10
+
+// DEFAULT_BUSY_TIMEOUT is how long a write waits for the transaction which
+// writes to end before it fails with ErrLocked.
+const DEFAULT_BUSY_TIMEOUT = 5 * time.Second
+
+// ErrLocked is returned by a write which waited BusyTimeout for the write
+// lock, the transaction stays open so the write can be retried.
+var ErrLocked = errors.New("database is locked")
+
+// TableOptions configures a database file. PageSize is only used when the
+// file is created, 0 means DEFAULT_PAGE_SIZE. MaxSize limits the file size in bytes every time it is
+// opened, 0 means no limit other than MAX_PAGE_COUNT. BufferPoolSize is the
+// number of pages kept in memory, 0 means DEFAULT_BUFFER_POOL_SIZE. A
+// ReadOnly table rejects writes with ErrReadOnly. BusyTimeout is how long a
+// write waits for the write lock, 0 means DEFAULT_BUSY_TIMEOUT.
+type TableOptions struct {
+	PageSize       int
+	MaxSize        int64
+	BufferPoolSize int
+	ReadOnly       bool
+	BusyTimeout    time.Duration
+}
+
+func DefaultTableOptions() *TableOptions {
+	return &TableOptions{
+		PageSize:       DEFAULT_PAGE_SIZE,
+		BufferPoolSize: DEFAULT_BUFFER_POOL_SIZE,
 	}
+}
 
-	bufferPool := NewBufferPool(replacer, pager, 5, 100)
-	tableHeader, err := readTableHeader(bufferPool)
+func OpenTable(fileName string) (*Table, error) {
+	return OpenTableWithOptions(fileName, DefaultTableOptions())
+}
+
+func OpenTableWithOptions(fileName string, options *TableOptions) (*Table, error) {
+	bufferPool, tableHeader, err := openBufferPool(fileName, options)
 	if err != nil {
 		return nil, err
 	}
 
 	btree := &BTree{
 		rootNodeID:          tableHeader.rootPageNum,
-		capacityPerLeafNode: ROW_PER_PAGE,
+		capacityPerLeafNode: leafNodeKeyPerPage(bufferPool.PageSize()),
 	}
 
 	return &Table{
+		fileName:          fileName,
+		options:           options,
+		numRows:           int(tableHeader.rowCount),
 		btree:             btree,
 		lastTransactionID: int32(0),
 		bufferPool:        bufferPool,
+		readOnly:          options.ReadOnly,
+		writeLock:         make(chan struct{}, 1),
 	}, nil
 }
 
-const TABLE_HEADER_ROOT_PAGE_NUM_SIZE = 4
-const TABLE_HEADER_HEADER_SIZE = PAGE_TYPE_SIZE + TABLE_HEADER_ROOT_PAGE_NUM_SIZE
+// openBufferPool opens the database file and reads its table header through
+// a new buffer pool.
+func openBufferPool(fileName string, options *TableOptions) (*BufferPool, *TableHeader, error) {
+	replacer := &DummyReplacer{
+		frameIndices: []uint32{},
+		pinnedIdxMap: make(map[uint32]bool),
+	}
+	bufferPoolSize := options.BufferPoolSize
+	if bufferPoolSize == 0 {
+		bufferPoolSize = DEFAULT_BUFFER_POOL_SIZE
+	}
+	if bufferPoolSize < MIN_BUFFER_POOL_SIZE {
+		message := fmt.Sprintf("buffer pool size must be at least %d pages", MIN_BUFFER_POOL_SIZE)
+		return nil, nil, errors.New(message)
+	}
+	pager, err := newPager(fileName, options)
+	if err != nil {
+		return nil, nil, err
+	}
 
-type TableHeader struct {
-	rootPageNum uint32
+	bufferPool := NewBufferPool(replacer, pager, 5, bufferPoolSize)
+	tableHeader, err := readTableHeader(bufferPool)
+	if err != nil {
+		pager.Close()
+		return nil, nil, err
+	}
+	return bufferPool, tableHeader, nil
 }
 
-func readTableHeader(bufferPool *BufferPool) (*TableHeader, error) {
-	page, err := bufferPool.FetchPage(uint32(0))
-
+// newPager opens the database file, or creates a database in memory for
+// MEMORY_FILE_NAME.
+func newPager(fileName string, options *TableOptions) (Pager, error) {
+	if fileName == MEMORY_FILE_NAME {
+		pager, err := NewMemoryPager(options)
+		if err != nil {
+			return nil, err
+		}
+		return pager, nil
+	}
+	pager, err := NewFilePager(fileName, options)
 	if err != nil {
 		return nil, err
 	}
-	bs := page[:PAGE_TYPE_SIZE]
-	pageType := binary.LittleEndian.Uint16(bs)
-
-	if pageType != PAGE_TYPE_TABLE_HEADER {
-		return nil, errors.New("Incorrect page_type for Table Header")
-	}
-	from := PAGE_TYPE_SIZE
-	bs = page[from : from+TABLE_HEADER_ROOT_PAGE_NUM_SIZE]
-	rootPageNum := binary.LittleEndian.Uint32(bs)
-	return &TableHeader{rootPageNum: rootPageNum}, nil
+	return pager, nil
 }
 
 func NewTable(btree *BTree, bufferPool *BufferPool) *Table {
@@ -141,12 +217,49 @@ func NewTable(btree *BTree, bufferPool *BufferPool) *Table {
 		btree:             btree,
 		bufferPool:        bufferPool,
 		lastTransactionID: int32(0),
+		writeLock:         make(chan struct{}, 1),
 	}
 }
 
+// lockWrites waits for the transaction which writes to end.
+func (t *Table) lockWrites() {
+	t.writeLock <- struct{}{}
+}
+
+// tryLockWrites waits up to the busy timeout for the transaction which
+// writes to end.
+func (t *Table) tryLockWrites() error {
+	busyTimeout := DEFAULT_BUSY_TIMEOUT
+	if t.options != nil && t.options.BusyTimeout > 0 {
+		busyTimeout = t.options.BusyTimeout
+	}
+	timer := time.NewTimer(busyTimeout)
+	defer timer.Stop()
+	select {
+	case t.writeLock <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrLocked
+	}
+}
+
+func (t *Table) unlockWrites() {
+	<-t.writeLock
+}
+
+// ReadOnly reports whether the table rejects writes.
+func (t *Table) ReadOnly() bool {
+	return t.readOnly
+}
+
+// CloseTable waits for the transaction which writes to end and closes the
+// database.
 func (t *Table) CloseTable() error {
-	t.bufferPool.FlushAllPage()
-	return nil
+	t.lockWrites()
+	defer t.unlockWrites()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.bufferPool.Close()
 }
 
 func (t *Table) newTransaction() *Transaction {
@@ -154,70 +267,156 @@ func (t *Table) newTransaction() *Transaction {
 	return NewTransaction(id, t.bufferPool)
 }
 
-func (t *Table) InsertRow(newRow *Row) error {
-	tx := t.newTransaction()
-	c := newCursorFromStart(t, tx)
-	c.write(newRow)
+// commit records the b-tree root and bumps the change counter in the table
+// header before committing a write transaction. Nothing is written for a
+// read only table.
+func (t *Table) commit(tx *Transaction) error {
+	if t.readOnly {
+		tx.Rollback()
+		return nil
+	}
+	header, err := tx.ReadPage(uint32(0))
+	if err != nil {
+		t.rollback(tx)
+		return err
+	}
+	writeTableHeaderField(header, TABLE_HEADER_ROOT_PAGE_NUM_OFFSET, t.btree.rootNodeID)
+	changeCounter := readTableHeaderField(header, TABLE_HEADER_CHANGE_COUNTER_OFFSET)
+	writeTableHeaderField(header, TABLE_HEADER_CHANGE_COUNTER_OFFSET, changeCounter+1)
 	tx.Commit()
 	return nil
 }
 
-func (t *Table) Schema() map[string]string {
-	return map[string]string{
-		"id":       "uint32",
-		"username": "string",
-		"email":    "string",
+// rollback discards a write transaction, the b-tree root might be changed by
+// the transaction so it is reloaded from the table header. The pages the
+// transaction appended to the database are released to the free list.
+func (t *Table) rollback(tx *Transaction) {
+	tx.Rollback()
+	header, err := readTableHeader(t.bufferPool)
+	if err == nil {
+		t.btree.rootNodeID = header.rootPageNum
+	}
+	if len(tx.newPageIDs) > 0 {
+		t.freeNewPages(tx.newPageIDs)
 	}
 }
 
-func (t *Table) SeqScan(filter Filter) ([]*Row, error) {
-	tx := t.newTransaction()
-
-	rows := []*Row{}
+func (t *Table) InsertRow(newRow *Row) error {
+	tx := t.Begin()
+	err := tx.InsertRow(newRow)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-	c := newCursorFromStart(t, tx)
-	for c.endOfTable != true {
-		row, err := c.value()
-		if err != nil {
-			return nil, err
-		}
-		if filter == nil {
-			rows = append(rows, row)
-		} else {
-			pass, err := filter.Test(row)
+// InsertRows inserts the rows in one transaction, either all of them or none
+// are inserted.
+func (t *Table) InsertRows(newRows []*Row) error {
+	tx := t.Begin()
+	err := tx.InsertRows(newRows)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-			if err != nil {
-				return nil, err
-			}
+// UpdateRow replaces the row with the same id, the overflow pages of the old
+// row are released.
+func (t *Table) UpdateRow(newRow *Row) error {
+	tx := t.Begin()
+	err := tx.UpdateRow(newRow)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
 
-			if pass {
-				rows = append(rows, row)
-			}
-		}
+// DeleteRow removes the row by id and releases its overflow pages, it returns
+// false when there is no such row.
+func (t *Table) DeleteRow(id uint32) (bool, error) {
+	tx := t.Begin()
+	found, err := tx.DeleteRow(id)
+	if err != nil || found == false {
+		tx.Rollback()
+		return false, err
+	}
+	return true, tx.Commit()
+}
 
-		c.advance()
+// deleteRow removes the row from btree and releases its overflow pages.
+func deleteRow(btree *BTree, tx *Transaction, id uint32) (bool, error) {
+	noder := &TransactionNoder{transaction: tx}
+	tuple, err := btree.Delete(id, noder)
+	if err != nil || tuple == nil {
+		return false, err
 	}
 
-	for c.endOfTable != true {
-		row, err := c.value()
+	overflowPageID := codec.rowOverflowPageID(tuple.value)
+	if overflowPageID != 0 {
+		err := freeOverflow(tx, overflowPageID)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		rows = append(rows, row)
-		c.advance()
 	}
+	return true, nil
+}
 
-	tx.Commit()
-	return rows, nil
+// TABLE_NAME is the name of the only table of a database file, statements
+// do not check the table they name yet.
+const TABLE_NAME = "users"
+
+func (t *Table) Name() string {
+	return TABLE_NAME
 }
 
-func (t *Table) IndexScan(indexCondition *IndexCondition, filter Filter) ([]*Row, error) {
+// Columns returns the column names in the order they are stored in a row.
+func (t *Table) Columns() []string {
+	return []string{"id", "username", "email"}
+}
+
+func (t *Table) Schema() map[string]string {
+	return map[string]string{
+		"id":       "uint32",
+		"username": "string",
+		"email":    "string",
+	}
+}
+
+// SeqScan reads every committed row passing filter, the writes of a running
+// TableTransaction are not seen.
+func (t *Table) SeqScan(filter Filter) ([]*Row, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
 	tx := t.newTransaction()
+	defer tx.Rollback()
+	c, err := newCursorFromStart(t.btree, tx)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(c, filter)
+}
 
-	rows := []*Row{}
+func (t *Table) IndexScan(indexCondition *IndexCondition, filter Filter) ([]*Row, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 
-	c := newCursorForIndexScan(t, tx, indexCondition)
+	tx := t.newTransaction()
+	defer tx.Rollback()
+	c, err := newCursorForIndexScan(t.btree, tx, indexCondition)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(c, filter)
+}
 
+// collectRows reads rows from the cursor until the end of table.
+func collectRows(c *Cursor, filter Filter) ([]*Row, error) {
+	rows := []*Row{}
 	for c.endOfTable != true {
 		row, err := c.value()
 		if err != nil {
@@ -237,29 +436,25 @@ func (t *Table) IndexScan(indexCondition *IndexCondition, filter Filter) ([]*Row
 			}
 		}
 
-		c.advance()
-	}
-
-	for c.endOfTable != true {
-		row, err := c.value()
+		err = c.advance()
 		if err != nil {
 			return nil, err
 		}
-		rows = append(rows, row)
-		c.advance()
 	}
-
-	tx.Commit()
 	return rows, nil
 }
 
+// NumRows returns the number of committed rows.
 func (t *Table) NumRows() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
 	return t.numRows
 }
 
-// Cursor represents a location in the table.
+// Cursor represents a location in the b-tree of a table.
 type Cursor struct {
-	table      *Table
+	btree      *BTree
+	tx         *Transaction
 	noder      Noder
 	endOfTable bool
 	pageNum    int
@@ -270,70 +465,105 @@ type Cursor struct {
 }
 
 // * Create a cursor at the beginning of the table
-func newCursorFromStart(table *Table, tx *Transaction) *Cursor {
+func newCursorFromStart(btree *BTree, tx *Transaction) (*Cursor, error) {
 	noder := &TransactionNoder{transaction: tx}
-	leafNode := table.btree.FirstLeafNode(noder)
-	return &Cursor{
-		table:      table,
-		noder:      noder,
-		endOfTable: len(leafNode.Keys()) == 0,
-		leafNode:   leafNode,
-		direction:  "next",
+	leafNode, err := btree.FirstLeafNode(noder)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cursor{
+		btree:     btree,
+		tx:        tx,
+		noder:     noder,
+		leafNode:  leafNode,
+		cellNum:   -1,
+		direction: "next",
+	}
+	// advance to the first row, which might not be in the left most leaf
+	err = c.advance()
+	if err != nil {
+		return nil, err
 	}
+	return c, nil
 }
 
-// * Create a cursor at the beginning of the table
-func newCursorForIndexScan(table *Table, tx *Transaction, indexCondition *IndexCondition) *Cursor {
-	key := indexCondition.Target
-
+// * Create a cursor at the first row matching the condition, a scan bounded
+// from above starts from the first row of the table and walks forward until
+// the bound.
+func newCursorForIndexScan(btree *BTree, tx *Transaction, indexCondition *IndexCondition) (*Cursor, error) {
 	operator := indexCondition.Operator
-	direction := "next"
 	if operator == "<" || operator == "<=" {
-		direction = "prev"
+		c, err := newCursorFromStart(btree, tx)
+		if err != nil {
+			return nil, err
+		}
+		c.indexCond = indexCondition
+		err = c.checkCondition()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
 	}
 
 	noder := &TransactionNoder{transaction: tx}
-	leafNode, idx := table.btree.FindLeafNodeByCondition(key, operator, noder)
+	leafNode, idx, err := btree.FindLeafNodeByCondition(indexCondition.Target, operator, noder)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Cursor{
-		table:      table,
+		btree:      btree,
+		tx:         tx,
 		noder:      noder,
 		endOfTable: idx == -1,
 		leafNode:   leafNode,
 		cellNum:    idx,
-		direction:  direction,
+		direction:  "next",
 		indexCond:  indexCondition,
-	}
+	}, nil
 }
 
 // Access the row the cursor is pointing to
 func (c *Cursor) value() (*Row, error) {
 	tuple := c.leafNode.tuples[c.cellNum]
-	bs := tuple.value
-	id := binary.LittleEndian.Uint32(bs)
-	replacer := strings.NewReplacer("\x00", "")
-	usrename := replacer.Replace(string(bs[4:COLUMN_USERNAME_LENGTH]))
-	email := replacer.Replace(string(bs[36:COLUMN_EMAIL_LENGTH]))
-
-	row := NewRow(id, usrename, email)
+	row, overflow, err := codec.decodeRow(tuple.value)
+	if err != nil {
+		return nil, err
+	}
+	if overflow.size() > 0 {
+		bs, err := readOverflow(c.tx, overflow.pageID, overflow.size())
+		if err != nil {
+			return nil, err
+		}
+		overflow.complete(row, bs)
+	}
 	return row, nil
 }
 
 // Overwrite the row
-func (c *Cursor) write(row *Row) {
-	c.table.btree.Insert(row.Id(), row.Bytes(), c.noder)
+func (c *Cursor) write(row *Row) error {
+	overflowPageID, err := writeOverflow(c.tx, codec.encodeRowOverflow(row))
+	if err != nil {
+		return err
+	}
+	return c.btree.Insert(row.Id(), codec.encodeRow(row, overflowPageID), c.noder)
 }
 
 // Advance the cursor to move its position forward.
-func (c *Cursor) advance() {
+func (c *Cursor) advance() error {
 	c.cellNum = c.cellNum + 1
 
-	if c.cellNum >= LEAF_NODE_KEY_PER_PAGE {
+	// leaf nodes are not merged after delete, so a sibling might be empty
+	for c.endOfTable == false && c.cellNum >= len(c.leafNode.Keys()) {
 		var newLeafNode *LeafNode
+		var err error
 		if c.direction == "next" {
-			newLeafNode = c.table.btree.NextLeafNode(c.leafNode, c.noder)
+			newLeafNode, err = c.btree.NextLeafNode(c.leafNode, c.noder)
 		} else {
-			newLeafNode = c.table.btree.PrevLeafNode(c.leafNode, c.noder)
+			newLeafNode, err = c.btree.PrevLeafNode(c.leafNode, c.noder)
+		}
+		if err != nil {
+			return err
 		}
 		if newLeafNode != nil {
 			c.cellNum = 0
@@ -341,13 +571,20 @@ func (c *Cursor) advance() {
 		} else {
 			c.endOfTable = true
 		}
-	} else if c.cellNum >= len(c.leafNode.Keys()) {
-		c.endOfTable = true
 	}
 
-	if c.indexCond != nil && c.endOfTable == false {
-		row, _ := c.value()
-		shouldEnd, _ := c.indexCond.ShouldEnd(row.Id())
-		c.endOfTable = shouldEnd
+	return c.checkCondition()
+}
+
+// checkCondition ends the cursor when its row is beyond the index condition.
+func (c *Cursor) checkCondition() error {
+	if c.indexCond == nil || c.endOfTable {
+		return nil
 	}
+	shouldEnd, err := c.indexCond.ShouldEnd(c.leafNode.tuples[c.cellNum].key)
+	if err != nil {
+		return err
+	}
+	c.endOfTable = shouldEnd
+	return nil
 }
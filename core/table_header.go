@@ -0,0 +1,108 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Table Header
+// The first page of a database file, every field except MAGIC is a little
+// endian uint32.
+// MAGIC(16 bytes), FORMAT_VERSION, PAGE_SIZE, PAGE_COUNT, ROOT_PAGE_NUM,
+// FREE_LIST_HEAD, CHANGE_COUNTER, ROW_COUNT, CHECKSUM
+const TABLE_HEADER_MAGIC = "sqlbit format\x00\x00\x00"
+
+// FORMAT_VERSION is bumped whenever the on disk layout changes, files with an
+// older version are migrated by upgradeFile when they are opened.
+const FORMAT_VERSION = 3
+
+const TABLE_HEADER_MAGIC_OFFSET = 0
+const TABLE_HEADER_MAGIC_SIZE = 16
+const TABLE_HEADER_FORMAT_VERSION_OFFSET = TABLE_HEADER_MAGIC_OFFSET + TABLE_HEADER_MAGIC_SIZE
+const TABLE_HEADER_FORMAT_VERSION_SIZE = 4
+const TABLE_HEADER_PAGE_SIZE_OFFSET = TABLE_HEADER_FORMAT_VERSION_OFFSET + TABLE_HEADER_FORMAT_VERSION_SIZE
+const TABLE_HEADER_PAGE_SIZE_SIZE = 4
+const TABLE_HEADER_PAGE_COUNT_OFFSET = TABLE_HEADER_PAGE_SIZE_OFFSET + TABLE_HEADER_PAGE_SIZE_SIZE
+const TABLE_HEADER_PAGE_COUNT_SIZE = 4
+const TABLE_HEADER_ROOT_PAGE_NUM_OFFSET = TABLE_HEADER_PAGE_COUNT_OFFSET + TABLE_HEADER_PAGE_COUNT_SIZE
+const TABLE_HEADER_ROOT_PAGE_NUM_SIZE = 4
+const TABLE_HEADER_FREE_LIST_HEAD_OFFSET = TABLE_HEADER_ROOT_PAGE_NUM_OFFSET + TABLE_HEADER_ROOT_PAGE_NUM_SIZE
+const TABLE_HEADER_FREE_LIST_HEAD_SIZE = 4
+const TABLE_HEADER_CHANGE_COUNTER_OFFSET = TABLE_HEADER_FREE_LIST_HEAD_OFFSET + TABLE_HEADER_FREE_LIST_HEAD_SIZE
+const TABLE_HEADER_CHANGE_COUNTER_SIZE = 4
+const TABLE_HEADER_ROW_COUNT_OFFSET = TABLE_HEADER_CHANGE_COUNTER_OFFSET + TABLE_HEADER_CHANGE_COUNTER_SIZE
+const TABLE_HEADER_ROW_COUNT_SIZE = 4
+const TABLE_HEADER_CHECKSUM_OFFSET = TABLE_HEADER_ROW_COUNT_OFFSET + TABLE_HEADER_ROW_COUNT_SIZE
+const TABLE_HEADER_CHECKSUM_SIZE = PAGE_CHECKSUM_SIZE
+const TABLE_HEADER_HEADER_SIZE = TABLE_HEADER_CHECKSUM_OFFSET + TABLE_HEADER_CHECKSUM_SIZE
+
+type TableHeader struct {
+	formatVersion uint32
+	pageSize      uint32
+	pageCount     uint32
+	rootPageNum   uint32
+	freeListHead  uint32
+	changeCounter uint32
+	rowCount      uint32
+}
+
+// Bytes encodes the header into the beginning of a page.
+func (h *TableHeader) Bytes() []byte {
+	return codec.encodeTableHeader(h)
+}
+
+func hasTableHeaderMagic(bs []byte) bool {
+	if len(bs) < TABLE_HEADER_HEADER_SIZE {
+		return false
+	}
+	magic := bs[TABLE_HEADER_MAGIC_OFFSET : TABLE_HEADER_MAGIC_OFFSET+TABLE_HEADER_MAGIC_SIZE]
+	return bytes.Equal(magic, []byte(TABLE_HEADER_MAGIC))
+}
+
+// deserializeTableHeader decodes the header at the beginning of bs, it fails
+// when bs does not start with TABLE_HEADER_MAGIC.
+func deserializeTableHeader(bs []byte) (*TableHeader, error) {
+	return codec.decodeTableHeader(bs)
+}
+
+// validate checks the header against what this build of sqlbit can read.
+func (h *TableHeader) validate() error {
+	if h.formatVersion != FORMAT_VERSION {
+		message := fmt.Sprintf("unsupported format version %d", h.formatVersion)
+		return errors.New(message)
+	}
+	if isValidPageSize(int(h.pageSize)) == false {
+		message := fmt.Sprintf("unsupported page size %d", h.pageSize)
+		return errors.New(message)
+	}
+	if h.pageCount < 2 || h.rootPageNum == 0 || h.rootPageNum >= h.pageCount {
+		return errors.New("table header is corrupted")
+	}
+	return nil
+}
+
+// isValidPageSize reports whether pageSize is a power of two between
+// MIN_PAGE_SIZE and MAX_PAGE_SIZE.
+func isValidPageSize(pageSize int) bool {
+	return pageSize >= MIN_PAGE_SIZE && pageSize <= MAX_PAGE_SIZE && pageSize&(pageSize-1) == 0
+}
+
+func readTableHeader(bufferPool *BufferPool) (*TableHeader, error) {
+	page, err := bufferPool.FetchPage(uint32(0))
+	if err != nil {
+		return nil, err
+	}
+	defer bufferPool.UnpinPage(uint32(0), false)
+
+	return deserializeTableHeader(page)
+}
+
+func readTableHeaderField(page *Page, offset int) uint32 {
+	return codec.tableHeaderField(page.body, offset)
+}
+
+func writeTableHeaderField(page *Page, offset int, value uint32) {
+	codec.setTableHeaderField(page.body, offset, value)
+	page.MarkAsDirty()
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableHeaderBytes(t *testing.T) {
+	header := &TableHeader{
+		formatVersion: FORMAT_VERSION,
+		pageSize:      DEFAULT_PAGE_SIZE,
+		pageCount:     7,
+		rootPageNum:   3,
+		freeListHead:  5,
+		changeCounter: 42,
+		rowCount:      9,
+	}
+
+	actual, err := deserializeTableHeader(header.Bytes())
+
+	assert.Nil(t, err)
+	assert.Equal(t, header, actual)
+	assert.Nil(t, actual.validate())
+}
+
+func TestDeserializeTableHeaderWithoutMagic(t *testing.T) {
+	bs := make([]byte, TABLE_HEADER_HEADER_SIZE)
+
+	_, err := deserializeTableHeader(bs)
+
+	assert.Equal(t, "file is not a sqlbit database", err.Error())
+}
+
+func TestTableHeaderValidate(t *testing.T) {
+	header := &TableHeader{
+		formatVersion: FORMAT_VERSION + 1,
+		pageSize:      DEFAULT_PAGE_SIZE,
+		pageCount:     2,
+		rootPageNum:   1,
+	}
+	message := fmt.Sprintf("unsupported format version %d", FORMAT_VERSION+1)
+	assert.Equal(t, message, header.validate().Error())
+
+	header.formatVersion = FORMAT_VERSION
+	header.pageSize = 3000
+	assert.Equal(t, "unsupported page size 3000", header.validate().Error())
+
+	header.pageSize = DEFAULT_PAGE_SIZE
+	header.rootPageNum = 2
+	assert.Equal(t, "table header is corrupted", header.validate().Error())
+}
@@ -2,8 +2,9 @@ package core
 
 import (
 	"bufio"
-	"encoding/binary"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,36 +15,35 @@ func prepareBtreeFile(fileName string, tuples []*Tuple) uint32 {
 	w := bufio.NewWriter(f)
 
 	//Prepare Table Header
-	bs := make([]byte, 2)
-	binary.LittleEndian.PutUint16(bs, uint16(PAGE_TYPE_TABLE_HEADER))
 	rootPageNum := uint32(1)
-	b := make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, rootPageNum)
-	bs = append(bs, b...)
-	bs = append(bs, make([]byte, 4096-len(bs))...)
+	header := &TableHeader{
+		formatVersion: FORMAT_VERSION,
+		pageSize:      DEFAULT_PAGE_SIZE,
+		pageCount:     2,
+		rootPageNum:   rootPageNum,
+		rowCount:      uint32(len(tuples)),
+	}
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
+	copy(page0[:], header.Bytes())
+	stampPageChecksum(0, page0[:])
 
 	//Prepare Leaf Node
-	b = make([]byte, 2)
-	binary.LittleEndian.PutUint16(b, uint16(PAGE_TYPE_LEAF_NODE))
-	bs = append(bs, b...)
-	numTuples := uint32(len(tuples))
-	b = make([]byte, 4)
-	binary.LittleEndian.PutUint32(b, numTuples)
-	bs = append(bs, b...)
-
-	b = make([]byte, LEAF_NODE_HEADER_SIZE-(4+2))
-	bs = append(bs, b...)
-
-	for _, tuple := range tuples {
-		bs = append(bs, tuple.value...)
+	page1 := newPageBody(DEFAULT_PAGE_SIZE)
+	leafNode := &LeafNode{
+		id:     rootPageNum,
+		tuples: tuples,
+		page:   &Page{id: rootPageNum, body: page1},
 	}
+	leafNode.syncBytes()
+	stampPageChecksum(rootPageNum, page1[:])
 
-	w.Write(bs)
+	w.Write(append(page0[:], page1[:]...))
 	w.Flush()
 	f.Close()
 
 	return rootPageNum
 }
+
 func TestOpenTable(t *testing.T) {
 	removeTestFile()
 	fileName := getTestFileName()
@@ -120,3 +120,240 @@ func TestTableIndexScan(t *testing.T) {
 	assert.Equal(t, 1, len(rows))
 	assert.Equal(t, uint32(17), rows[0].Id())
 }
+
+func TestTableInsertRowWithLongEmail(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+	email := strings.Repeat("a", DEFAULT_PAGE_SIZE*2) + "@hogwarts.edu"
+	row := NewRow(1, "Harry", email)
+
+	err := table.InsertRow(row)
+
+	assert.Nil(t, err)
+	rows, err := table.SeqScan(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "Harry", rows[0].Username())
+	assert.Equal(t, email, rows[0].Email())
+}
+
+func TestTableInsertRowWithLongUsername(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+	username := strings.Repeat("h", COLUMN_USERNAME_LENGTH+DEFAULT_PAGE_SIZE)
+	email := strings.Repeat("a", COLUMN_EMAIL_LENGTH+10) + "@hogwarts.edu"
+	table.InsertRow(NewRow(1, "Harry", email))
+
+	err := table.InsertRow(NewRow(2, username, "ron@hogwarts.edu"))
+	assert.Nil(t, err)
+	err = table.InsertRow(NewRow(3, username, email))
+	assert.Nil(t, err)
+
+	table.CloseTable()
+	table, _ = OpenTable(fileName)
+	defer table.CloseTable()
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, []*Row{
+		NewRow(1, "Harry", email),
+		NewRow(2, username, "ron@hogwarts.edu"),
+		NewRow(3, username, email),
+	}, rows)
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestTableDeleteRow(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	tuples := []*Tuple{createTuple(17), createTuple(42)}
+	prepareBtreeFile(fileName, tuples)
+	table, _ := OpenTable(fileName)
+
+	found, err := table.DeleteRow(17)
+
+	assert.Nil(t, err)
+	assert.Equal(t, true, found)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, uint32(42), rows[0].Id())
+	found, err = table.DeleteRow(17)
+	assert.Nil(t, err)
+	assert.Equal(t, false, found)
+}
+
+func TestTableDeleteRowFreesOverflowPages(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+	email := strings.Repeat("a", overflowPageCapacity(DEFAULT_PAGE_SIZE)*2)
+	table.InsertRow(NewRow(1, "Harry", email))
+
+	table.DeleteRow(1)
+	table.InsertRow(NewRow(2, "Ron", email))
+
+	tx := table.newTransaction()
+	_, head, _ := readFreeListHead(tx)
+	tx.Commit()
+	assert.Equal(t, uint32(0), head)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, email, rows[0].Email())
+}
+
+func TestTableUpdateRow(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	table, _ := OpenTable(fileName)
+	table.InsertRow(NewRow(1, "Harry", strings.Repeat("a", overflowPageCapacity(DEFAULT_PAGE_SIZE))))
+
+	err := table.UpdateRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+
+	assert.Nil(t, err)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "harry@hogwarts.edu", rows[0].Email())
+	tx := table.newTransaction()
+	_, head, _ := readFreeListHead(tx)
+	tx.Commit()
+	assert.NotEqual(t, uint32(0), head)
+	err = table.UpdateRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+	assert.Equal(t, "row 2 does not exist", err.Error())
+}
+
+func TestOpenTableWithPageSize(t *testing.T) {
+	for _, pageSize := range []int{MIN_PAGE_SIZE, 16384, MAX_PAGE_SIZE} {
+		removeTestFile()
+		fileName := getTestFileName()
+		table, err := OpenTableWithOptions(fileName, &TableOptions{PageSize: pageSize})
+		assert.Nil(t, err)
+		for i := 1; i <= 60; i++ {
+			table.InsertRow(NewRow(uint32(i*7%61), "user", strings.Repeat("a", pageSize/2)))
+		}
+		table.CloseTable()
+
+		table, err = OpenTable(fileName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, pageSize, table.bufferPool.PageSize())
+		rows, _ := table.SeqScan(nil)
+		assert.Equal(t, 60, len(rows))
+		assert.Equal(t, strings.Repeat("a", pageSize/2), rows[59].Email())
+		problems, _ := table.CheckIntegrity()
+		assert.Equal(t, []string{}, problems)
+		fi, _ := os.Stat(fileName)
+		assert.Equal(t, int64(pageSize)*int64(table.bufferPool.pager.(*FilePager).numPages), fi.Size())
+		table.CloseTable()
+	}
+}
+
+func TestOpenTableWithInvalidPageSize(t *testing.T) {
+	removeTestFile()
+
+	_, err := OpenTableWithOptions(getTestFileName(), &TableOptions{PageSize: 5000})
+
+	assert.Equal(t, "page size must be a power of two between 1024 and 65536", err.Error())
+}
+
+func TestTableNumRows(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	table, _ := OpenTable(fileName)
+	// more rows than the 100 pages tables used to be limited to
+	for i := 1; i <= 1500; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	table.DeleteRow(1)
+	table.DeleteRow(1)
+	table.UpdateRow(NewRow(2, "user", "user@test.com"))
+	tx := table.Begin()
+	tx.InsertRow(NewRow(2000, "user", "user@test.com"))
+	assert.Equal(t, 1500, tx.NumRows())
+	tx.Rollback()
+	assert.Equal(t, 1499, table.NumRows())
+	table.CloseTable()
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1499, table.NumRows())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestOpenTableWithMaxSize(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTableWithOptions(getTestFileName(), &TableOptions{
+		PageSize: DEFAULT_PAGE_SIZE,
+		MaxSize:  4 * DEFAULT_PAGE_SIZE,
+	})
+
+	var err error
+	numRows := 0
+	for i := 1; i <= 100 && err == nil; i++ {
+		err = table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+		if err == nil {
+			numRows++
+		}
+	}
+
+	assert.Equal(t, "statement is rolled back: database is full", err.Error())
+	assert.Equal(t, numRows, table.NumRows())
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, numRows, len(rows))
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	found, err := table.DeleteRow(1)
+	assert.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestOpenTableReadOnly(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	table, _ := OpenTable(fileName)
+	table.InsertRow(NewRow(1, "harry", "harry@hogwarts.edu"))
+	table.CloseTable()
+	before, _ := ioutil.ReadFile(fileName)
+
+	table, err := OpenTableWithOptions(fileName, &TableOptions{ReadOnly: true})
+
+	assert.Nil(t, err)
+	assert.True(t, table.ReadOnly())
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, ErrReadOnly, table.InsertRow(NewRow(2, "ron", "ron@hogwarts.edu")))
+	_, err = table.DeleteRow(1)
+	assert.Equal(t, ErrReadOnly, err)
+	tx := table.Begin()
+	assert.Equal(t, ErrReadOnly, tx.UpdateRow(NewRow(1, "ron", "ron@hogwarts.edu")))
+	assert.False(t, tx.Closed())
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, table.CloseTable())
+	after, _ := ioutil.ReadFile(fileName)
+	assert.Equal(t, before, after)
+
+	removeTestFile()
+	_, err = OpenTableWithOptions(fileName, &TableOptions{ReadOnly: true})
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(fileName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOpenTableWithBufferPoolSize(t *testing.T) {
+	removeTestFile()
+	_, err := OpenTableWithOptions(getTestFileName(), &TableOptions{BufferPoolSize: 5})
+	assert.Equal(t, "buffer pool size must be at least 10 pages", err.Error())
+
+	table, err := OpenTableWithOptions(getTestFileName(), &TableOptions{BufferPoolSize: 1000})
+
+	assert.Nil(t, err)
+	stats, _ := table.Stats()
+	assert.Equal(t, 1000, stats.BufferPool.MaxFrames)
+	table.CloseTable()
+}
@@ -0,0 +1,368 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrReadOnly = errors.New("attempt to write a read only database")
+
+// Relation is what statements are executed against, a Table runs every call
+// in its own transaction while a TableTransaction groups them.
+type Relation interface {
+	InsertRow(newRow *Row) error
+	InsertRows(newRows []*Row) error
+	UpdateRow(newRow *Row) error
+	DeleteRow(id uint32) (bool, error)
+	SeqScan(filter Filter) ([]*Row, error)
+	IndexScan(indexCondition *IndexCondition, filter Filter) ([]*Row, error)
+	Scan(indexCondition *IndexCondition, filter Filter) *RowIterator
+	Columns() []string
+	Schema() map[string]string
+	NumRows() int
+}
+
+// TableTransaction groups reads and writes which are committed or rolled
+// back together. Until its first write it reads the committed rows, like a
+// scan of the table. The first write waits for the transaction which writes
+// to end, or fails with ErrLocked after the busy timeout, and holds the write
+// lock of the table until the transaction ends, scans of the table go on and
+// do not see its writes.
+// A write which fails in the middle is undone back to the savepoint taken
+// before it and the transaction stays open, it is only rolled back as a
+// whole when the pages appended by the write can not be released.
+type TableTransaction struct {
+	table *Table
+	tx    *Transaction
+	// btree is the b-tree the transaction writes to, nil before its first
+	// write
+	btree   *BTree
+	numRows int
+	closed  bool
+	// the b-tree root and row count at the savepoint of the running write
+	savepointRootNodeID uint32
+	savepointNumRows    int
+}
+
+// Begin starts a transaction, it does not wait for other transactions.
+func (t *Table) Begin() *TableTransaction {
+	return &TableTransaction{
+		table: t,
+		tx:    t.newTransaction(),
+	}
+}
+
+// Closed reports whether the transaction is committed or rolled back.
+func (tt *TableTransaction) Closed() bool {
+	return tt.closed
+}
+
+func (tt *TableTransaction) checkClosed() error {
+	if tt.closed {
+		return errors.New("transaction is already closed")
+	}
+	return nil
+}
+
+// checkWritable is checked before every write, a rejected write leaves the
+// transaction open.
+func (tt *TableTransaction) checkWritable() error {
+	err := tt.checkClosed()
+	if err != nil {
+		return err
+	}
+	if tt.table.readOnly {
+		return ErrReadOnly
+	}
+	return tt.beginWrite()
+}
+
+// beginWrite takes the write lock of the table before the first write, the
+// transaction writes to a b-tree of its own from the committed root.
+func (tt *TableTransaction) beginWrite() error {
+	if tt.btree != nil {
+		return nil
+	}
+	t := tt.table
+	err := t.tryLockWrites()
+	if err != nil {
+		return err
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	tt.btree = &BTree{
+		rootNodeID:          t.btree.rootNodeID,
+		capacityPerLeafNode: t.btree.capacityPerLeafNode,
+	}
+	tt.numRows = t.numRows
+	return nil
+}
+
+// setNumRows records the row count in the table header, it becomes visible
+// to others when the transaction is committed.
+func (tt *TableTransaction) setNumRows(numRows int) error {
+	header, err := tt.tx.ReadPage(uint32(0))
+	if err != nil {
+		return err
+	}
+	writeTableHeaderField(header, TABLE_HEADER_ROW_COUNT_OFFSET, uint32(numRows))
+	tt.numRows = numRows
+	return nil
+}
+
+// setSavepoint is called at the start of every write, after checkWritable.
+func (tt *TableTransaction) setSavepoint() {
+	tt.tx.setSavepoint()
+	tt.savepointRootNodeID = tt.btree.rootNodeID
+	tt.savepointNumRows = tt.numRows
+}
+
+// abort undoes a failed write back to its savepoint, the pages it appended
+// are released in the transaction. The whole transaction is rolled back when
+// they can not be released.
+func (tt *TableTransaction) abort(err error) error {
+	newPageIDs := tt.tx.rollbackToSavepoint()
+	tt.btree.rootNodeID = tt.savepointRootNodeID
+	tt.numRows = tt.savepointNumRows
+	for _, pageID := range newPageIDs {
+		freeErr := freeNewPage(tt.tx, pageID)
+		if freeErr != nil {
+			tt.Rollback()
+			message := fmt.Sprintf("transaction is rolled back: %s", err)
+			return errors.New(message)
+		}
+	}
+	message := fmt.Sprintf("statement is rolled back: %s", err)
+	return errors.New(message)
+}
+
+func (tt *TableTransaction) InsertRow(newRow *Row) error {
+	err := tt.checkWritable()
+	if err != nil {
+		return err
+	}
+	tt.setSavepoint()
+	defer tt.tx.releaseSavepoint()
+	return tt.insertRow(newRow)
+}
+
+func (tt *TableTransaction) insertRow(newRow *Row) error {
+	// check before the overflow pages are written, so a duplicate id is
+	// rejected without undoing anything
+	err := tt.checkNewID(newRow.Id())
+	if err != nil {
+		return err
+	}
+
+	c, err := newCursorFromStart(tt.btree, tt.tx)
+	if err != nil {
+		return tt.abort(err)
+	}
+	err = c.write(newRow)
+	if err != nil {
+		return tt.abort(err)
+	}
+	err = tt.setNumRows(tt.numRows + 1)
+	if err != nil {
+		return tt.abort(err)
+	}
+	return nil
+}
+
+// checkNewID returns ErrDuplicateKey when a row with the id exists.
+func (tt *TableTransaction) checkNewID(id uint32) error {
+	noder := &TransactionNoder{transaction: tt.tx}
+	tuple, err := tt.btree.Find(id, noder)
+	if err != nil {
+		return tt.abort(err)
+	}
+	if tuple != nil {
+		return ErrDuplicateKey{Key: id}
+	}
+	return nil
+}
+
+// InsertRows inserts the rows one by one, a failed insert undoes the rows
+// inserted before it. The ids are checked first, a duplicate id inserts none
+// of the rows.
+func (tt *TableTransaction) InsertRows(newRows []*Row) error {
+	err := tt.checkWritable()
+	if err != nil {
+		return err
+	}
+	tt.setSavepoint()
+	defer tt.tx.releaseSavepoint()
+	ids := map[uint32]bool{}
+	for _, newRow := range newRows {
+		if ids[newRow.Id()] {
+			return ErrDuplicateKey{Key: newRow.Id()}
+		}
+		ids[newRow.Id()] = true
+		err = tt.checkNewID(newRow.Id())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, newRow := range newRows {
+		err := tt.insertRow(newRow)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateRow replaces the row with the same id, the overflow pages of the old
+// row are released.
+func (tt *TableTransaction) UpdateRow(newRow *Row) error {
+	err := tt.checkWritable()
+	if err != nil {
+		return err
+	}
+	tt.setSavepoint()
+	defer tt.tx.releaseSavepoint()
+
+	found, err := deleteRow(tt.btree, tt.tx, newRow.Id())
+	if err != nil {
+		return tt.abort(err)
+	}
+	if found == false {
+		message := fmt.Sprintf("row %d does not exist", newRow.Id())
+		return errors.New(message)
+	}
+
+	c, err := newCursorFromStart(tt.btree, tt.tx)
+	if err != nil {
+		return tt.abort(err)
+	}
+	err = c.write(newRow)
+	if err != nil {
+		return tt.abort(err)
+	}
+	return nil
+}
+
+// DeleteRow removes the row by id and releases its overflow pages, it returns
+// false when there is no such row.
+func (tt *TableTransaction) DeleteRow(id uint32) (bool, error) {
+	err := tt.checkWritable()
+	if err != nil {
+		return false, err
+	}
+	tt.setSavepoint()
+	defer tt.tx.releaseSavepoint()
+
+	found, err := deleteRow(tt.btree, tt.tx, id)
+	if err != nil {
+		return false, tt.abort(err)
+	}
+	if found {
+		err = tt.setNumRows(tt.numRows - 1)
+		if err != nil {
+			return false, tt.abort(err)
+		}
+	}
+	return found, nil
+}
+
+// SeqScan reads every row passing filter, including the rows written by the
+// transaction.
+func (tt *TableTransaction) SeqScan(filter Filter) ([]*Row, error) {
+	err := tt.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	if tt.btree == nil {
+		return tt.table.SeqScan(filter)
+	}
+	c, err := newCursorFromStart(tt.btree, tt.tx)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(c, filter)
+}
+
+func (tt *TableTransaction) IndexScan(indexCondition *IndexCondition, filter Filter) ([]*Row, error) {
+	err := tt.checkClosed()
+	if err != nil {
+		return nil, err
+	}
+	if tt.btree == nil {
+		return tt.table.IndexScan(indexCondition, filter)
+	}
+	c, err := newCursorForIndexScan(tt.btree, tt.tx, indexCondition)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(c, filter)
+}
+
+func (tt *TableTransaction) Columns() []string {
+	return tt.table.Columns()
+}
+
+func (tt *TableTransaction) Schema() map[string]string {
+	return tt.table.Schema()
+}
+
+// NumRows returns the number of rows, including the writes of the
+// transaction.
+func (tt *TableTransaction) NumRows() int {
+	if tt.btree == nil {
+		return tt.table.NumRows()
+	}
+	return tt.numRows
+}
+
+// Full reports whether the transaction pinned half of the buffer pool, a
+// long batch of writes should be committed and continued in a new
+// transaction then.
+func (tt *TableTransaction) Full() bool {
+	return tt.tx.full()
+}
+
+// Commit makes the writes of the transaction visible to others, scans wait
+// while the pages are copied into the buffer pool.
+func (tt *TableTransaction) Commit() error {
+	err := tt.checkClosed()
+	if err != nil {
+		return err
+	}
+
+	tt.closed = true
+	if tt.btree == nil {
+		tt.tx.Rollback()
+		return nil
+	}
+	t := tt.table
+	defer t.unlockWrites()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.btree.rootNodeID = tt.btree.rootNodeID
+	err = t.commit(tt.tx)
+	if err != nil {
+		return err
+	}
+	t.numRows = tt.numRows
+	return nil
+}
+
+// Rollback discards the writes of the transaction, it is a no-op when the
+// transaction is already closed.
+func (tt *TableTransaction) Rollback() {
+	if tt.closed {
+		return
+	}
+
+	tt.closed = true
+	if tt.btree == nil {
+		tt.tx.Rollback()
+		return
+	}
+	t := tt.table
+	defer t.unlockWrites()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rollback(tt.tx)
+}
@@ -0,0 +1,285 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableTransactionCommit(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	tx := table.Begin()
+
+	tx.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+	tx.InsertRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+	rowsInTx, _ := tx.SeqScan(nil)
+	err := tx.Commit()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rowsInTx))
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, true, tx.Closed())
+}
+
+func TestTableTransactionRollback(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	table.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+	tx := table.Begin()
+
+	tx.InsertRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+	tx.DeleteRow(1)
+	tx.Rollback()
+
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "Harry", rows[0].Username())
+	assert.Equal(t, "transaction is already closed", tx.InsertRow(NewRow(3, "a", "b")).Error())
+}
+
+func TestTableTransactionRollbackReleasesNewPages(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	email := strings.Repeat("e", 300)
+	tx := table.Begin()
+	for i := 1; i <= 40; i++ {
+		tx.InsertRow(NewRow(uint32(i), "user", email))
+	}
+	tx.Rollback()
+
+	for i := 1; i <= 21; i++ {
+		assert.Nil(t, table.InsertRow(NewRow(uint32(i), "user", email)))
+	}
+
+	problems, err := table.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	table.CloseTable()
+	table, _ = OpenTable(getTestFileName())
+	defer table.CloseTable()
+	problems, err = table.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	assert.Equal(t, 21, table.NumRows())
+}
+
+func TestTableTransactionUpdateMissingRow(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	tx := table.Begin()
+	tx.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+
+	err := tx.UpdateRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+	tx.Commit()
+
+	assert.Equal(t, "row 2 does not exist", err.Error())
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+}
+
+func TestTableTransactionInsertDuplicateRow(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	tx := table.Begin()
+	tx.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+
+	err := tx.InsertRow(NewRow(1, "Ron", "ron@hogwarts.edu"))
+	assert.Equal(t, ErrDuplicateKey{Key: 1}, err)
+	err = tx.InsertRows([]*Row{NewRow(2, "Ron", "ron@hogwarts.edu"), NewRow(1, "Ron", "ron@hogwarts.edu")})
+	assert.Equal(t, "id 1 already exists", err.Error())
+	err = tx.InsertRows([]*Row{NewRow(3, "Hermione", "hermione@hogwarts.edu"), NewRow(3, "Ginny", "ginny@hogwarts.edu")})
+	assert.Equal(t, ErrDuplicateKey{Key: 3}, err)
+	tx.Commit()
+
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, "Harry", rows[0].Username())
+	assert.Equal(t, 1, table.NumRows())
+}
+
+func TestTableConcurrentWrites(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id := uint32(i*20 + j + 1)
+				table.InsertRow(NewRow(id, "user", fmt.Sprintf("user%d@test.com", id)))
+				table.IndexScan(&IndexCondition{Target: id, Operator: "="}, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 160, len(rows))
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestTableTransactionDoesNotBlockReaders(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	table.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+	tx := table.Begin()
+	rowsBeforeWrite, _ := tx.SeqScan(nil)
+
+	tx.InsertRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+
+	rows, err := table.SeqScan(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, 1, table.NumRows())
+	rowsInTx, _ := tx.SeqScan(nil)
+	assert.Equal(t, 1, len(rowsBeforeWrite))
+	assert.Equal(t, 2, len(rowsInTx))
+	assert.Equal(t, 2, tx.NumRows())
+	assert.Nil(t, tx.Commit())
+	rows, _ = table.SeqScan(nil)
+	assert.Equal(t, 2, len(rows))
+}
+
+func TestTableScansDuringTransaction(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	for i := 1; i <= 100; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+
+	done := make(chan bool)
+	counts := make(chan int, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for true {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				rows, err := table.SeqScan(nil)
+				assert.Nil(t, err)
+				select {
+				case counts <- len(rows):
+				default:
+				}
+			}
+		}()
+	}
+
+	// the inserts split the root, readers keep reading the committed tree
+	tx := table.Begin()
+	for i := 101; i <= 600; i++ {
+		assert.Nil(t, tx.InsertRow(NewRow(uint32(i), "user", "user@test.com")))
+	}
+	assert.Nil(t, tx.Commit())
+	close(done)
+	wg.Wait()
+	close(counts)
+
+	for count := range counts {
+		assert.True(t, count == 100 || count == 600, count)
+	}
+	assert.Equal(t, 600, table.NumRows())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestFullBufferPoolRollsBackStatement(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	defer table.CloseTable()
+	table.InsertRow(NewRow(1, "user", "user@test.com"))
+
+	// a transaction pins every page it touches
+	tx := table.Begin()
+	var err error
+	numRows := 1
+	for i := 2; err == nil && i < 10000; i++ {
+		err = tx.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+		if err == nil {
+			numRows++
+		}
+	}
+
+	assert.Equal(t, "statement is rolled back: no victim to evict", err.Error())
+	assert.Equal(t, false, tx.Closed())
+	assert.Equal(t, numRows, tx.NumRows())
+	assert.Nil(t, tx.Commit())
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, numRows, len(rows))
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestTableTransactionWriteFailsAfterBusyTimeout(t *testing.T) {
+	removeTestFile()
+	options := DefaultTableOptions()
+	options.BusyTimeout = 10 * time.Millisecond
+	table, _ := OpenTableWithOptions(getTestFileName(), options)
+	defer table.CloseTable()
+	writer := table.Begin()
+	writer.InsertRow(NewRow(1, "Harry", "harry@hogwarts.edu"))
+	tx := table.Begin()
+
+	err := tx.InsertRow(NewRow(2, "Ron", "ron@hogwarts.edu"))
+
+	assert.Equal(t, ErrLocked, err)
+	assert.Equal(t, false, tx.Closed())
+	assert.Equal(t, ErrLocked, table.Vacuum())
+	writer.Commit()
+	assert.Nil(t, tx.InsertRow(NewRow(2, "Ron", "ron@hogwarts.edu")))
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, 2, table.NumRows())
+}
+
+func TestTableTransactionFailedStatementReleasesNewPages(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTableWithOptions(getTestFileName(), &TableOptions{
+		PageSize: DEFAULT_PAGE_SIZE,
+		MaxSize:  8 * DEFAULT_PAGE_SIZE,
+	})
+	// every row spills into two overflow pages, the second statement appends
+	// two pages before the database is full
+	email := strings.Repeat("e", 255+DEFAULT_PAGE_SIZE)
+	tx := table.Begin()
+	tx.InsertRow(NewRow(1, "harry", "harry@hogwarts.edu"))
+	var err error
+	numRows := 1
+	for i := 2; i <= 100 && err == nil; i++ {
+		err = tx.InsertRows([]*Row{NewRow(uint32(i*2), "user", email), NewRow(uint32(i*2+1), "user", email)})
+		if err == nil {
+			numRows += 2
+		}
+	}
+
+	assert.Equal(t, "statement is rolled back: database is full", err.Error())
+	assert.Equal(t, numRows, tx.NumRows())
+	assert.Nil(t, tx.Commit())
+	problems, err := table.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	// the database is full, the pages of the failed statement are reused
+	assert.Nil(t, table.InsertRow(NewRow(1000, "user", strings.Repeat("e", 300))))
+	problems, _ = table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	table.CloseTable()
+	table, _ = OpenTable(getTestFileName())
+	defer table.CloseTable()
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, numRows+1, len(rows))
+}
@@ -1,7 +1,7 @@
 package core
 
 type transactionPage struct {
-	page     *PageBody
+	page     PageBody
 	snapshot *Page
 }
 
@@ -13,6 +13,18 @@ type Transaction struct {
 	id         int32
 	pageTable  map[uint32]*transactionPage
 	bufferPool *BufferPool
+	// newPageIDs are the pages appended to the database by NewPage, they are
+	// released to the free list when the transaction is rolled back.
+	newPageIDs []uint32
+	savepoint  *savepoint
+}
+
+// savepoint keeps the pages as they were before a statement, a page which
+// was not in the transaction yet is kept as nil. The pages appended since
+// are newPageIDs[numNewPages:].
+type savepoint struct {
+	pages       map[uint32]*Page
+	numNewPages int
 }
 
 func NewTransaction(id int32, bufferPool *BufferPool) *Transaction {
@@ -28,26 +40,26 @@ func (t *Transaction) ID() int32 {
 	return t.id
 }
 
-func EmptySnapshotPage() *Page {
-	body := emptyPageBody()
+func EmptySnapshotPage(pageSize int) *Page {
 	return &Page{
-		body:    &body,
+		body:    newPageBody(pageSize),
 		isDirty: false,
 	}
 }
 
 func (t *Transaction) ReadPage(pageID uint32) (*Page, error) {
+	t.keep(pageID)
 	if t.pageTable[pageID] == nil {
 		page, err := t.bufferPool.FetchPage(pageID)
 		if err != nil {
 			return nil, err
 		}
 
-		body := emptyPageBody()
-		copy(body[:], page[:])
+		body := newPageBody(len(page))
+		copy(body, page)
 		snapshot := &Page{
 			id:      pageID,
-			body:    &body,
+			body:    body,
 			isDirty: false,
 		}
 
@@ -61,17 +73,17 @@ func (t *Transaction) ReadPage(pageID uint32) (*Page, error) {
 
 func (t *Transaction) NewPage() (*Page, error) {
 	page, err := t.bufferPool.NewPage()
-	pageID := page.id
-	body := page.body
 	if err != nil {
 		return nil, err
 	}
+	pageID := page.id
+	body := page.body
 
-	snapshotBody := emptyPageBody()
-	copy(snapshotBody[:], body[:])
+	snapshotBody := newPageBody(len(body))
+	copy(snapshotBody, body)
 	snapshot := &Page{
 		id:      pageID,
-		body:    &snapshotBody,
+		body:    snapshotBody,
 		isDirty: true,
 	}
 
@@ -79,13 +91,78 @@ func (t *Transaction) NewPage() (*Page, error) {
 		page:     body,
 		snapshot: snapshot,
 	}
+	t.newPageIDs = append(t.newPageIDs, pageID)
 	return t.pageTable[pageID].snapshot, nil
 }
 
+// setSavepoint starts recording the pages the next statement reads, so the
+// statement can be undone by rollbackToSavepoint.
+func (t *Transaction) setSavepoint() {
+	t.savepoint = &savepoint{
+		pages:       make(map[uint32]*Page),
+		numNewPages: len(t.newPageIDs),
+	}
+}
+
+func (t *Transaction) releaseSavepoint() {
+	t.savepoint = nil
+}
+
+// keep copies the page the first time it is read after the savepoint.
+func (t *Transaction) keep(pageID uint32) {
+	if t.savepoint == nil {
+		return
+	}
+	_, ok := t.savepoint.pages[pageID]
+	if ok {
+		return
+	}
+	tp := t.pageTable[pageID]
+	if tp == nil {
+		t.savepoint.pages[pageID] = nil
+		return
+	}
+	body := newPageBody(len(tp.snapshot.body))
+	copy(body, tp.snapshot.body)
+	t.savepoint.pages[pageID] = &Page{
+		id:      pageID,
+		body:    body,
+		isDirty: tp.snapshot.isDirty,
+	}
+}
+
+// rollbackToSavepoint restores the pages kept by the savepoint and unpins
+// the pages read since, it returns the pages appended since which are left
+// pinned and have to be freed by the caller.
+func (t *Transaction) rollbackToSavepoint() []uint32 {
+	sp := t.savepoint
+	t.savepoint = nil
+	for pageID, page := range sp.pages {
+		tp := t.pageTable[pageID]
+		if tp == nil {
+			continue
+		}
+		if page == nil {
+			t.bufferPool.UnpinPage(pageID, false)
+			delete(t.pageTable, pageID)
+			continue
+		}
+		copy(tp.snapshot.body, page.body)
+		tp.snapshot.isDirty = page.isDirty
+	}
+	return t.newPageIDs[sp.numNewPages:]
+}
+
+// full reports whether the transaction pinned half of the buffer pool, a long
+// batch of writes is committed then so the pool does not run out of frames.
+func (t *Transaction) full() bool {
+	return len(t.pageTable) >= t.bufferPool.maxPageNum/2
+}
+
 func (t *Transaction) Commit() {
 	for pageID, tp := range t.pageTable {
 		if tp.snapshot.isDirty {
-			copy(t.pageTable[pageID].page[:], t.pageTable[pageID].snapshot.body[:])
+			copy(t.pageTable[pageID].page, t.pageTable[pageID].snapshot.body)
 		}
 		t.bufferPool.UnpinPage(pageID, tp.isDirty())
 	}
@@ -1,98 +1,63 @@
 package core
 
-import (
-	"encoding/binary"
-	"fmt"
-	"os"
-)
-
 type TransactionNoder struct {
 	transaction *Transaction
 }
 
-func (n *TransactionNoder) Read(nodeID uint32) Node {
+// Read returns the node stored in the page, a page which is not a node is
+// reported as corrupted.
+func (n *TransactionNoder) Read(nodeID uint32) (Node, error) {
 	page, err := n.transaction.ReadPage(nodeID)
-	// TODO: handle error with better way later
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-		return nil
+		return nil, err
 	}
-	bs := page.body[:PAGE_TYPE_SIZE]
-	pageType := binary.LittleEndian.Uint16(bs)
+	pageType := codec.pageType(page.body)
 
 	var node Node
 	if pageType == PAGE_TYPE_INTERNAL_NODE {
-		node = deserializeInternalNodeFromPage(nodeID, page)
+		node, err = deserializeInternalNodeFromPage(nodeID, page)
 	} else if pageType == PAGE_TYPE_LEAF_NODE {
-		node = deserializeLeafNodeFromPage(nodeID, page)
+		node, err = deserializeLeafNodeFromPage(nodeID, page)
 	} else {
-		fmt.Println("You can't convert unknwon page to node")
-		os.Exit(1)
-		return nil
+		err = ErrCorruptPage{PageID: nodeID}
 	}
-
-	return node
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
 }
 
-func deserializeInternalNodeFromPage(nodeID uint32, page *Page) *InternalNode {
-	keys := []uint32{}
-	children := []uint32{}
-	from := PAGE_TYPE_SIZE
-	bs := page.body[from : from+INTERNAL_NODE_NUM_KEYS_SIZE]
-	numKeys := binary.LittleEndian.Uint32(bs)
-	from = INTERNAL_NODE_FIRST_CHILD_OFFSET
-
-	bs = page.body[from : from+INTERNAL_NODE_CHILD_SIZE]
-	children = append(children, binary.LittleEndian.Uint32(bs))
-	from = from + INTERNAL_NODE_CHILD_SIZE
-
-	for i := 0; i < int(numKeys); i++ {
-		bs := page.body[from : from+INTERNAL_NODE_KEY_SIZE]
-
-		keys = append(keys, binary.LittleEndian.Uint32(bs))
-		from = from + INTERNAL_NODE_KEY_SIZE
-
-		bs = page.body[from : from+INTERNAL_NODE_CHILD_SIZE]
-		children = append(children, binary.LittleEndian.Uint32(bs))
-		from = from + INTERNAL_NODE_CHILD_SIZE
+func deserializeInternalNodeFromPage(nodeID uint32, page *Page) (*InternalNode, error) {
+	keys, children, err := codec.decodeInternalNode(nodeID, page.body)
+	if err != nil {
+		return nil, err
 	}
-
 	return &InternalNode{
 		id:       nodeID,
 		keys:     keys,
 		children: children,
 		page:     page,
-	}
+	}, nil
 }
 
-func deserializeLeafNodeFromPage(nodeId uint32, page *Page) *LeafNode {
-	tuples := []*Tuple{}
-	from := PAGE_TYPE_SIZE
-	bs := page.body[from : from+LEAF_NODE_NUM_TUPLE_SIZE]
-	numTuples := binary.LittleEndian.Uint32(bs)
-	from = LEAF_NODE_FIRST_CHILD_OFFSET
-
-	for i := 0; i < int(numTuples); i++ {
-		bs := page.body[from : from+ROW_SIZE]
-		key := binary.LittleEndian.Uint32(bs[:4])
-		tuples = append(tuples, &Tuple{key: key, value: bs})
-		from = from + ROW_SIZE
+func deserializeLeafNodeFromPage(nodeID uint32, page *Page) (*LeafNode, error) {
+	prevNodeID, nextNodeID, tuples, err := codec.decodeLeafNode(nodeID, page.body)
+	if err != nil {
+		return nil, err
 	}
-
 	return &LeafNode{
-		id:     nodeId,
-		tuples: tuples,
-		page:   page,
-	}
+		id:         nodeID,
+		prevNodeID: prevNodeID,
+		nextNodeID: nextNodeID,
+		tuples:     tuples,
+		page:       page,
+	}, nil
 }
 
-func (n *TransactionNoder) NewLeafNode(tuples []*Tuple) *LeafNode {
-	page, err := n.transaction.NewPage()
+func (n *TransactionNoder) NewLeafNode(tuples []*Tuple) (*LeafNode, error) {
+	page, err := allocatePage(n.transaction)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-		return nil
+		return nil, err
 	}
 	node := &LeafNode{
 		id:     page.id,
@@ -100,15 +65,13 @@ func (n *TransactionNoder) NewLeafNode(tuples []*Tuple) *LeafNode {
 		page:   page,
 	}
 	node.syncBytes()
-	return node
+	return node, nil
 }
 
-func (n *TransactionNoder) NewInternalNode(keys []uint32, children []uint32) *InternalNode {
-	page, err := n.transaction.NewPage()
+func (n *TransactionNoder) NewInternalNode(keys []uint32, children []uint32) (*InternalNode, error) {
+	page, err := allocatePage(n.transaction)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-		return nil
+		return nil, err
 	}
 	node := &InternalNode{
 		id:       page.id,
@@ -117,5 +80,5 @@ func (n *TransactionNoder) NewInternalNode(keys []uint32, children []uint32) *In
 		page:     page,
 	}
 	node.syncBytes()
-	return node
+	return node, nil
 }
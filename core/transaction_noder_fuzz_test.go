@@ -0,0 +1,86 @@
+//go:build go1.18
+// +build go1.18
+
+package core
+
+import (
+	"testing"
+)
+
+// fuzzPage copies data into an empty page, the smallest page size keeps the
+// counts the fuzzer has to hit small.
+func fuzzPage(data []byte) *Page {
+	page := EmptyPage(MIN_PAGE_SIZE)
+	copy(page.body, data)
+	return page
+}
+
+// FuzzDeserializeInternalNode checks a page either fails to decode or
+// decodes into a node which encodes back into the same node, run it with
+// go test -fuzz FuzzDeserializeInternalNode ./core.
+func FuzzDeserializeInternalNode(f *testing.F) {
+	page := EmptyPage(MIN_PAGE_SIZE)
+	codec.encodeInternalNode(page.body, []uint32{5, 9}, []uint32{2, 3, 4})
+	f.Add([]byte(page.body))
+	codec.encodeInternalNode(page.body, []uint32{}, []uint32{})
+	f.Add([]byte(page.body))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		node, err := deserializeInternalNodeFromPage(3, fuzzPage(data))
+		if err != nil {
+			return
+		}
+		if len(node.children) != len(node.keys)+1 {
+			t.Fatalf("%d keys and %d children", len(node.keys), len(node.children))
+		}
+		encoded := EmptyPage(MIN_PAGE_SIZE)
+		codec.encodeInternalNode(encoded.body, node.keys, node.children)
+		decoded, err := deserializeInternalNodeFromPage(3, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for idx := range node.children {
+			if idx < len(node.keys) && decoded.keys[idx] != node.keys[idx] {
+				t.Fatalf("key %d is %d, expected %d", idx, decoded.keys[idx], node.keys[idx])
+			}
+			if decoded.children[idx] != node.children[idx] {
+				t.Fatalf("child %d is %d, expected %d", idx, decoded.children[idx], node.children[idx])
+			}
+		}
+	})
+}
+
+// FuzzDeserializeLeafNode checks a page either fails to decode or decodes
+// into tuples which are decoded as rows without panicking, and which encode
+// back into the same page.
+func FuzzDeserializeLeafNode(f *testing.F) {
+	page := EmptyPage(MIN_PAGE_SIZE)
+	tuples := []*Tuple{
+		{key: 1, value: NewRow(1, "Harry", "harry@hogwarts.edu").Bytes()},
+		{key: 2, value: codec.encodeRow(NewRow(2, "Ron", "ron"), 7)},
+	}
+	codec.encodeLeafNode(page.body, 4, 5, tuples)
+	f.Add([]byte(page.body))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		node, err := deserializeLeafNodeFromPage(3, fuzzPage(data))
+		if err != nil {
+			return
+		}
+		for _, tuple := range node.tuples {
+			row, _, err := codec.decodeRow(tuple.value)
+			if err == nil && row.id != tuple.key {
+				t.Fatalf("row %d is in tuple %d", row.id, tuple.key)
+			}
+		}
+		encoded := EmptyPage(MIN_PAGE_SIZE)
+		codec.encodeLeafNode(encoded.body, node.prevNodeID, node.nextNodeID, node.tuples)
+		decoded, err := deserializeLeafNodeFromPage(3, encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.prevNodeID != node.prevNodeID || decoded.nextNodeID != node.nextNodeID || sameTuples(decoded.tuples, node.tuples) == false {
+			t.Fatal("leaf node does not encode back into the same node")
+		}
+	})
+}
@@ -29,7 +29,7 @@ func TestMain(m *testing.M) {
 }
 
 func TestTxNoderReadInternalNode(t *testing.T) {
-	page := EmptyPage()
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	pageTypeBytes := make([]byte, 2)
 	binary.LittleEndian.PutUint16(pageTypeBytes, uint16(PAGE_TYPE_INTERNAL_NODE))
 	copy(page.body[0:2], pageTypeBytes)
@@ -42,7 +42,7 @@ func TestTxNoderReadInternalNode(t *testing.T) {
 	keys := []uint32{5}
 	children := []uint32{4, 5}
 	internalNode.Update(keys, children)
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	pager := &DummyPager{body: append(page0[:], internalNode.page.body[:]...)}
 	replacer := NewDummyReplacer()
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -50,8 +50,9 @@ func TestTxNoderReadInternalNode(t *testing.T) {
 	noder := &TransactionNoder{transaction: tx}
 	nodeID := uint32(1)
 
-	node := noder.Read(nodeID)
+	node, err := noder.Read(nodeID)
 
+	assert.Nil(t, err)
 	assert.Equal(t, "InternalNode", node.NodeType())
 	assert.Equal(t, uint32(1), node.ID())
 	assert.Equal(t, keys, node.Keys())
@@ -59,7 +60,7 @@ func TestTxNoderReadInternalNode(t *testing.T) {
 }
 
 func TestTxNoderReadLeafNode(t *testing.T) {
-	page := EmptyPage()
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
 	pageTypeBytes := make([]byte, 2)
 	binary.LittleEndian.PutUint16(pageTypeBytes, uint16(PAGE_TYPE_LEAF_NODE))
 	copy(page.body[0:2], pageTypeBytes)
@@ -71,7 +72,7 @@ func TestTxNoderReadLeafNode(t *testing.T) {
 	tuple1 := &Tuple{row1.Id(), row1.Bytes()}
 	tuples := []*Tuple{tuple1}
 	leafNode.Update(tuples, leafNode.PrevNodeID(), leafNode.NextNodeID())
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	pager := &DummyPager{body: append(page0[:], leafNode.page.body[:]...)}
 	replacer := NewDummyReplacer()
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -79,15 +80,16 @@ func TestTxNoderReadLeafNode(t *testing.T) {
 	noder := &TransactionNoder{transaction: tx}
 	nodeID := uint32(1)
 
-	node := noder.Read(nodeID).(*LeafNode)
+	node, err := readLeafNode(nodeID, noder)
 
+	assert.Nil(t, err)
 	assert.Equal(t, "LeafNode", node.NodeType())
 	assert.Equal(t, uint32(1), node.ID())
 	assert.Equal(t, tuples, node.tuples)
 }
 
 func TestTxNoderNewLeafNode(t *testing.T) {
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	pager := &DummyPager{body: page0[:]}
 	replacer := NewDummyReplacer()
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -97,14 +99,15 @@ func TestTxNoderNewLeafNode(t *testing.T) {
 	tuple1 := &Tuple{row1.Id(), row1.Bytes()}
 	tuples := []*Tuple{tuple1}
 
-	node := noder.NewLeafNode(tuples)
+	node, err := noder.NewLeafNode(tuples)
 
+	assert.Nil(t, err)
 	assert.Equal(t, uint32(1), node.ID())
 	assert.Equal(t, tuples, node.tuples)
 }
 
 func TestTxNoderNewInternalNode(t *testing.T) {
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	pager := &DummyPager{body: page0[:]}
 	replacer := NewDummyReplacer()
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -113,9 +116,44 @@ func TestTxNoderNewInternalNode(t *testing.T) {
 	keys := []uint32{5}
 	children := []uint32{4, 5}
 
-	node := noder.NewInternalNode(keys, children)
+	node, err := noder.NewInternalNode(keys, children)
 
+	assert.Nil(t, err)
 	assert.Equal(t, uint32(1), node.ID())
 	assert.Equal(t, keys, node.keys)
 	assert.Equal(t, children, node.children)
 }
+
+func TestDeserializeLeafNodeWithCorruptNumTuples(t *testing.T) {
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
+	copy(page.body[LEAF_NODE_NUM_TUPLES_OFFSET:], convertUint32ToBytes(uint32(leafNodeKeyPerPage(DEFAULT_PAGE_SIZE)+1)))
+
+	node, err := deserializeLeafNodeFromPage(3, page)
+
+	assert.Nil(t, node)
+	assert.Equal(t, ErrCorruptPage{PageID: 3}, err)
+}
+
+func TestDeserializeInternalNodeWithCorruptNumKeys(t *testing.T) {
+	page := EmptyPage(DEFAULT_PAGE_SIZE)
+	copy(page.body[INTERNAL_NODE_NUM_KEYS_OFFSET:], convertUint32ToBytes(uint32(internalNodeKeyPerPage(DEFAULT_PAGE_SIZE)+1)))
+
+	node, err := deserializeInternalNodeFromPage(3, page)
+
+	assert.Nil(t, node)
+	assert.Equal(t, ErrCorruptPage{PageID: 3}, err)
+}
+
+func TestTxNoderReadPageWhichIsNotNode(t *testing.T) {
+	page := newPageBody(DEFAULT_PAGE_SIZE)
+	binary.LittleEndian.PutUint16(page[0:2], uint16(PAGE_TYPE_OVERFLOW))
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
+	pager := &DummyPager{body: append(page0[:], page[:]...)}
+	bufferPool := NewBufferPool(NewDummyReplacer(), pager, 5, 100)
+	noder := &TransactionNoder{transaction: NewTransaction(1, bufferPool)}
+
+	node, err := noder.Read(1)
+
+	assert.Nil(t, node)
+	assert.Equal(t, ErrCorruptPage{PageID: 1}, err)
+}
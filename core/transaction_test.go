@@ -11,7 +11,7 @@ func TestReadPage(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(page0[:], expectedPage[:]...)}
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -20,7 +20,7 @@ func TestReadPage(t *testing.T) {
 	page, err := tx.ReadPage(uint32(1))
 
 	assert.Nil(t, err)
-	assert.Equal(t, expectedPage, *page.body)
+	assert.Equal(t, expectedPage, page.body)
 }
 
 func TestReadPageFromCache(t *testing.T) {
@@ -28,7 +28,7 @@ func TestReadPageFromCache(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(page0[:], expectedPage[:]...)}
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -39,7 +39,7 @@ func TestReadPageFromCache(t *testing.T) {
 	page, err := tx.ReadPage(pageID)
 
 	assert.Nil(t, err)
-	assert.Equal(t, expectedPage, *page.body)
+	assert.Equal(t, expectedPage, page.body)
 	assert.Equal(t, int32(1), bufferPool.pageTable[pageID].referenceCount)
 }
 
@@ -48,7 +48,7 @@ func TestTransactionNewPage(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(page0[:], expectedPage[:]...)}
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -57,7 +57,7 @@ func TestTransactionNewPage(t *testing.T) {
 	page, err := tx.NewPage()
 
 	assert.Nil(t, err)
-	assert.Equal(t, emptyPageBody(), *page.body)
+	assert.Equal(t, newPageBody(DEFAULT_PAGE_SIZE), page.body)
 	assert.Equal(t, 2, int(page.id))
 	assert.Equal(t, true, page.isDirty)
 }
@@ -66,7 +66,7 @@ func TestTransactionCommit(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(page0[:], expectedPage[:]...)}
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
@@ -89,7 +89,7 @@ func TestTransactionRollback(t *testing.T) {
 		frameIndices: []uint32{},
 		pinnedIdxMap: make(map[uint32]bool),
 	}
-	page0 := emptyPageBody()
+	page0 := newPageBody(DEFAULT_PAGE_SIZE)
 	expectedPage := createPageFromSlice([]byte{1, 2, 3, 4, 5})
 	pager := &DummyPager{body: append(page0[:], expectedPage[:]...)}
 	bufferPool := NewBufferPool(replacer, pager, 5, 100)
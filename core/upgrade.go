@@ -0,0 +1,409 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// migration returns the function upgrading a database file from version to
+// FORMAT_VERSION, nil if there is no such migration.
+func migration(version uint32) func(fileName string) error {
+	switch version {
+	case 0:
+		return migrateFromVersion0
+	case 1:
+		return migrateFromVersion1
+	case 2:
+		return migrateFromVersion2
+	default:
+		return nil
+	}
+}
+
+// upgradeFile migrates a database file written by an older version of sqlbit
+// to FORMAT_VERSION, it is a no-op for files already in FORMAT_VERSION.
+func upgradeFile(fileName string) error {
+	version, err := readFormatVersion(fileName)
+	if err != nil {
+		return err
+	}
+
+	if version < FORMAT_VERSION {
+		migrate := migration(version)
+		if migrate == nil {
+			message := fmt.Sprintf("unsupported format version %d", version)
+			return errors.New(message)
+		}
+		err = migrate(fileName)
+		if err != nil {
+			message := fmt.Sprintf("failed to upgrade from format version %d: %s", version, err)
+			return errors.New(message)
+		}
+	}
+	return nil
+}
+
+func readFormatVersion(fileName string) (uint32, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	bs, err := readLegacyPage(f, 0)
+	if err != nil {
+		return 0, err
+	}
+	if hasTableHeaderMagic(bs) {
+		header, err := deserializeTableHeader(bs)
+		if err != nil {
+			return 0, err
+		}
+		return header.formatVersion, nil
+	}
+	if isVersion0Header(f, bs) {
+		return 0, nil
+	}
+	return 0, errors.New("file is not a sqlbit database")
+}
+
+// Files before version 2 always used 4096 bytes pages.
+const LEGACY_PAGE_SIZE = 4096
+
+// readLegacyPage reads a page without going through the buffer pool, missing
+// bytes at the end of the file are read as zero.
+func readLegacyPage(f *os.File, pageID uint32) ([]byte, error) {
+	bs := make([]byte, LEGACY_PAGE_SIZE)
+	_, err := f.ReadAt(bs, int64(pageID)*LEGACY_PAGE_SIZE)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// Version 0 and 1 files were written before pages had a checksum.
+// Internal Node: PAGE_TYPE(2 bytes), NUM_KEYS(4 bytes), Child1(4 bytes), Key1(4 bytes), ...
+// Leaf Node: PAGE_TYPE(2 bytes), NUM_TUPLES(4 bytes), PREV_NODE_ID(4 bytes), NEXT_NODE_ID(4 bytes), Child1, ...
+// Overflow Page: PAGE_TYPE(2 bytes), NEXT_PAGE_ID(4 bytes), DATA_SIZE(4 bytes), DATA
+const VERSION_1_INTERNAL_NODE_FIRST_CHILD_OFFSET = 6
+const VERSION_1_LEAF_NODE_NUM_TUPLES_OFFSET = 2
+const VERSION_1_LEAF_NODE_PREV_NODE_ID_OFFSET = 6
+const VERSION_1_LEAF_NODE_NEXT_NODE_ID_OFFSET = 10
+const VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET = 14
+const VERSION_1_OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET = 2
+const VERSION_1_OVERFLOW_PAGE_DATA_SIZE_OFFSET = 6
+const VERSION_1_OVERFLOW_PAGE_HEADER_SIZE = 10
+
+// Version 0 files were written before the table header had a magic string.
+// Table Header: PAGE_TYPE(2 bytes), ROOT_PAGE_NUM(4 bytes)
+// Row: ID(4 bytes), USERNAME(32 bytes), EMAIL(255 bytes) padded with NUL
+const VERSION_0_ROOT_PAGE_NUM_OFFSET = PAGE_TYPE_SIZE
+const VERSION_0_ROW_SIZE = 291
+const VERSION_0_USERNAME_OFFSET = 4
+const VERSION_0_USERNAME_LENGTH = 32
+const VERSION_0_EMAIL_OFFSET = VERSION_0_USERNAME_OFFSET + VERSION_0_USERNAME_LENGTH
+const VERSION_0_EMAIL_LENGTH = 255
+
+func isVersion0Header(f *os.File, bs []byte) bool {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() < LEGACY_PAGE_SIZE+VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET {
+		return false
+	}
+	if binary.LittleEndian.Uint16(bs[:PAGE_TYPE_SIZE]) != PAGE_TYPE_TABLE_HEADER {
+		return false
+	}
+	rootPageNum := binary.LittleEndian.Uint32(bs[VERSION_0_ROOT_PAGE_NUM_OFFSET:])
+	numPages := (fi.Size() + LEGACY_PAGE_SIZE - 1) / LEGACY_PAGE_SIZE
+	return rootPageNum > 0 && int64(rootPageNum) < numPages
+}
+
+// readLegacyRows collects the rows of a version 0 or 1 file by walking the
+// leaf nodes from the left most one. Version 0 never persisted a new root
+// after a split, but the left most leaf always kept its page, so the sibling
+// links still reach every row.
+func readLegacyRows(f *os.File, rootPageNum uint32, rowSize int, decode func(bs []byte) (*Row, error)) ([]*Row, error) {
+	pageID := rootPageNum
+	visited := make(map[uint32]bool)
+	rows := []*Row{}
+	for pageID != 0 {
+		if visited[pageID] {
+			message := fmt.Sprintf("page %d is visited twice", pageID)
+			return nil, errors.New(message)
+		}
+		visited[pageID] = true
+
+		bs, err := readLegacyPage(f, pageID)
+		if err != nil {
+			return nil, err
+		}
+		pageType := binary.LittleEndian.Uint16(bs[:PAGE_TYPE_SIZE])
+		switch pageType {
+		case PAGE_TYPE_INTERNAL_NODE:
+			pageID = binary.LittleEndian.Uint32(bs[VERSION_1_INTERNAL_NODE_FIRST_CHILD_OFFSET:])
+		case PAGE_TYPE_LEAF_NODE:
+			numTuples := int(binary.LittleEndian.Uint32(bs[VERSION_1_LEAF_NODE_NUM_TUPLES_OFFSET:]))
+			if VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET+numTuples*rowSize > LEGACY_PAGE_SIZE {
+				message := fmt.Sprintf("leaf node %d has too many tuples", pageID)
+				return nil, errors.New(message)
+			}
+			from := VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET
+			for i := 0; i < numTuples; i++ {
+				row, err := decode(bs[from : from+rowSize])
+				if err != nil {
+					return nil, err
+				}
+				rows = append(rows, row)
+				from = from + rowSize
+			}
+			pageID = binary.LittleEndian.Uint32(bs[VERSION_1_LEAF_NODE_NEXT_NODE_ID_OFFSET:])
+		default:
+			message := fmt.Sprintf("page %d has unknown page type %d", pageID, pageType)
+			return nil, errors.New(message)
+		}
+	}
+	return rows, nil
+}
+
+func version0RowFromBytes(bs []byte) (*Row, error) {
+	id := binary.LittleEndian.Uint32(bs)
+	username := bs[VERSION_0_USERNAME_OFFSET : VERSION_0_USERNAME_OFFSET+VERSION_0_USERNAME_LENGTH]
+	email := bs[VERSION_0_EMAIL_OFFSET : VERSION_0_EMAIL_OFFSET+VERSION_0_EMAIL_LENGTH]
+	return NewRow(id, strings.TrimRight(string(username), "\x00"), strings.TrimRight(string(email), "\x00")), nil
+}
+
+func migrateFromVersion0(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	bs, err := readLegacyPage(f, 0)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rootPageNum := binary.LittleEndian.Uint32(bs[VERSION_0_ROOT_PAGE_NUM_OFFSET:])
+	rows, err := readLegacyRows(f, rootPageNum, VERSION_0_ROW_SIZE, version0RowFromBytes)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	return rebuildFile(fileName, rows)
+}
+
+// readVersion1Overflow follows an overflow chain of a version 1 file.
+func readVersion1Overflow(f *os.File, pageID uint32, size int) ([]byte, error) {
+	data := []byte{}
+	visited := make(map[uint32]bool)
+	for pageID != 0 && len(data) < size {
+		if visited[pageID] {
+			message := fmt.Sprintf("overflow chain visits page %d twice", pageID)
+			return nil, errors.New(message)
+		}
+		visited[pageID] = true
+		bs, err := readLegacyPage(f, pageID)
+		if err != nil {
+			return nil, err
+		}
+		if binary.LittleEndian.Uint16(bs[:PAGE_TYPE_SIZE]) != PAGE_TYPE_OVERFLOW {
+			message := fmt.Sprintf("page %d is not an overflow page", pageID)
+			return nil, errors.New(message)
+		}
+		dataSize := int(binary.LittleEndian.Uint32(bs[VERSION_1_OVERFLOW_PAGE_DATA_SIZE_OFFSET:]))
+		if VERSION_1_OVERFLOW_PAGE_HEADER_SIZE+dataSize > LEGACY_PAGE_SIZE {
+			message := fmt.Sprintf("overflow page %d has invalid data size %d", pageID, dataSize)
+			return nil, errors.New(message)
+		}
+		data = append(data, bs[VERSION_1_OVERFLOW_PAGE_HEADER_SIZE:VERSION_1_OVERFLOW_PAGE_HEADER_SIZE+dataSize]...)
+		pageID = binary.LittleEndian.Uint32(bs[VERSION_1_OVERFLOW_PAGE_NEXT_PAGE_ID_OFFSET:])
+	}
+	if len(data) != size {
+		message := fmt.Sprintf("overflow chain has %d bytes, expected %d", len(data), size)
+		return nil, errors.New(message)
+	}
+	return data, nil
+}
+
+func migrateFromVersion1(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bs, err := readLegacyPage(f, 0)
+	if err != nil {
+		return err
+	}
+	header, err := deserializeTableHeader(bs)
+	if err != nil {
+		return err
+	}
+	decode := func(bs []byte) (*Row, error) {
+		row, overflow, err := codec.decodeRow(bs)
+		if err != nil {
+			return nil, err
+		}
+		if overflow.size() > 0 {
+			data, err := readVersion1Overflow(f, overflow.pageID, overflow.size())
+			if err != nil {
+				return nil, err
+			}
+			overflow.complete(row, data)
+		}
+		return row, nil
+	}
+	rows, err := readLegacyRows(f, header.rootPageNum, ROW_SIZE, decode)
+	if err != nil {
+		return err
+	}
+
+	return rebuildFile(fileName, rows)
+}
+
+// rebuildFile writes rows into a new database file next to fileName, and
+// then replaces fileName with it.
+func rebuildFile(fileName string, rows []*Row) error {
+	tmpFileName := fileName + ".upgrade"
+	os.Remove(tmpFileName)
+
+	table, err := OpenTable(tmpFileName)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		err = table.InsertRow(row)
+		if err != nil {
+			table.CloseTable()
+			os.Remove(tmpFileName)
+			return err
+		}
+	}
+	err = table.CloseTable()
+	if err != nil {
+		os.Remove(tmpFileName)
+		return err
+	}
+	return os.Rename(tmpFileName, fileName)
+}
+
+// Version 2 files did not record the row count, the checksum of the table
+// header was stored where ROW_COUNT is now.
+const VERSION_2_TABLE_HEADER_CHECKSUM_OFFSET = TABLE_HEADER_ROW_COUNT_OFFSET
+
+// readVersion2Page reads a page of a version 2 file, every page except the
+// table header has the same layout and checksum as in FORMAT_VERSION.
+func readVersion2Page(f *os.File, pageSize int, pageID uint32) (*Page, error) {
+	page := EmptyPage(pageSize)
+	page.id = pageID
+	n, err := f.ReadAt(page.body, int64(pageID)*int64(pageSize))
+	if n < pageSize {
+		if err == nil || err == io.EOF {
+			return nil, ErrCorruptPage{PageID: pageID}
+		}
+		return nil, err
+	}
+	if pageID == 0 {
+		offset := VERSION_2_TABLE_HEADER_CHECKSUM_OFFSET
+		checksum := crc32.Update(0, crc32cTable, page.body[:offset])
+		checksum = crc32.Update(checksum, crc32cTable, page.body[offset+PAGE_CHECKSUM_SIZE:])
+		if binary.LittleEndian.Uint32(page.body[offset:]) != checksum {
+			return nil, ErrCorruptPage{PageID: pageID}
+		}
+		return page, nil
+	}
+	return page, verifyPageChecksum(pageID, page.body)
+}
+
+// countVersion2Rows descends to the left most leaf and counts the tuples of
+// every leaf by following the sibling links.
+func countVersion2Rows(f *os.File, pageSize int, rootPageNum uint32) (uint32, error) {
+	pageID := rootPageNum
+	for {
+		page, err := readVersion2Page(f, pageSize, pageID)
+		if err != nil {
+			return 0, err
+		}
+		if codec.pageType(page.body) != PAGE_TYPE_INTERNAL_NODE {
+			break
+		}
+		node, err := deserializeInternalNodeFromPage(pageID, page)
+		if err != nil {
+			return 0, err
+		}
+		pageID = node.children[0]
+	}
+
+	visited := make(map[uint32]bool)
+	rowCount := uint32(0)
+	for pageID != 0 {
+		if visited[pageID] {
+			message := fmt.Sprintf("page %d is visited twice", pageID)
+			return 0, errors.New(message)
+		}
+		visited[pageID] = true
+
+		page, err := readVersion2Page(f, pageSize, pageID)
+		if err != nil {
+			return 0, err
+		}
+		if codec.pageType(page.body) != PAGE_TYPE_LEAF_NODE {
+			return 0, ErrCorruptPage{PageID: pageID}
+		}
+		node, err := deserializeLeafNodeFromPage(pageID, page)
+		if err != nil {
+			return 0, err
+		}
+		rowCount = rowCount + uint32(len(node.tuples))
+		pageID = node.nextNodeID
+	}
+	return rowCount, nil
+}
+
+// migrateFromVersion2 records the row count in the table header, the file is
+// upgraded in place since no other page changes.
+func migrateFromVersion2(fileName string) error {
+	f, err := os.OpenFile(fileName, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bs, err := readLegacyPage(f, 0)
+	if err != nil {
+		return err
+	}
+	header, err := deserializeTableHeader(bs)
+	if err != nil {
+		return err
+	}
+	pageSize := int(header.pageSize)
+	if isValidPageSize(pageSize) == false {
+		message := fmt.Sprintf("unsupported page size %d", header.pageSize)
+		return errors.New(message)
+	}
+
+	page, err := readVersion2Page(f, pageSize, 0)
+	if err != nil {
+		return err
+	}
+	rowCount, err := countVersion2Rows(f, pageSize, header.rootPageNum)
+	if err != nil {
+		return err
+	}
+
+	header.formatVersion = FORMAT_VERSION
+	header.rowCount = rowCount
+	copy(page.body, header.Bytes())
+	stampPageChecksum(0, page.body)
+	_, err = f.WriteAt(page.body, 0)
+	if err != nil {
+		return err
+	}
+	return f.Sync()
+}
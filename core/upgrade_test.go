@@ -0,0 +1,218 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createVersion0Row(id uint32) []byte {
+	bs := make([]byte, VERSION_0_ROW_SIZE)
+	binary.LittleEndian.PutUint32(bs, id)
+	copy(bs[VERSION_0_USERNAME_OFFSET:], fmt.Sprintf("user-%d", id))
+	copy(bs[VERSION_0_EMAIL_OFFSET:], fmt.Sprintf("user-%d@test.com", id))
+	return bs
+}
+
+func createVersion0LeafNode(ids []uint32, prevNodeID uint32, nextNodeID uint32) []byte {
+	bs := make([]byte, DEFAULT_PAGE_SIZE)
+	binary.LittleEndian.PutUint16(bs, uint16(PAGE_TYPE_LEAF_NODE))
+	binary.LittleEndian.PutUint32(bs[VERSION_1_LEAF_NODE_NUM_TUPLES_OFFSET:], uint32(len(ids)))
+	binary.LittleEndian.PutUint32(bs[VERSION_1_LEAF_NODE_PREV_NODE_ID_OFFSET:], prevNodeID)
+	binary.LittleEndian.PutUint32(bs[VERSION_1_LEAF_NODE_NEXT_NODE_ID_OFFSET:], nextNodeID)
+	from := VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET
+	for _, id := range ids {
+		copy(bs[from:], createVersion0Row(id))
+		from = from + VERSION_0_ROW_SIZE
+	}
+	return bs
+}
+
+// prepareVersion0File writes a file with two leaf nodes, the root page in the
+// header is stale just like a version 0 file after a split.
+func prepareVersion0File(fileName string) {
+	header := make([]byte, DEFAULT_PAGE_SIZE)
+	binary.LittleEndian.PutUint16(header, uint16(PAGE_TYPE_TABLE_HEADER))
+	binary.LittleEndian.PutUint32(header[VERSION_0_ROOT_PAGE_NUM_OFFSET:], 1)
+
+	bs := append(header, createVersion0LeafNode([]uint32{1, 2}, 0, 2)...)
+	bs = append(bs, createVersion0LeafNode([]uint32{3, 4, 5}, 1, 0)...)
+	ioutil.WriteFile(fileName, bs, 0644)
+}
+
+func TestOpenTableUpgradesVersion0File(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareVersion0File(fileName)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	header, _ := readTableHeader(table.bufferPool)
+	assert.Equal(t, uint32(FORMAT_VERSION), header.formatVersion)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 5, len(rows))
+	for idx, row := range rows {
+		id := uint32(idx + 1)
+		assert.Equal(t, id, row.Id())
+		assert.Equal(t, fmt.Sprintf("user-%d", id), row.Username())
+		assert.Equal(t, fmt.Sprintf("user-%d@test.com", id), row.Email())
+	}
+	table.CloseTable()
+	_, err = os.Stat(fileName + ".upgrade")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestOpenTableWithUnknownFile(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	ioutil.WriteFile(fileName, []byte("definitely not a database"), 0644)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, table)
+	assert.Equal(t, "file is not a sqlbit database", err.Error())
+}
+
+func TestOpenTableWithNewerFormatVersion(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	prepareBtreeFile(fileName, []*Tuple{})
+	f, _ := os.OpenFile(fileName, os.O_RDWR, 0644)
+	f.WriteAt(convertUint32ToBytes(FORMAT_VERSION+1), TABLE_HEADER_FORMAT_VERSION_OFFSET)
+	f.Close()
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, table)
+	message := fmt.Sprintf("unsupported format version %d", FORMAT_VERSION+1)
+	assert.Equal(t, message, err.Error())
+}
+
+func TestOpenTableWithEmptyFile(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	ioutil.WriteFile(fileName, []byte{}, 0644)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 0, len(rows))
+}
+
+func TestTableHeaderTracksWrites(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	table, _ := OpenTable(fileName)
+	for i := 1; i <= 40; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	table.CloseTable()
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	header, _ := readTableHeader(table.bufferPool)
+	assert.Equal(t, uint32(40), header.changeCounter)
+	assert.Equal(t, table.btree.rootNodeID, header.rootPageNum)
+	assert.NotEqual(t, uint32(1), header.rootPageNum)
+	fi, _ := os.Stat(fileName)
+	assert.Equal(t, int64(header.pageCount)*DEFAULT_PAGE_SIZE, fi.Size())
+	for i := 1; i <= 40; i++ {
+		rows, _ := table.IndexScan(&IndexCondition{ColumnName: "id", Target: uint32(i), Operator: "="}, nil)
+		assert.Equal(t, 1, len(rows))
+	}
+}
+
+// prepareVersion1File writes a file with a single leaf node holding a row
+// whose email spills into a version 1 overflow page.
+func prepareVersion1File(fileName string, email string) {
+	header := &TableHeader{
+		formatVersion: 1,
+		pageSize:      DEFAULT_PAGE_SIZE,
+		pageCount:     3,
+		rootPageNum:   1,
+	}
+	page0 := make([]byte, DEFAULT_PAGE_SIZE)
+	copy(page0, header.Bytes())
+
+	page1 := make([]byte, DEFAULT_PAGE_SIZE)
+	binary.LittleEndian.PutUint16(page1, uint16(PAGE_TYPE_LEAF_NODE))
+	binary.LittleEndian.PutUint32(page1[VERSION_1_LEAF_NODE_NUM_TUPLES_OFFSET:], 2)
+	from := VERSION_1_LEAF_NODE_FIRST_CHILD_OFFSET
+	copy(page1[from:], codec.encodeRow(NewRow(1, "Harry", email), 2))
+	copy(page1[from+ROW_SIZE:], NewRow(2, "Ron", "ron@hogwarts.edu").Bytes())
+
+	page2 := make([]byte, DEFAULT_PAGE_SIZE)
+	rest := email[COLUMN_EMAIL_LENGTH:]
+	binary.LittleEndian.PutUint16(page2, uint16(PAGE_TYPE_OVERFLOW))
+	binary.LittleEndian.PutUint32(page2[VERSION_1_OVERFLOW_PAGE_DATA_SIZE_OFFSET:], uint32(len(rest)))
+	copy(page2[VERSION_1_OVERFLOW_PAGE_HEADER_SIZE:], rest)
+
+	bs := append(page0, page1...)
+	bs = append(bs, page2...)
+	ioutil.WriteFile(fileName, bs, 0644)
+}
+
+func TestOpenTableUpgradesVersion1File(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	email := strings.Repeat("a", COLUMN_EMAIL_LENGTH+100)
+	prepareVersion1File(fileName, email)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	header, _ := readTableHeader(table.bufferPool)
+	assert.Equal(t, uint32(FORMAT_VERSION), header.formatVersion)
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, email, rows[0].Email())
+	assert.Equal(t, "ron@hogwarts.edu", rows[1].Email())
+}
+
+// downgradeToVersion2 rewrites the table header in the version 2 layout,
+// which has no row count.
+func downgradeToVersion2(fileName string) {
+	f, _ := os.OpenFile(fileName, os.O_RDWR, 0644)
+	page0 := make([]byte, DEFAULT_PAGE_SIZE)
+	f.ReadAt(page0, 0)
+	binary.LittleEndian.PutUint32(page0[TABLE_HEADER_FORMAT_VERSION_OFFSET:], 2)
+	binary.LittleEndian.PutUint32(page0[TABLE_HEADER_CHECKSUM_OFFSET:], 0)
+	offset := VERSION_2_TABLE_HEADER_CHECKSUM_OFFSET
+	checksum := crc32.Update(0, crc32cTable, page0[:offset])
+	checksum = crc32.Update(checksum, crc32cTable, page0[offset+PAGE_CHECKSUM_SIZE:])
+	binary.LittleEndian.PutUint32(page0[offset:], checksum)
+	f.WriteAt(page0, 0)
+	f.Close()
+}
+
+func TestOpenTableUpgradesVersion2File(t *testing.T) {
+	removeTestFile()
+	fileName := getTestFileName()
+	table, _ := OpenTable(fileName)
+	for i := 1; i <= 100; i++ {
+		table.InsertRow(NewRow(uint32(i), "user", "user@test.com"))
+	}
+	table.CloseTable()
+	downgradeToVersion2(fileName)
+
+	table, err := OpenTable(fileName)
+
+	assert.Nil(t, err)
+	header, _ := readTableHeader(table.bufferPool)
+	assert.Equal(t, uint32(FORMAT_VERSION), header.formatVersion)
+	assert.Equal(t, uint32(100), header.rowCount)
+	assert.Equal(t, 100, table.NumRows())
+	problems, err := table.CheckIntegrity()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, problems)
+	table.CloseTable()
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// VACUUM_FILE_SUFFIX names the file Vacuum rebuilds a database into, next to
+// the database file.
+const VACUUM_FILE_SUFFIX = "-vacuum"
+
+// Vacuum rebuilds the database file with packed nodes and without free
+// pages, which shrinks the file after many deletes. The rows are bulk loaded
+// into a new file which then replaces the database file. It waits for the
+// TableTransaction which writes to end, or fails with ErrLocked after the
+// busy timeout, and blocks everything else until it is done. An in-memory database is rebuilt into a new one in memory.
+func (t *Table) Vacuum() error {
+	err := t.tryLockWrites()
+	if err != nil {
+		return err
+	}
+	defer t.unlockWrites()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	if t.fileName == "" {
+		return errors.New("vacuum needs a table opened from a database file")
+	}
+	if t.fileName == MEMORY_FILE_NAME {
+		target, err := t.vacuumInto(MEMORY_FILE_NAME)
+		if err != nil {
+			return err
+		}
+		return t.replaceBufferPool(target)
+	}
+	vacuumFileName := t.fileName + VACUUM_FILE_SUFFIX
+	err = os.Remove(vacuumFileName)
+	if err != nil && os.IsNotExist(err) == false {
+		return err
+	}
+	target, err := t.vacuumInto(vacuumFileName)
+	if err == nil {
+		err = target.CloseTable()
+	}
+	if err != nil {
+		os.Remove(vacuumFileName)
+		return err
+	}
+	return t.replaceFile(vacuumFileName)
+}
+
+// lockedScanner reads the rows of a table whose lock is already held.
+type lockedScanner struct {
+	table *Table
+}
+
+func (s *lockedScanner) scanBatch(indexCondition *IndexCondition, after *uint32, filter Filter) (*scanBatch, error) {
+	tx := s.table.newTransaction()
+	defer tx.Rollback()
+	return readBatch(s.table.btree, tx, indexCondition, after, filter)
+}
+
+// vacuumInto bulk loads the rows into a new database, its change counter
+// goes on from the one of the table. The new database is left open.
+func (t *Table) vacuumInto(fileName string) (*Table, error) {
+	header, err := readTableHeader(t.bufferPool)
+	if err != nil {
+		return nil, err
+	}
+	target, err := OpenTableWithOptions(fileName, &TableOptions{
+		PageSize:       t.bufferPool.PageSize(),
+		BufferPoolSize: t.options.BufferPoolSize,
+		MaxSize:        t.options.MaxSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := &RowIterator{scanner: &lockedScanner{table: t}}
+	_, err = target.BulkLoad(rows.Next)
+	if err == nil {
+		err = target.setChangeCounter(header.changeCounter + 1)
+	}
+	if err != nil {
+		target.CloseTable()
+		return nil, err
+	}
+	return target, nil
+}
+
+// setChangeCounter overwrites the change counter of the table header, a
+// commit would increment it.
+func (t *Table) setChangeCounter(changeCounter uint32) error {
+	tx := t.newTransaction()
+	header, err := tx.ReadPage(uint32(0))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	writeTableHeaderField(header, TABLE_HEADER_CHANGE_COUNTER_OFFSET, changeCounter)
+	tx.Commit()
+	return nil
+}
+
+// replaceFile closes the database file, moves fileName over it and opens it
+// again. The table can not be used anymore when it can not be opened again.
+func (t *Table) replaceFile(fileName string) error {
+	err := t.bufferPool.Close()
+	if err != nil {
+		return err
+	}
+	renameErr := os.Rename(fileName, t.fileName)
+
+	bufferPool, header, err := openBufferPool(t.fileName, t.options)
+	if err != nil {
+		message := fmt.Sprintf("database can not be opened after vacuum: %s", err)
+		return errors.New(message)
+	}
+	t.bufferPool = bufferPool
+	t.btree.rootNodeID = header.rootPageNum
+	t.numRows = int(header.rowCount)
+	return renameErr
+}
+
+// replaceBufferPool closes the pages of the table and takes over the ones of
+// target, which is rebuilt in memory.
+func (t *Table) replaceBufferPool(target *Table) error {
+	header, err := readTableHeader(target.bufferPool)
+	if err != nil {
+		target.CloseTable()
+		return err
+	}
+	err = t.bufferPool.Close()
+	t.bufferPool = target.bufferPool
+	t.btree.rootNodeID = header.rootPageNum
+	t.numRows = int(header.rowCount)
+	return err
+}
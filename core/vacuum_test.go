@@ -0,0 +1,70 @@
+package core
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableVacuum(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	for i := 1; i <= 600; i++ {
+		email := "user@test.com"
+		if i%50 == 0 {
+			email = strings.Repeat("e", 2*DEFAULT_PAGE_SIZE)
+		}
+		err := table.InsertRow(NewRow(uint32(i), "user", email))
+		assert.Nil(t, err)
+	}
+	for i := 1; i <= 600; i++ {
+		if i%10 != 0 {
+			_, err := table.DeleteRow(uint32(i))
+			assert.Nil(t, err)
+		}
+	}
+	rows := drainRowIterator(t, table.Scan(nil, nil))
+	before, _ := table.Stats()
+	fi, _ := os.Stat(getTestFileName())
+	sizeBefore := fi.Size()
+
+	err := table.Vacuum()
+
+	assert.Nil(t, err)
+	after, _ := table.Stats()
+	assert.True(t, after.PageCount < before.PageCount/3)
+	assert.Equal(t, before.ChangeCounter+1, after.ChangeCounter)
+	assert.Equal(t, 60, table.NumRows())
+	assert.Equal(t, rows, drainRowIterator(t, table.Scan(nil, nil)))
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	fi, _ = os.Stat(getTestFileName())
+	assert.True(t, fi.Size() < sizeBefore)
+	_, err = os.Stat(getTestFileName() + VACUUM_FILE_SUFFIX)
+	assert.True(t, os.IsNotExist(err))
+
+	err = table.InsertRow(NewRow(1, "user", "user@test.com"))
+	assert.Nil(t, err)
+	table.CloseTable()
+
+	table, _ = OpenTable(getTestFileName())
+	assert.Equal(t, 61, table.NumRows())
+	problems, _ = table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+	table.CloseTable()
+}
+
+func TestTableVacuumReadOnly(t *testing.T) {
+	removeTestFile()
+	table, _ := OpenTable(getTestFileName())
+	table.InsertRow(NewRow(1, "user", "user@test.com"))
+	table.CloseTable()
+	table, _ = OpenTableWithOptions(getTestFileName(), &TableOptions{ReadOnly: true})
+
+	err := table.Vacuum()
+
+	assert.Equal(t, ErrReadOnly, err)
+	table.CloseTable()
+}
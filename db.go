@@ -0,0 +1,289 @@
+// Package sqlbit embeds a sqlbit database file into a Go program:
+//
+//	db, err := sqlbit.Open("/path/to.db")
+//	_, err = db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+//	rows, err := db.Query("select * from users where id > ?", 0)
+//	for rows.Next() {
+//		var id uint32
+//		var username, email string
+//		err = rows.Scan(&id, &username, &email)
+//	}
+//
+// A DB is safe for concurrent use. Statements outside of a transaction are
+// committed one by one. A Tx takes the write lock at its first write and
+// holds it until it ends, other writes wait for it meanwhile and fail with
+// core.ErrLocked after the busy timeout, reads go on.
+package sqlbit
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+var ErrClosed = errors.New("sqlbit: database is closed")
+var ErrTxDone = errors.New("sqlbit: transaction has already been committed or rolled back")
+
+// Options configures a database file. PageSize is only used when the file is
+// created, MaxSize limits the file size in bytes, 0 means no limit. A write
+// which would grow the file beyond MaxSize fails with core.ErrDatabaseFull.
+// BufferPoolSize is the number of pages kept in memory, it also limits how
+// many pages a transaction can touch. A ReadOnly database is neither created
+// nor written, writes fail with core.ErrReadOnly. BusyTimeout is how long a
+// write waits for the Tx which writes to end before it fails with
+// core.ErrLocked, 0 means core.DEFAULT_BUSY_TIMEOUT.
+type Options struct {
+	PageSize       int
+	MaxSize        int64
+	BufferPoolSize int
+	ReadOnly       bool
+	BusyTimeout    time.Duration
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		PageSize:       core.DEFAULT_PAGE_SIZE,
+		BufferPoolSize: core.DEFAULT_BUFFER_POOL_SIZE,
+		BusyTimeout:    core.DEFAULT_BUSY_TIMEOUT,
+	}
+}
+
+type DB struct {
+	table *core.Table
+	// lock keeps the table open while a call uses it
+	lock   sync.RWMutex
+	closed bool
+}
+
+// Open opens the database file, it is created when it does not exist. The
+// fileName :memory: opens a new database in memory, Backup saves it into a
+// file.
+func Open(fileName string) (*DB, error) {
+	return OpenWithOptions(fileName, DefaultOptions())
+}
+
+func OpenWithOptions(fileName string, options *Options) (*DB, error) {
+	table, err := core.OpenTableWithOptions(fileName, &core.TableOptions{
+		PageSize:       options.PageSize,
+		MaxSize:        options.MaxSize,
+		BufferPoolSize: options.BufferPoolSize,
+		ReadOnly:       options.ReadOnly,
+		BusyTimeout:    options.BusyTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DB{table: table}, nil
+}
+
+// Result is the outcome of Exec, Tag is the command tag of the statement,
+// e.g. "INSERT 0 1".
+type Result struct {
+	Tag          string
+	RowsAffected int64
+}
+
+func newResult(result *statement.Result) *Result {
+	r := &Result{Tag: result.Tag}
+	tokens := strings.Fields(result.Tag)
+	if len(tokens) > 1 {
+		r.RowsAffected, _ = strconv.ParseInt(tokens[len(tokens)-1], 10, 64)
+	}
+	return r
+}
+
+// prepare parses query and binds args to its ? or $n placeholders.
+func prepare(query string, args []interface{}) (statement.Statement, error) {
+	st, err := statement.Prepare(query)
+	if err != nil {
+		return statement.Statement{}, err
+	}
+	switch st.Type {
+	case statement.StatementType_Begin, statement.StatementType_Commit, statement.StatementType_Rollback:
+		return statement.Statement{}, errors.New("sqlbit: use DB.Begin, Tx.Commit and Tx.Rollback to control transactions")
+	}
+	return statement.Bind(st, args)
+}
+
+// use runs f while the table is open.
+func (db *DB) use(f func() error) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	if db.closed {
+		return ErrClosed
+	}
+	return f()
+}
+
+// Exec executes a statement in its own transaction, VACUUM waits for the
+// transaction which writes to end.
+func (db *DB) Exec(query string, args ...interface{}) (*Result, error) {
+	st, err := prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *statement.Result
+	err = db.use(func() error {
+		if st.Type == statement.StatementType_Vacuum {
+			result = &statement.Result{Tag: "VACUUM"}
+			return db.table.Vacuum()
+		}
+		result, err = statement.Execute(st, db.table)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result), nil
+}
+
+// Query executes a select, the rows are read in batches while iterating so
+// rows committed meanwhile by others might be seen. Use a Tx for a
+// consistent read.
+func (db *DB) Query(query string, args ...interface{}) (*Rows, error) {
+	st, err := prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var iterator *core.RowIterator
+	err = db.use(func() error {
+		iterator, err = openSelect(st, db.table)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(db.table.Columns(), func() (row *core.Row, err error) {
+		err = db.use(func() error {
+			row, err = iterator.Next()
+			return err
+		})
+		return row, err
+	}), nil
+}
+
+func openSelect(st statement.Statement, relation core.Relation) (*core.RowIterator, error) {
+	if st.Type != statement.StatementType_Select {
+		return nil, errors.New("sqlbit: Query only runs select statements, use Exec")
+	}
+	return statement.OpenSelect(st, relation)
+}
+
+// Backup copies the database into fileName while other calls go on, the
+// copy is the database as of a single commit.
+func (db *DB) Backup(fileName string) error {
+	return db.use(func() error {
+		return db.table.Backup(fileName)
+	})
+}
+
+// Begin starts a transaction, its first write waits for the transaction which
+// writes to end.
+func (db *DB) Begin() (*Tx, error) {
+	var tx *core.TableTransaction
+	err := db.use(func() error {
+		tx = db.table.Begin()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, columns: db.table.Columns()}, nil
+}
+
+// Close closes the database file, it waits for the running calls and the
+// open transactions to end.
+func (db *DB) Close() error {
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		return ErrClosed
+	}
+	db.closed = true
+	db.lock.Unlock()
+	return db.table.CloseTable()
+}
+
+// Tx is a transaction, its writes are only visible to others after Commit.
+// Every statement runs to a savepoint, a failed statement only undoes its
+// own changes and the Tx stays open.
+type Tx struct {
+	lock    sync.Mutex
+	tx      *core.TableTransaction
+	columns []string
+}
+
+// use runs f while the transaction is open.
+func (tx *Tx) use(f func() error) error {
+	tx.lock.Lock()
+	defer tx.lock.Unlock()
+	if tx.tx.Closed() {
+		return ErrTxDone
+	}
+	return f()
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (*Result, error) {
+	st, err := prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if st.Type == statement.StatementType_Vacuum {
+		return nil, errors.New("sqlbit: VACUUM can not run inside a transaction")
+	}
+
+	var result *statement.Result
+	err = tx.use(func() error {
+		result, err = statement.Execute(st, tx.tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result), nil
+}
+
+// Query executes a select inside the transaction, the rows have to be read
+// before the transaction ends.
+func (tx *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	st, err := prepare(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var iterator *core.RowIterator
+	err = tx.use(func() error {
+		iterator, err = openSelect(st, tx.tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRows(tx.columns, func() (row *core.Row, err error) {
+		err = tx.use(func() error {
+			row, err = iterator.Next()
+			return err
+		})
+		return row, err
+	}), nil
+}
+
+func (tx *Tx) Commit() error {
+	return tx.use(func() error {
+		return tx.tx.Commit()
+	})
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.use(func() error {
+		tx.tx.Rollback()
+		return nil
+	})
+}
@@ -0,0 +1,210 @@
+package sqlbit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) (*DB, func()) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	db, err := Open(filepath.Join(dir, "test.db"))
+	assert.Nil(t, err)
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func scanUsernames(t *testing.T, rows *Rows) []string {
+	usernames := []string{}
+	for rows.Next() {
+		var id uint32
+		var username, email string
+		assert.Nil(t, rows.Scan(&id, &username, &email))
+		usernames = append(usernames, username)
+	}
+	assert.Nil(t, rows.Err())
+	return usernames
+}
+
+func TestExecAndQuery(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	result, err := db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	assert.Equal(t, &Result{Tag: "INSERT 0 1", RowsAffected: 1}, result)
+	db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+
+	rows, err := db.Query("select * from users where id >= $1", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "username", "email"}, rows.Columns())
+	assert.Equal(t, []string{"ron"}, scanUsernames(t, rows))
+
+	rows, _ = db.Query("select * from users")
+	rows.Next()
+	var id int64
+	var username []byte
+	var email interface{}
+	assert.Nil(t, rows.Scan(&id, &username, &email))
+	assert.Equal(t, int64(1), id)
+	assert.Equal(t, "harry@hogwarts.edu", email)
+	assert.Equal(t, "sqlbit: column id: can not scan uint32 into *string", rows.Scan(new(string), &email, &email).Error())
+	rows.Close()
+	assert.Equal(t, false, rows.Next())
+
+	_, err = db.Query("insert 3 hermione hermione@hogwarts.edu")
+	assert.Equal(t, "sqlbit: Query only runs select statements, use Exec", err.Error())
+	_, err = db.Exec("begin")
+	assert.Equal(t, "sqlbit: use DB.Begin, Tx.Commit and Tx.Rollback to control transactions", err.Error())
+}
+
+func TestQueryStreamsRows(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	for i := 1; i <= 200; i++ {
+		db.Exec("insert ? ? ?", i, fmt.Sprintf("user%d", i), "user@test.com")
+	}
+
+	rows, _ := db.Query("select * from users")
+	count := 0
+	for rows.Next() {
+		count++
+		if count == 1 {
+			// the scan does not hold the table between batches
+			_, err := db.Exec("insert ? ? ?", 1000, "late", "late@test.com")
+			assert.Nil(t, err)
+		}
+	}
+
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, 201, count)
+}
+
+func TestTransaction(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	tx, _ := db.Begin()
+	tx.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, ErrTxDone, tx.Commit())
+
+	tx, _ = db.Begin()
+	tx.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	rows, _ := tx.Query("select * from users")
+	assert.Equal(t, []string{"ron"}, scanUsernames(t, rows))
+	assert.Nil(t, tx.Commit())
+	_, err := tx.Exec("insert ? ? ?", 3, "hermione", "hermione@hogwarts.edu")
+	assert.Equal(t, ErrTxDone, err)
+
+	rows, _ = db.Query("select * from users")
+	assert.Equal(t, []string{"ron"}, scanUsernames(t, rows))
+}
+
+func TestWriteFailsAfterBusyTimeout(t *testing.T) {
+	options := DefaultOptions()
+	options.BusyTimeout = 50 * time.Millisecond
+	db, err := OpenWithOptions(":memory:", options)
+	assert.Nil(t, err)
+	defer db.Close()
+	tx, _ := db.Begin()
+	tx.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+
+	start := time.Now()
+	_, err = db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+
+	assert.Equal(t, core.ErrLocked, err)
+	assert.True(t, time.Since(start) < core.DEFAULT_BUSY_TIMEOUT)
+	assert.Nil(t, tx.Commit())
+	_, err = db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	assert.Nil(t, err)
+}
+
+func TestVacuum(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	db.Exec("delete ?", 1)
+
+	result, err := db.Exec("vacuum")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "VACUUM", result.Tag)
+	rows, _ := db.Query("select * from users")
+	assert.Equal(t, []string{"ron"}, scanUsernames(t, rows))
+	tx, _ := db.Begin()
+	_, err = tx.Exec("vacuum")
+	assert.Equal(t, "sqlbit: VACUUM can not run inside a transaction", err.Error())
+	tx.Rollback()
+}
+
+func TestBackup(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	backupFile := filepath.Join(dir, "backup.db")
+
+	err := db.Backup(backupFile)
+
+	assert.Nil(t, err)
+	backup, err := Open(backupFile)
+	assert.Nil(t, err)
+	rows, _ := backup.Query("select * from users")
+	assert.Equal(t, []string{"harry"}, scanUsernames(t, rows))
+	backup.Close()
+}
+
+func TestConcurrentUse(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 1; j <= 10; j++ {
+				if j%2 == 0 {
+					tx, _ := db.Begin()
+					tx.Exec("insert ? ? ?", i*10+j, "user", "user@test.com")
+					tx.Commit()
+				} else {
+					db.Exec("insert ? ? ?", i*10+j, "user", "user@test.com")
+				}
+				rows, _ := db.Query("select * from users")
+				scanUsernames(t, rows)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	rows, _ := db.Query("select * from users")
+	assert.Equal(t, 40, len(scanUsernames(t, rows)))
+}
+
+func TestClose(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	rows, _ := db.Query("select * from users")
+
+	assert.Nil(t, db.Close())
+
+	assert.Equal(t, false, rows.Next())
+	assert.Equal(t, ErrClosed, rows.Err())
+	_, err := db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, ErrClosed, db.Close())
+}
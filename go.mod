@@ -4,5 +4,6 @@ go 1.12
 
 require (
 	github.com/alecthomas/participle v0.2.1
+	github.com/peterh/liner v1.2.2
 	github.com/stretchr/testify v1.2.2
 )
@@ -0,0 +1,260 @@
+// Package logictest runs SQL logic test files against a new sqlbit database.
+// The files use the text format of sqllogictest:
+//
+//	statement ok
+//	INSERT INTO users VALUES (1, 'harry', 'harry@hogwarts.edu')
+//
+//	statement error id 1 already exists
+//	insert 1 ron ron@hogwarts.edu
+//
+//	query ITT rowsort
+//	select * from users where id >= 1
+//	----
+//	1
+//	harry
+//	harry@hogwarts.edu
+//
+// Records are separated by blank lines, and the lines between records which
+// start with # are comments. A statement error record can be followed by a
+// regular expression the error has to match. A query lists the type of every
+// column, I for integers and T for text, an optional sort mode of nosort,
+// rowsort or valuesort, and an optional label. The queries with the same
+// label must return the same results. The expected values come after ----,
+// one per line and row by row. An empty text is written as (empty), and the
+// control characters of a text are written as @. A result with more values
+// than hash-threshold is compared as "N values hashing to MD5".
+//
+// skipif sqlbit and onlyif ENGINE lines before a record skip it, halt stops
+// the file.
+//
+// A query answered by an index scan is also run as a seq scan, and the
+// record fails when the two scans return different rows.
+package logictest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ENGINE is the name skipif and onlyif lines compare against.
+const ENGINE = "sqlbit"
+
+type RecordType int
+
+const (
+	RecordType_Statement RecordType = iota
+	RecordType_Query
+	RecordType_HashThreshold
+	RecordType_Halt
+)
+
+const (
+	SORT_MODE_NONE  = "nosort"
+	SORT_MODE_ROWS  = "rowsort"
+	SORT_MODE_VALUE = "valuesort"
+)
+
+const RESULTS_SEPARATOR = "----"
+
+// Record is a record of a logic test file, Line is the line it starts on.
+type Record struct {
+	Type RecordType
+	Line int
+	SQL  string
+	// Skip is set by skipif and onlyif lines which exclude sqlbit
+	Skip bool
+
+	// ExpectError is set by statement error, ErrorPattern is nil when any
+	// error is expected
+	ExpectError  bool
+	ErrorPattern *regexp.Regexp
+
+	ColumnTypes string
+	SortMode    string
+	Label       string
+	// HasResults is false for a query without ----, only its label and its
+	// scans are checked
+	HasResults bool
+	Results    []string
+
+	HashThreshold int
+}
+
+// Parse reads the records of a logic test file, fileName is only used in
+// errors.
+func Parse(fileName string, r io.Reader) ([]*Record, error) {
+	p := &recordParser{fileName: fileName, scanner: bufio.NewScanner(r)}
+	p.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	records := []*Record{}
+	for true {
+		record, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if record == nil {
+			break
+		}
+		records = append(records, record)
+	}
+	return records, p.scanner.Err()
+}
+
+type recordParser struct {
+	fileName string
+	scanner  *bufio.Scanner
+	line     int
+}
+
+// readLine returns false at the end of the file.
+func (p *recordParser) readLine() (string, bool) {
+	if p.scanner.Scan() == false {
+		return "", false
+	}
+	p.line++
+	return strings.TrimRight(p.scanner.Text(), " \t\r"), true
+}
+
+func (p *recordParser) error(format string, args ...interface{}) error {
+	message := fmt.Sprintf("%s:%d: ", p.fileName, p.line) + fmt.Sprintf(format, args...)
+	return errors.New(message)
+}
+
+// next returns the next record, nil at the end of the file.
+func (p *recordParser) next() (*Record, error) {
+	skip := false
+	for true {
+		line, ok := p.readLine()
+		if ok == false {
+			return nil, nil
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		record := &Record{Line: p.line, Skip: skip}
+		switch fields[0] {
+		case "skipif", "onlyif":
+			if len(fields) < 2 {
+				return nil, p.error("%s needs an engine name", fields[0])
+			}
+			if (fields[0] == "skipif") == (fields[1] == ENGINE) {
+				skip = true
+			}
+			continue
+		case "statement":
+			return record, p.statement(record, line, fields)
+		case "query":
+			return record, p.query(record, fields)
+		case "hash-threshold":
+			if len(fields) != 2 {
+				return nil, p.error("hash-threshold needs a number")
+			}
+			threshold, err := strconv.Atoi(fields[1])
+			if err != nil || threshold < 0 {
+				return nil, p.error("invalid hash-threshold %q", fields[1])
+			}
+			record.Type = RecordType_HashThreshold
+			record.HashThreshold = threshold
+			return record, nil
+		case "halt":
+			record.Type = RecordType_Halt
+			return record, nil
+		default:
+			return nil, p.error("unknown record %q", fields[0])
+		}
+	}
+	return nil, nil
+}
+
+func (p *recordParser) statement(record *Record, line string, fields []string) error {
+	record.Type = RecordType_Statement
+	if len(fields) < 2 || (fields[1] != "ok" && fields[1] != "error") || (fields[1] == "ok" && len(fields) > 2) {
+		return p.error("expected statement ok or statement error")
+	}
+	if fields[1] == "error" {
+		record.ExpectError = true
+		pattern := strings.TrimSpace(line[strings.Index(line, "error")+len("error"):])
+		if pattern != "" {
+			var err error
+			record.ErrorPattern, err = regexp.Compile(pattern)
+			if err != nil {
+				return p.error("invalid error pattern: %s", err)
+			}
+		}
+	}
+
+	sql, separator, err := p.sql()
+	if err != nil {
+		return err
+	}
+	if separator {
+		return p.error("a statement has no results")
+	}
+	record.SQL = sql
+	return nil
+}
+
+func (p *recordParser) query(record *Record, fields []string) error {
+	record.Type = RecordType_Query
+	record.SortMode = SORT_MODE_NONE
+	if len(fields) < 2 || len(fields) > 4 || strings.Trim(fields[1], "IT") != "" {
+		return p.error("expected query TYPES [nosort|rowsort|valuesort] [LABEL], with a type I or T per column")
+	}
+	record.ColumnTypes = fields[1]
+	if len(fields) > 2 {
+		switch fields[2] {
+		case SORT_MODE_NONE, SORT_MODE_ROWS, SORT_MODE_VALUE:
+			record.SortMode = fields[2]
+		default:
+			return p.error("unknown sort mode %q", fields[2])
+		}
+	}
+	if len(fields) > 3 {
+		record.Label = fields[3]
+	}
+
+	sql, separator, err := p.sql()
+	if err != nil {
+		return err
+	}
+	record.SQL = sql
+	record.HasResults = separator
+	record.Results = []string{}
+	for separator {
+		line, ok := p.readLine()
+		if ok == false || line == "" {
+			break
+		}
+		record.Results = append(record.Results, line)
+	}
+	return nil
+}
+
+// sql reads the lines of a statement until a blank line, it reports whether
+// they end with ----. A trailing semicolon is removed.
+func (p *recordParser) sql() (string, bool, error) {
+	lines := []string{}
+	separator := false
+	for true {
+		line, ok := p.readLine()
+		if ok == false || line == "" {
+			break
+		}
+		if line == RESULTS_SEPARATOR {
+			separator = true
+			break
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "", false, p.error("record has no SQL")
+	}
+	sql := strings.TrimSpace(strings.Join(lines, "\n"))
+	return strings.TrimSpace(strings.TrimSuffix(sql, ";")), separator, nil
+}
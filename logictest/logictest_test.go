@@ -0,0 +1,143 @@
+package logictest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogicTestFiles(t *testing.T) {
+	fileNames, _ := filepath.Glob(filepath.Join("testdata", "*.test"))
+	assert.NotEqual(t, 0, len(fileNames))
+	for _, fileName := range fileNames {
+		fileName := fileName
+		t.Run(filepath.Base(fileName), func(t *testing.T) {
+			failures, err := RunFile(fileName)
+			assert.Nil(t, err)
+			for _, failure := range failures {
+				t.Error(failure.String())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	text := `# a comment
+statement ok
+insert 1 harry harry@hogwarts.edu;
+
+skipif sqlbit
+statement error id \d+ already exists
+insert 1 ron
+  ron@hogwarts.edu
+
+hash-threshold 10
+
+query ITT rowsort users
+select * from users
+----
+1
+harry
+harry@hogwarts.edu
+
+query ITT valuesort
+select * from users where id = 2
+
+halt
+`
+	records, err := Parse("test", strings.NewReader(text))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(records))
+	assert.Equal(t, RecordType_Statement, records[0].Type)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "insert 1 harry harry@hogwarts.edu", records[0].SQL)
+	assert.Equal(t, false, records[0].ExpectError)
+	assert.Equal(t, true, records[1].Skip)
+	assert.Equal(t, true, records[1].ExpectError)
+	assert.Equal(t, `id \d+ already exists`, records[1].ErrorPattern.String())
+	assert.Equal(t, "insert 1 ron\n  ron@hogwarts.edu", records[1].SQL)
+	assert.Equal(t, 10, records[2].HashThreshold)
+	assert.Equal(t, RecordType_Query, records[3].Type)
+	assert.Equal(t, "ITT", records[3].ColumnTypes)
+	assert.Equal(t, SORT_MODE_ROWS, records[3].SortMode)
+	assert.Equal(t, "users", records[3].Label)
+	assert.Equal(t, []string{"1", "harry", "harry@hogwarts.edu"}, records[3].Results)
+	assert.Equal(t, false, records[4].HasResults)
+	assert.Equal(t, RecordType_Halt, records[5].Type)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"statement maybe\ninsert 1 a b\n":        "test:1: expected statement ok or statement error",
+		"query IXT\nselect * from users\n":       "test:1: expected query TYPES [nosort|rowsort|valuesort] [LABEL], with a type I or T per column",
+		"query ITT anysort\nselect * from users": "test:1: unknown sort mode \"anysort\"",
+		"statement ok\n\n":                       "test:2: record has no SQL",
+		"select * from users\n":                  "test:1: unknown record \"select\"",
+	}
+	for text, message := range tests {
+		_, err := Parse("test", strings.NewReader(text))
+		assert.Equal(t, message, err.Error(), text)
+	}
+}
+
+func TestRunFileReportsFailures(t *testing.T) {
+	text := `statement ok
+insert 1 harry harry@hogwarts.edu
+
+statement error
+insert 2 ron ron@hogwarts.edu
+
+statement error UNIQUE
+insert 1 harry harry@hogwarts.edu
+
+query ITT
+select * from users where id = 1
+----
+1
+ron
+ron@hogwarts.edu
+
+query ITT nosort users
+select * from users
+
+query ITT nosort users
+select * from users where id = 3
+
+query I
+select * from users
+`
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "failures.test")
+	ioutil.WriteFile(fileName, []byte(text), 0644)
+
+	failures, err := RunFile(fileName)
+
+	assert.Nil(t, err)
+	lines := []int{}
+	for _, failure := range failures {
+		lines = append(lines, failure.Line)
+	}
+	assert.Equal(t, []int{4, 7, 10, 20, 23}, lines)
+	assert.Equal(t, "statement succeeded, expected an error", failures[0].Message)
+	assert.Equal(t, `error "id 1 already exists" does not match "UNIQUE"`, failures[1].Message)
+	assert.Equal(t, "got results\n1\nharry\nharry@hogwarts.edu\nexpected\n1\nron\nron@hogwarts.edu", failures[2].Message)
+	assert.Equal(t, "results differ from the earlier query labelled users", failures[3].Message)
+	assert.Equal(t, "query returns 3 columns, \"I\" has 1", failures[4].Message)
+	assert.Equal(t, fileName+":4: statement succeeded, expected an error\ninsert 2 ron ron@hogwarts.edu", failures[0].String())
+}
+
+func TestFormatText(t *testing.T) {
+	assert.Equal(t, "(empty)", formatText(""))
+	assert.Equal(t, "a@b@", formatText("a\x00b\n"))
+	assert.Equal(t, "harry", formatText("harry"))
+}
+
+func TestHashValues(t *testing.T) {
+	assert.Equal(t, "3 values hashing to 58ab07aff1aa06e7cf7f060c7d7ee815", hashValues([]string{"1", "harry", "harry@hogwarts.edu"}))
+}
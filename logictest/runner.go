@@ -0,0 +1,258 @@
+package logictest
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// Failure is a record whose statement or results were not the expected
+// ones.
+type Failure struct {
+	FileName string
+	Line     int
+	SQL      string
+	Message  string
+}
+
+func (f Failure) String() string {
+	return fmt.Sprintf("%s:%d: %s\n%s", f.FileName, f.Line, f.Message, f.SQL)
+}
+
+// RunFile runs the records of a logic test file against a new database in
+// memory, it returns an error when the file cannot be read or parsed.
+func RunFile(fileName string) ([]Failure, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records, err := Parse(fileName, file)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := core.OpenTable(core.MEMORY_FILE_NAME)
+	if err != nil {
+		return nil, err
+	}
+	defer table.CloseTable()
+
+	r := newRunner(fileName, statement.NewSession(table))
+	defer r.session.Close()
+	return r.run(records), nil
+}
+
+type runner struct {
+	fileName      string
+	session       *statement.Session
+	hashThreshold int
+	// labels are the results of the first query with each label
+	labels   map[string][]string
+	failures []Failure
+}
+
+func newRunner(fileName string, session *statement.Session) *runner {
+	return &runner{
+		fileName: fileName,
+		session:  session,
+		labels:   map[string][]string{},
+	}
+}
+
+func (r *runner) run(records []*Record) []Failure {
+	for _, record := range records {
+		if record.Skip {
+			continue
+		}
+		var err error
+		switch record.Type {
+		case RecordType_Halt:
+			return r.failures
+		case RecordType_HashThreshold:
+			r.hashThreshold = record.HashThreshold
+		case RecordType_Statement:
+			err = r.runStatement(record)
+		case RecordType_Query:
+			err = r.runQuery(record)
+		}
+		if err != nil {
+			r.failures = append(r.failures, Failure{
+				FileName: r.fileName,
+				Line:     record.Line,
+				SQL:      record.SQL,
+				Message:  err.Error(),
+			})
+		}
+	}
+	return r.failures
+}
+
+func (r *runner) runStatement(record *Record) error {
+	_, err := r.session.Execute(record.SQL)
+	if record.ExpectError == false {
+		if err != nil {
+			message := fmt.Sprintf("statement failed: %s", err)
+			return errors.New(message)
+		}
+		return nil
+	}
+	if err == nil {
+		return errors.New("statement succeeded, expected an error")
+	}
+	if record.ErrorPattern != nil && record.ErrorPattern.MatchString(err.Error()) == false {
+		message := fmt.Sprintf("error %q does not match %q", err.Error(), record.ErrorPattern.String())
+		return errors.New(message)
+	}
+	return nil
+}
+
+func (r *runner) runQuery(record *Record) error {
+	st, err := statement.Prepare(record.SQL)
+	if err != nil {
+		message := fmt.Sprintf("query failed: %s", err)
+		return errors.New(message)
+	}
+	if st.Type != statement.StatementType_Select {
+		return errors.New("query is not a select, use statement ok")
+	}
+	result, err := r.session.ExecuteStatement(st)
+	if err != nil {
+		message := fmt.Sprintf("query failed: %s", err)
+		return errors.New(message)
+	}
+	if len(result.Columns) != len(record.ColumnTypes) {
+		message := fmt.Sprintf("query returns %d columns, %q has %d", len(result.Columns), record.ColumnTypes, len(record.ColumnTypes))
+		return errors.New(message)
+	}
+
+	err = r.compareScans(st, result.Rows)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(result.Rows))
+	for idx, row := range result.Rows {
+		rows[idx] = formatRow(row, record.ColumnTypes)
+	}
+	values := sortValues(rows, record.SortMode)
+	if len(values) > r.hashThreshold && r.hashThreshold > 0 {
+		values = []string{hashValues(values)}
+	}
+
+	if record.HasResults && equalValues(values, record.Results) == false {
+		message := fmt.Sprintf("got results\n%s\nexpected\n%s", strings.Join(values, "\n"), strings.Join(record.Results, "\n"))
+		return errors.New(message)
+	}
+	if record.Label != "" {
+		labelled, ok := r.labels[record.Label]
+		if ok == false {
+			r.labels[record.Label] = values
+		} else if equalValues(values, labelled) == false {
+			message := fmt.Sprintf("results differ from the earlier query labelled %s", record.Label)
+			return errors.New(message)
+		}
+	}
+	return nil
+}
+
+// compareScans runs a query answered by an index scan again as a seq scan,
+// both have to return the same rows in the same order.
+func (r *runner) compareScans(st statement.Statement, rows []*core.Row) error {
+	relation := r.session.Relation()
+	plan, err := statement.OptimizeQueryPlan(st, relation)
+	if err != nil || plan.ScanMethod != statement.ScanMethodType_IndexScan {
+		return err
+	}
+	seqScanPlan, err := statement.SeqScanQueryPlan(st, relation)
+	if err != nil {
+		return err
+	}
+	seqScanRows, err := relation.SeqScan(seqScanPlan.Filter)
+	if err != nil {
+		message := fmt.Sprintf("seq scan failed: %s", err)
+		return errors.New(message)
+	}
+
+	indexScan := []string{}
+	for _, row := range rows {
+		indexScan = append(indexScan, row.String())
+	}
+	seqScan := []string{}
+	for _, row := range seqScanRows {
+		seqScan = append(seqScan, row.String())
+	}
+	if equalValues(indexScan, seqScan) == false {
+		message := fmt.Sprintf("index scan returns\n%s\nseq scan returns\n%s", strings.Join(indexScan, "\n"), strings.Join(seqScan, "\n"))
+		return errors.New(message)
+	}
+	return nil
+}
+
+// formatRow returns the values of a row as they are written in a test file.
+func formatRow(row *core.Row, columnTypes string) []string {
+	values := []string{fmt.Sprintf("%d", row.Id()), row.Username(), row.Email()}
+	for idx, value := range values {
+		if columnTypes[idx] == 'T' {
+			values[idx] = formatText(value)
+		}
+	}
+	return values
+}
+
+func formatText(text string) string {
+	if text == "" {
+		return "(empty)"
+	}
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return '@'
+		}
+		return r
+	}, text)
+}
+
+// sortValues flattens rows into values, rowsort sorts the rows and
+// valuesort sorts the values.
+func sortValues(rows [][]string, sortMode string) []string {
+	if sortMode == SORT_MODE_ROWS {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return strings.Join(rows[i], " ") < strings.Join(rows[j], " ")
+		})
+	}
+	values := []string{}
+	for _, row := range rows {
+		values = append(values, row...)
+	}
+	if sortMode == SORT_MODE_VALUE {
+		sort.Strings(values)
+	}
+	return values
+}
+
+func hashValues(values []string) string {
+	h := md5.New()
+	for _, value := range values {
+		h.Write([]byte(value))
+		h.Write([]byte("\n"))
+	}
+	return fmt.Sprintf("%d values hashing to %x", len(values), h.Sum(nil))
+}
+
+func equalValues(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
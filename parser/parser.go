@@ -8,17 +8,17 @@ import (
 )
 
 type Select struct {
-	Expression *SelectExpression `"SELECT" @@`
-	From       *From             `"FROM" @@`
+	Expression *SelectExpression `parser:"\"SELECT\" @@"`
+	From       *From             `parser:"\"FROM\" @@"`
 }
 
 type SelectExpression struct {
-	All         bool         `@"*"`
-	Expressions []*Attribute `| @@ ("," @@)*`
+	All         bool         `parser:"@\"*\""`
+	Expressions []*Attribute `parser:"| @@ (\",\" @@)*"`
 }
 
 type Attribute struct {
-	Name string `@Ident`
+	Name string `parser:"@Ident"`
 }
 
 func (a *Attribute) String() string {
@@ -26,28 +26,31 @@ func (a *Attribute) String() string {
 }
 
 type From struct {
-	Name  string      `@Ident`
-	Where *Expression `( "WHERE" @@ )?`
+	Name  string      `parser:"@Ident"`
+	Where *Expression `parser:"( \"WHERE\" @@ )?"`
 }
 
 type Expression struct {
-	Condition *Condition `@@`
+	Condition *Condition `parser:"@@"`
 }
 
 type Condition struct {
-	LHS     string   `@Ident`
-	Compare *Compare `@@`
-	Value   *Value   `@@`
+	LHS     string   `parser:"@Ident"`
+	Compare *Compare `parser:"@@"`
+	Value   *Value   `parser:"@@"`
 }
 
 type Value struct {
-	Str    *string  `  @String`
-	Number *float64 `| @Number`
+	Str         *string  `parser:"  @String"`
+	Number      *float64 `parser:"| @Number"`
+	Placeholder *string  `parser:"| @Placeholder"`
 }
 
 func (v *Value) Type() string {
 	if v.Str != nil {
 		return "string"
+	} else if v.Placeholder != nil {
+		return "placeholder"
 	} else {
 		return "float64"
 	}
@@ -56,29 +59,35 @@ func (v *Value) Type() string {
 func (v *Value) String() string {
 	if v.Str != nil {
 		return *v.Str
+	} else if v.Placeholder != nil {
+		return *v.Placeholder
 	} else {
 		return fmt.Sprintf("%f", *v.Number)
 	}
 }
 
 type Compare struct {
-	Operator string `@( "<>" | "<=" | ">=" | "=" | "<" | ">" | "!=" )`
+	Operator string `parser:"@( \"<>\" | \"<=\" | \">=\" | \"=\" | \"<\" | \">\" | \"!=\" )"`
 }
 
-func Parse(query string) (*Select, error) {
-	sqlLexer := lexer.Must(lexer.Regexp(`(\s+)` +
-		`|(?P<Keyword>(?i)SELECT|FROM|WHERE|AND|OR)` +
-		`|(?P<Ident>[a-zA-Z_][a-zA-Z0-9_]*)` +
-		`|(?P<Number>[-+]?\d*\.?\d+([eE][-+]?\d+)?)` +
-		`|(?P<String>'[^']*'|"[^"]*")` +
+// sqlParser is built once, building it compiles the lexer and the grammar.
+var sqlParser = participle.MustBuild(
+	&Select{},
+	participle.Lexer(lexer.Must(lexer.Regexp(`(\s+)`+
+		`|(?P<Keyword>(?i)SELECT|FROM|WHERE|AND|OR)`+
+		`|(?P<Ident>[a-zA-Z_][a-zA-Z0-9_]*)`+
+		`|(?P<Number>[-+]?\d*\.?\d+([eE][-+]?\d+)?)`+
+		`|(?P<String>'[^']*'|"[^"]*")`+
+		`|(?P<Placeholder>\?|\$\d+)`+
 		`|(?P<Operators><>|!=|<=|>=|[-+*/%,.()=<>])`,
-	))
-	sqlParser := participle.MustBuild(
-		&Select{},
-		participle.Lexer(sqlLexer),
-		participle.Unquote("String"),
-		participle.CaseInsensitive("Keyword"),
-	)
+	))),
+	participle.Unquote("String"),
+	participle.CaseInsensitive("Keyword"),
+)
+
+// Parse parses a select query, a value in the where clause can be a ? or $n
+// placeholder.
+func Parse(query string) (*Select, error) {
 	sql := &Select{}
 	err := sqlParser.ParseString(query, sql)
 	return sql, err
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import "testing"
+
+// FuzzParse checks Parse returns an error instead of panicking on any input,
+// run it with go test -fuzz FuzzParse ./parser.
+func FuzzParse(f *testing.F) {
+	seeds := []string{
+		"select id, name from users",
+		"select * from users",
+		"select * from users where id = 1 and username = 'Harry'",
+		"select * from users where id >= ? and id < $2",
+		"select * from users where email <> \"\" or id != 3",
+		"select",
+		"select * from users where id = 'unterminated",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		Parse(query)
+	})
+}
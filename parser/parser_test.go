@@ -37,3 +37,17 @@ func TestSelectWhere(t *testing.T) {
 	assert.Equal(t, "=", cond.Compare.Operator)
 	assert.Equal(t, 1, int(*cond.Value.Number))
 }
+
+func TestSelectWherePlaceholder(t *testing.T) {
+	query, err := Parse("select * from users where username = ?")
+
+	assert.Nil(t, err)
+	value := query.From.Where.Condition.Value
+	assert.Equal(t, "placeholder", value.Type())
+	assert.Equal(t, "?", value.String())
+
+	query, err = Parse("select * from users where id > $2")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "$2", *query.From.Where.Condition.Value.Placeholder)
+}
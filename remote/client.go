@@ -0,0 +1,137 @@
+package remote
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Client is a minimal Postgres client which runs simple queries, it is not
+// safe for concurrent use.
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Response is the answer of the server to a query, a NULL value is returned
+// as nil. When the query has several statements it is the answer to the last
+// one.
+type Response struct {
+	Tag     string
+	Columns []string
+	Rows    [][]*string
+}
+
+// Dial connects to the server and runs the startup handshake.
+func Dial(address string) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+	err = c.startup()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) startup() error {
+	startup := newMessage(0).int32(PROTOCOL_VERSION).
+		string("user").string("sqlbit").
+		string("database").string("sqlbit").
+		bytes([]byte{0})
+	_, err := c.conn.Write(startup.encode())
+	if err != nil {
+		return err
+	}
+
+	for {
+		kind, body, err := readMessage(c.reader)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case MESSAGE_AUTHENTICATION:
+			r := &messageReader{body: body}
+			if r.int32() != 0 {
+				return errors.New("authentication is not supported")
+			}
+		case MESSAGE_ERROR_RESPONSE:
+			return parseErrorResponse(body)
+		case MESSAGE_READY_FOR_QUERY:
+			return nil
+		}
+	}
+}
+
+// Execute sends the query and waits for its response, a statement which
+// fails on the server is returned as ErrStatement.
+func (c *Client) Execute(text string) (*Response, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("statement is empty")
+	}
+	_, err := c.conn.Write(newMessage(MESSAGE_QUERY).string(text).encode())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{}
+	var statementErr error
+	for {
+		kind, body, err := readMessage(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		r := &messageReader{body: body}
+		switch kind {
+		case MESSAGE_ROW_DESCRIPTION:
+			response.Columns = []string{}
+			response.Rows = [][]*string{}
+			numColumns := r.int16()
+			for i := 0; i < numColumns && r.err == nil; i++ {
+				response.Columns = append(response.Columns, r.string())
+				r.bytes(18)
+			}
+		case MESSAGE_DATA_ROW:
+			numColumns := r.int16()
+			row := make([]*string, numColumns)
+			for i := 0; i < numColumns && r.err == nil; i++ {
+				length := r.int32()
+				if length >= 0 {
+					value := string(r.bytes(length))
+					row[i] = &value
+				}
+			}
+			response.Rows = append(response.Rows, row)
+		case MESSAGE_COMMAND_COMPLETE:
+			response.Tag = r.string()
+		case MESSAGE_EMPTY_QUERY_RESPONSE, MESSAGE_NOTICE_RESPONSE:
+		case MESSAGE_ERROR_RESPONSE:
+			statementErr = parseErrorResponse(body)
+		case MESSAGE_READY_FOR_QUERY:
+			if statementErr != nil {
+				return nil, statementErr
+			}
+			return response, nil
+		default:
+			message := fmt.Sprintf("unexpected message type %q", kind)
+			return nil, errors.New(message)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+}
+
+// Close sends Terminate and closes the connection.
+func (c *Client) Close() error {
+	c.conn.Write(newMessage(MESSAGE_TERMINATE).encode())
+	return c.conn.Close()
+}
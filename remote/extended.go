@@ -0,0 +1,353 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// preparedStatement is the statement of a Parse message, empty is set when
+// the query has no statement.
+type preparedStatement struct {
+	statement  statement.Statement
+	empty      bool
+	paramTypes []uint32
+}
+
+// portal is a prepared statement bound to its arguments by a Bind message.
+type portal struct {
+	statement     statement.Statement
+	empty         bool
+	resultFormats []int
+}
+
+// extendedQuery keeps the prepared statements and portals of a connection,
+// the unnamed ones are named "".
+type extendedQuery struct {
+	table      *core.Table
+	statements map[string]*preparedStatement
+	portals    map[string]*portal
+}
+
+func newExtendedQuery(table *core.Table) *extendedQuery {
+	return &extendedQuery{
+		table:      table,
+		statements: make(map[string]*preparedStatement),
+		portals:    make(map[string]*portal),
+	}
+}
+
+// handle answers a message of the extended query flow, the returned error
+// is sent as an ErrorResponse.
+func (e *extendedQuery) handle(writer *bufio.Writer, session *statement.Session, kind byte, body []byte) error {
+	r := &messageReader{body: body}
+	switch kind {
+	case MESSAGE_PARSE:
+		return e.parse(writer, r)
+	case MESSAGE_BIND:
+		return e.bind(writer, r)
+	case MESSAGE_DESCRIBE:
+		return e.describe(writer, r)
+	case MESSAGE_EXECUTE:
+		return e.execute(writer, session, r)
+	case MESSAGE_CLOSE:
+		return e.close(writer, r)
+	default:
+		// Flush, every message is flushed anyway
+		return nil
+	}
+}
+
+// sync ends the extended query, the portals are dropped outside of a
+// transaction.
+func (e *extendedQuery) sync(session *statement.Session) {
+	if session.InTransaction() == false {
+		e.portals = make(map[string]*portal)
+	}
+}
+
+func protocolViolation(err error) error {
+	return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: err.Error()}
+}
+
+func (e *extendedQuery) parse(writer *bufio.Writer, r *messageReader) error {
+	name := r.string()
+	query := r.string()
+	numTypes := r.int16()
+	types := []uint32{}
+	for i := 0; i < numTypes && r.err == nil; i++ {
+		types = append(types, uint32(r.int32()))
+	}
+	if r.err != nil {
+		return protocolViolation(r.err)
+	}
+	if name != "" && e.statements[name] != nil {
+		message := fmt.Sprintf("prepared statement %q already exists", name)
+		return ErrStatement{Code: SQLSTATE_DUPLICATE_PREPARED_STATEMENT, Message: message}
+	}
+
+	texts := statement.Split(query)
+	if len(texts) > 1 {
+		return ErrStatement{Code: SQLSTATE_SYNTAX_ERROR, Message: "cannot insert multiple commands into a prepared statement"}
+	}
+	prepared := &preparedStatement{empty: len(texts) == 0}
+	if len(texts) == 1 {
+		st, err := statement.Prepare(texts[0])
+		if err != nil {
+			return err
+		}
+		prepared.statement = st
+		prepared.paramTypes = e.paramTypes(st, types)
+	}
+	e.statements[name] = prepared
+	writer.Write(newMessage(MESSAGE_PARSE_COMPLETE).encode())
+	return nil
+}
+
+// paramTypes returns the type of every argument of st, the types the client
+// did not specify are the types of the columns the arguments are bound to.
+func (e *extendedQuery) paramTypes(st statement.Statement, specified []uint32) []uint32 {
+	schema := e.table.Schema()
+	types := []uint32{}
+	for i, column := range st.ParamColumns() {
+		if i < len(specified) && specified[i] != 0 {
+			types = append(types, specified[i])
+		} else {
+			types = append(types, typeOID(schema[column]))
+		}
+	}
+	return types
+}
+
+func (e *extendedQuery) bind(writer *bufio.Writer, r *messageReader) error {
+	portalName := r.string()
+	statementName := r.string()
+	paramFormats := readFormats(r)
+	numParams := r.int16()
+	params := [][]byte{}
+	for i := 0; i < numParams && r.err == nil; i++ {
+		length := r.int32()
+		if length < 0 {
+			params = append(params, nil)
+			continue
+		}
+		params = append(params, r.bytes(length))
+	}
+	resultFormats := readFormats(r)
+	if r.err != nil {
+		return protocolViolation(r.err)
+	}
+
+	prepared := e.statements[statementName]
+	if prepared == nil {
+		message := fmt.Sprintf("prepared statement %q does not exist", statementName)
+		return ErrStatement{Code: SQLSTATE_INVALID_STATEMENT_NAME, Message: message}
+	}
+	if len(params) != len(prepared.paramTypes) {
+		message := fmt.Sprintf("bind message supplies %d parameters, but prepared statement %q requires %d", len(params), statementName, len(prepared.paramTypes))
+		return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+	if len(paramFormats) > 1 && len(paramFormats) != len(params) {
+		message := fmt.Sprintf("bind message has %d parameter formats but %d parameters", len(paramFormats), len(params))
+		return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+
+	columns := e.resultColumns(prepared.statement, prepared.empty)
+	if len(resultFormats) > 1 && len(resultFormats) != len(columns) {
+		message := fmt.Sprintf("bind message has %d result formats but query has %d columns", len(resultFormats), len(columns))
+		return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+	for _, resultFormat := range resultFormats {
+		if resultFormat != FORMAT_TEXT && resultFormat != FORMAT_BINARY {
+			message := fmt.Sprintf("unsupported format code: %d", resultFormat)
+			return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+		}
+	}
+
+	args := []interface{}{}
+	for i, param := range params {
+		arg, err := decodeParam(param, prepared.paramTypes[i], format(paramFormats, i))
+		if err != nil {
+			return err
+		}
+		args = append(args, arg)
+	}
+	st := prepared.statement
+	if prepared.empty == false {
+		bound, err := statement.Bind(st, args)
+		if err != nil {
+			return err
+		}
+		st = bound
+	}
+	e.portals[portalName] = &portal{statement: st, empty: prepared.empty, resultFormats: resultFormats}
+	writer.Write(newMessage(MESSAGE_BIND_COMPLETE).encode())
+	return nil
+}
+
+// readFormats reads a list of format codes, no code means text for every
+// value and a single code applies to every value.
+func readFormats(r *messageReader) []int {
+	n := r.int16()
+	formats := []int{}
+	for i := 0; i < n && r.err == nil; i++ {
+		formats = append(formats, r.int16())
+	}
+	return formats
+}
+
+func format(formats []int, i int) int {
+	switch len(formats) {
+	case 0:
+		return FORMAT_TEXT
+	case 1:
+		return formats[0]
+	default:
+		return formats[i]
+	}
+}
+
+// decodeParam converts the value of an argument, integers are bound as
+// int64 and everything else as a string.
+func decodeParam(param []byte, oid uint32, paramFormat int) (interface{}, error) {
+	if param == nil {
+		return nil, ErrStatement{Code: SQLSTATE_FEATURE_NOT_SUPPORTED, Message: "NULL arguments are not supported"}
+	}
+	integer := oid == OID_INT8 || oid == OID_INT4 || oid == OID_INT2
+	if paramFormat == FORMAT_TEXT {
+		if integer == false {
+			return string(param), nil
+		}
+		n, err := strconv.ParseInt(string(param), 10, 64)
+		if err != nil {
+			message := fmt.Sprintf("invalid input syntax for type integer: %q", param)
+			return nil, ErrStatement{Code: SQLSTATE_INVALID_TEXT_REPRESENTATION, Message: message}
+		}
+		return n, nil
+	}
+	if paramFormat != FORMAT_BINARY {
+		message := fmt.Sprintf("unsupported format code: %d", paramFormat)
+		return nil, ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+	if integer == false {
+		return string(param), nil
+	}
+	switch len(param) {
+	case 8:
+		return int64(binary.BigEndian.Uint64(param)), nil
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(param))), nil
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(param))), nil
+	default:
+		message := fmt.Sprintf("insufficient data left in message for a %d byte integer", len(param))
+		return nil, ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+}
+
+// resultColumns returns the columns of the rows st returns, nil when it
+// returns no rows.
+func (e *extendedQuery) resultColumns(st statement.Statement, empty bool) []string {
+	if empty || st.Type != statement.StatementType_Select {
+		return nil
+	}
+	return e.table.Columns()
+}
+
+func (e *extendedQuery) describe(writer *bufio.Writer, r *messageReader) error {
+	target := r.bytes(1)
+	name := r.string()
+	if r.err != nil {
+		return protocolViolation(r.err)
+	}
+
+	var columns []string
+	var resultFormats []int
+	switch target[0] {
+	case 'S':
+		prepared := e.statements[name]
+		if prepared == nil {
+			message := fmt.Sprintf("prepared statement %q does not exist", name)
+			return ErrStatement{Code: SQLSTATE_INVALID_STATEMENT_NAME, Message: message}
+		}
+		description := newMessage(MESSAGE_PARAMETER_DESCRIPTION).int16(len(prepared.paramTypes))
+		for _, oid := range prepared.paramTypes {
+			description.int32(int(oid))
+		}
+		writer.Write(description.encode())
+		columns = e.resultColumns(prepared.statement, prepared.empty)
+	case 'P':
+		p := e.portals[name]
+		if p == nil {
+			message := fmt.Sprintf("portal %q does not exist", name)
+			return ErrStatement{Code: SQLSTATE_INVALID_CURSOR_NAME, Message: message}
+		}
+		columns = e.resultColumns(p.statement, p.empty)
+		resultFormats = p.resultFormats
+	default:
+		message := fmt.Sprintf("invalid DESCRIBE message subtype %d", target[0])
+		return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+
+	if columns == nil {
+		writer.Write(newMessage(MESSAGE_NO_DATA).encode())
+		return nil
+	}
+	writer.Write(rowDescription(e.table.Schema(), columns, resultFormats).encode())
+	return nil
+}
+
+// execute runs the portal to completion, the row limit of the Execute
+// message is ignored. The rows are described by Describe, not by Execute.
+func (e *extendedQuery) execute(writer *bufio.Writer, session *statement.Session, r *messageReader) error {
+	name := r.string()
+	r.int32()
+	if r.err != nil {
+		return protocolViolation(r.err)
+	}
+	p := e.portals[name]
+	if p == nil {
+		message := fmt.Sprintf("portal %q does not exist", name)
+		return ErrStatement{Code: SQLSTATE_INVALID_CURSOR_NAME, Message: message}
+	}
+	if p.empty {
+		writer.Write(newMessage(MESSAGE_EMPTY_QUERY_RESPONSE).encode())
+		return nil
+	}
+
+	result, err := session.ExecuteStatement(p.statement)
+	if err != nil {
+		return err
+	}
+	schema := e.table.Schema()
+	for _, row := range result.Rows {
+		writer.Write(dataRow(schema, row, result.Columns, p.resultFormats).encode())
+	}
+	writer.Write(newMessage(MESSAGE_COMMAND_COMPLETE).string(result.Tag).encode())
+	return nil
+}
+
+// close drops a prepared statement or a portal, closing one which does not
+// exist is not an error.
+func (e *extendedQuery) close(writer *bufio.Writer, r *messageReader) error {
+	target := r.bytes(1)
+	name := r.string()
+	if r.err != nil {
+		return protocolViolation(r.err)
+	}
+	switch target[0] {
+	case 'S':
+		delete(e.statements, name)
+	case 'P':
+		delete(e.portals, name)
+	default:
+		message := fmt.Sprintf("invalid CLOSE message subtype %d", target[0])
+		return ErrStatement{Code: SQLSTATE_PROTOCOL_VIOLATION, Message: message}
+	}
+	writer.Write(newMessage(MESSAGE_CLOSE_COMPLETE).encode())
+	return nil
+}
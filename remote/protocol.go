@@ -0,0 +1,286 @@
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The server speaks the PostgreSQL frontend/backend protocol version 3, so
+// psql and stock Postgres drivers can connect to it. There is no
+// authentication and no TLS:
+//
+//	> StartupMessage
+//	< AuthenticationOk, ParameterStatus*, BackendKeyData, ReadyForQuery
+//	> Query "select * from users"
+//	< RowDescription, DataRow*, CommandComplete, ReadyForQuery
+//	> Parse "select * from users where id = $1", Bind, Describe, Execute, Sync
+//	< ParseComplete, BindComplete, RowDescription, DataRow*, CommandComplete,
+//	  ReadyForQuery
+//	> Terminate
+//
+// The extended query flow supports named and unnamed statements and portals,
+// arguments and results in text or binary format, but Execute ignores its
+// row limit and NULL arguments are rejected.
+//
+// Every message but the startup ones begins with a type byte, then the
+// length of the message including the length itself as a big endian int32.
+const DEFAULT_ADDRESS = "127.0.0.1:5433"
+
+const PROTOCOL_VERSION = 196608
+const SSL_REQUEST_CODE = 80877103
+const GSSENC_REQUEST_CODE = 80877104
+const CANCEL_REQUEST_CODE = 80877102
+
+// SERVER_VERSION is reported to clients, psql and drivers pick features by
+// it.
+const SERVER_VERSION = "14.0"
+
+const MAX_MESSAGE_SIZE = 1 << 24
+
+// frontend messages
+const MESSAGE_QUERY = 'Q'
+const MESSAGE_TERMINATE = 'X'
+const MESSAGE_PARSE = 'P'
+const MESSAGE_BIND = 'B'
+const MESSAGE_DESCRIBE = 'D'
+const MESSAGE_EXECUTE = 'E'
+const MESSAGE_CLOSE = 'C'
+const MESSAGE_FLUSH = 'H'
+const MESSAGE_SYNC = 'S'
+
+// backend messages
+const MESSAGE_AUTHENTICATION = 'R'
+const MESSAGE_PARAMETER_STATUS = 'S'
+const MESSAGE_BACKEND_KEY_DATA = 'K'
+const MESSAGE_READY_FOR_QUERY = 'Z'
+const MESSAGE_ROW_DESCRIPTION = 'T'
+const MESSAGE_DATA_ROW = 'D'
+const MESSAGE_COMMAND_COMPLETE = 'C'
+const MESSAGE_EMPTY_QUERY_RESPONSE = 'I'
+const MESSAGE_ERROR_RESPONSE = 'E'
+const MESSAGE_NOTICE_RESPONSE = 'N'
+const MESSAGE_PARSE_COMPLETE = '1'
+const MESSAGE_BIND_COMPLETE = '2'
+const MESSAGE_CLOSE_COMPLETE = '3'
+const MESSAGE_PARAMETER_DESCRIPTION = 't'
+const MESSAGE_NO_DATA = 'n'
+
+// transaction status of ReadyForQuery
+const TRANSACTION_STATUS_IDLE = 'I'
+const TRANSACTION_STATUS_IN_TRANSACTION = 'T'
+
+// Postgres type OIDs, uint32 does not fit into int4 so it is sent as int8.
+const OID_INT8 = 20
+const OID_INT2 = 21
+const OID_INT4 = 23
+const OID_TEXT = 25
+
+// format codes of arguments and results
+const FORMAT_TEXT = 0
+const FORMAT_BINARY = 1
+
+// SQLSTATE codes
+const SQLSTATE_SYNTAX_ERROR = "42601"
+const SQLSTATE_FEATURE_NOT_SUPPORTED = "0A000"
+const SQLSTATE_PROTOCOL_VIOLATION = "08P01"
+const SQLSTATE_INTERNAL_ERROR = "XX000"
+const SQLSTATE_IDLE_IN_TRANSACTION_TIMEOUT = "25P03"
+const SQLSTATE_INVALID_TEXT_REPRESENTATION = "22P02"
+const SQLSTATE_DUPLICATE_PREPARED_STATEMENT = "42P05"
+const SQLSTATE_INVALID_STATEMENT_NAME = "26000"
+const SQLSTATE_INVALID_CURSOR_NAME = "34000"
+
+var ErrServerClosed = errors.New("server closed")
+
+// ErrStatement is returned by Client.Execute when the statement fails on the
+// server.
+type ErrStatement struct {
+	Code    string
+	Message string
+}
+
+func (e ErrStatement) Error() string {
+	return e.Message
+}
+
+// typeOID maps a column type of Table.Schema to a Postgres type OID.
+func typeOID(columnType string) uint32 {
+	switch columnType {
+	case "uint32":
+		return OID_INT8
+	default:
+		return OID_TEXT
+	}
+}
+
+// message is the payload of a protocol message under construction.
+type message struct {
+	kind byte
+	body []byte
+}
+
+func newMessage(kind byte) *message {
+	return &message{kind: kind}
+}
+
+func (m *message) int16(n int) *message {
+	m.body = append(m.body, byte(n>>8), byte(n))
+	return m
+}
+
+func (m *message) int32(n int) *message {
+	m.body = append(m.body, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	return m
+}
+
+func (m *message) bytes(bs []byte) *message {
+	m.body = append(m.body, bs...)
+	return m
+}
+
+func (m *message) string(s string) *message {
+	m.body = append(m.body, s...)
+	m.body = append(m.body, 0)
+	return m
+}
+
+// encode returns the message with its type and length, startup messages
+// have no type.
+func (m *message) encode() []byte {
+	bs := make([]byte, 0, len(m.body)+5)
+	if m.kind != 0 {
+		bs = append(bs, m.kind)
+	}
+	length := len(m.body) + 4
+	bs = append(bs, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	return append(bs, m.body...)
+}
+
+func readLength(r io.Reader) (int, error) {
+	bs := make([]byte, 4)
+	_, err := io.ReadFull(r, bs)
+	if err != nil {
+		return 0, err
+	}
+	length := int(binary.BigEndian.Uint32(bs))
+	if length < 4 || length > MAX_MESSAGE_SIZE {
+		message := fmt.Sprintf("invalid message length %d", length)
+		return 0, errors.New(message)
+	}
+	return length - 4, nil
+}
+
+// readStartupMessage reads a message without a type byte.
+func readStartupMessage(r io.Reader) ([]byte, error) {
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	return body, err
+}
+
+func readMessage(r io.Reader) (byte, []byte, error) {
+	kind := make([]byte, 1)
+	_, err := io.ReadFull(r, kind)
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := readStartupMessage(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return kind[0], body, nil
+}
+
+// messageReader decodes the fields of a message body.
+type messageReader struct {
+	body []byte
+	err  error
+}
+
+func (r *messageReader) int16() int {
+	if len(r.body) < 2 {
+		r.err = errors.New("message is too short")
+		return 0
+	}
+	n := int(int16(binary.BigEndian.Uint16(r.body)))
+	r.body = r.body[2:]
+	return n
+}
+
+func (r *messageReader) int32() int {
+	if len(r.body) < 4 {
+		r.err = errors.New("message is too short")
+		return 0
+	}
+	n := int(int32(binary.BigEndian.Uint32(r.body)))
+	r.body = r.body[4:]
+	return n
+}
+
+func (r *messageReader) bytes(n int) []byte {
+	if n < 0 || len(r.body) < n {
+		r.err = errors.New("message is too short")
+		return nil
+	}
+	bs := r.body[:n]
+	r.body = r.body[n:]
+	return bs
+}
+
+func (r *messageReader) string() string {
+	end := strings.IndexByte(string(r.body), 0)
+	if end < 0 {
+		r.err = errors.New("string is not terminated")
+		return ""
+	}
+	s := string(r.body[:end])
+	r.body = r.body[end+1:]
+	return s
+}
+
+func errorResponse(code string, text string) *message {
+	return newMessage(MESSAGE_ERROR_RESPONSE).
+		bytes([]byte{'S'}).string("ERROR").
+		bytes([]byte{'V'}).string("ERROR").
+		bytes([]byte{'C'}).string(code).
+		bytes([]byte{'M'}).string(text).
+		bytes([]byte{0})
+}
+
+// fatalResponse is an ErrorResponse sent right before the server closes the
+// connection.
+func fatalResponse(code string, text string) *message {
+	return newMessage(MESSAGE_ERROR_RESPONSE).
+		bytes([]byte{'S'}).string("FATAL").
+		bytes([]byte{'V'}).string("FATAL").
+		bytes([]byte{'C'}).string(code).
+		bytes([]byte{'M'}).string(text).
+		bytes([]byte{0})
+}
+
+// parseErrorResponse reads the fields of an ErrorResponse or a
+// NoticeResponse.
+func parseErrorResponse(body []byte) ErrStatement {
+	r := &messageReader{body: body}
+	e := ErrStatement{}
+	for len(r.body) > 0 && r.body[0] != 0 {
+		field := r.bytes(1)[0]
+		value := r.string()
+		if r.err != nil {
+			break
+		}
+		switch field {
+		case 'C':
+			e.Code = value
+		case 'M':
+			e.Message = value
+		}
+	}
+	return e
+}
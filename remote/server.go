@@ -0,0 +1,347 @@
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// DEFAULT_IDLE_IN_TRANSACTION_TIMEOUT is how long a session can wait for its
+// next query inside a transaction, a transaction which writes blocks the
+// writes of every other session.
+const DEFAULT_IDLE_IN_TRANSACTION_TIMEOUT = time.Minute
+
+// Server owns the table and serves Postgres clients over TCP, every
+// connection runs in its own goroutine with its own transaction state, and
+// all of them share the buffer pool of the table.
+type Server struct {
+	// IdleInTransactionTimeout closes a session which is idle inside a
+	// transaction for longer, its transaction is rolled back. 0 disables it.
+	IdleInTransactionTimeout time.Duration
+
+	table    *core.Table
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	closed   bool
+	sessions sync.WaitGroup
+	// processID numbers the sessions for BackendKeyData
+	processID int
+}
+
+func NewServer(table *core.Table) *Server {
+	return &Server{
+		IdleInTransactionTimeout: DEFAULT_IDLE_IN_TRANSACTION_TIMEOUT,
+		table:                    table,
+		conns:                    make(map[net.Conn]bool),
+	}
+}
+
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections from listener until Close is called, it always
+// returns a non-nil error, ErrServerClosed after Close.
+func (s *Server) Serve(listener net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.lock.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if s.track(conn) == false {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = true
+	s.sessions.Add(1)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.conns, conn)
+	conn.Close()
+}
+
+// serveConn runs the startup handshake and then executes queries from conn
+// until the client terminates, the open transaction of the session is
+// rolled back when the client goes away or stays idle inside the
+// transaction for longer than IdleInTransactionTimeout.
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.sessions.Done()
+	defer s.untrack(conn)
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	if s.startup(reader, writer) == false {
+		return
+	}
+
+	session := statement.NewSession(s.table)
+	defer session.Close()
+
+	extended := newExtendedQuery(s.table)
+	// after an error in an extended query the messages up to Sync are
+	// discarded
+	discarding := false
+	for {
+		err := s.setIdleDeadline(conn, session)
+		if err != nil {
+			return
+		}
+		kind, body, err := readMessage(reader)
+		if err != nil {
+			netErr, ok := err.(net.Error)
+			if ok && netErr.Timeout() {
+				writer.Write(fatalResponse(SQLSTATE_IDLE_IN_TRANSACTION_TIMEOUT, "terminating connection due to idle-in-transaction timeout").encode())
+				writer.Flush()
+			}
+			return
+		}
+
+		switch kind {
+		case MESSAGE_QUERY:
+			r := &messageReader{body: body}
+			text := r.string()
+			if r.err != nil {
+				writer.Write(errorResponse(SQLSTATE_PROTOCOL_VIOLATION, r.err.Error()).encode())
+				writer.Flush()
+				return
+			}
+			s.executeQuery(writer, session, text)
+			writer.Write(readyForQuery(session).encode())
+		case MESSAGE_TERMINATE:
+			return
+		case MESSAGE_SYNC:
+			discarding = false
+			extended.sync(session)
+			writer.Write(readyForQuery(session).encode())
+		case MESSAGE_PARSE, MESSAGE_BIND, MESSAGE_DESCRIBE, MESSAGE_EXECUTE, MESSAGE_CLOSE, MESSAGE_FLUSH:
+			if discarding {
+				break
+			}
+			err = extended.handle(writer, session, kind, body)
+			if err != nil {
+				discarding = true
+				writer.Write(errorResponse(sqlState(err), err.Error()).encode())
+			}
+		default:
+			text := fmt.Sprintf("unexpected message type %q", kind)
+			writer.Write(errorResponse(SQLSTATE_PROTOCOL_VIOLATION, text).encode())
+			writer.Flush()
+			return
+		}
+
+		err = writer.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// setIdleDeadline limits the wait for the next message while the session is
+// inside a transaction.
+func (s *Server) setIdleDeadline(conn net.Conn, session *statement.Session) error {
+	if session.InTransaction() && s.IdleInTransactionTimeout > 0 {
+		return conn.SetReadDeadline(time.Now().Add(s.IdleInTransactionTimeout))
+	}
+	return conn.SetReadDeadline(time.Time{})
+}
+
+// startup negotiates the protocol with the client, SSL and GSS encryption
+// are declined. It returns false when the connection should be closed.
+func (s *Server) startup(reader *bufio.Reader, writer *bufio.Writer) bool {
+	for {
+		body, err := readStartupMessage(reader)
+		if err != nil {
+			return false
+		}
+		r := &messageReader{body: body}
+		code := r.int32()
+		if r.err != nil {
+			return false
+		}
+
+		switch code {
+		case SSL_REQUEST_CODE, GSSENC_REQUEST_CODE:
+			writer.WriteByte('N')
+			err = writer.Flush()
+			if err != nil {
+				return false
+			}
+			continue
+		case CANCEL_REQUEST_CODE:
+			// statements can not be cancelled
+			return false
+		case PROTOCOL_VERSION:
+		default:
+			text := fmt.Sprintf("unsupported frontend protocol %d.%d", code>>16, code&0xffff)
+			writer.Write(errorResponse(SQLSTATE_FEATURE_NOT_SUPPORTED, text).encode())
+			writer.Flush()
+			return false
+		}
+
+		writer.Write(newMessage(MESSAGE_AUTHENTICATION).int32(0).encode())
+		parameters := [][2]string{
+			{"server_version", SERVER_VERSION},
+			{"server_encoding", "UTF8"},
+			{"client_encoding", "UTF8"},
+			{"DateStyle", "ISO, MDY"},
+			{"integer_datetimes", "on"},
+			{"standard_conforming_strings", "on"},
+		}
+		for _, parameter := range parameters {
+			writer.Write(newMessage(MESSAGE_PARAMETER_STATUS).string(parameter[0]).string(parameter[1]).encode())
+		}
+		writer.Write(newMessage(MESSAGE_BACKEND_KEY_DATA).int32(s.nextProcessID()).int32(0).encode())
+		writer.Write(newMessage(MESSAGE_READY_FOR_QUERY).bytes([]byte{TRANSACTION_STATUS_IDLE}).encode())
+		return writer.Flush() == nil
+	}
+}
+
+func (s *Server) nextProcessID() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.processID++
+	return s.processID
+}
+
+// executeQuery executes the statements of a Query message in order, the
+// statements after a failed one are skipped.
+func (s *Server) executeQuery(writer *bufio.Writer, session *statement.Session, text string) {
+	statements := statement.Split(text)
+	if len(statements) == 0 {
+		writer.Write(newMessage(MESSAGE_EMPTY_QUERY_RESPONSE).encode())
+		return
+	}
+
+	for _, text := range statements {
+		result, err := session.Execute(text)
+		if err != nil {
+			writer.Write(errorResponse(sqlState(err), err.Error()).encode())
+			return
+		}
+		if result.Columns != nil {
+			s.writeRows(writer, result)
+		}
+		writer.Write(newMessage(MESSAGE_COMMAND_COMPLETE).string(result.Tag).encode())
+	}
+}
+
+// writeRows sends the rows of result in text format.
+func (s *Server) writeRows(writer *bufio.Writer, result *statement.Result) {
+	schema := s.table.Schema()
+	writer.Write(rowDescription(schema, result.Columns, nil).encode())
+	for _, row := range result.Rows {
+		writer.Write(dataRow(schema, row, result.Columns, nil).encode())
+	}
+}
+
+// rowDescription describes columns sent in formats, see format.
+func rowDescription(schema map[string]string, columns []string, formats []int) *message {
+	description := newMessage(MESSAGE_ROW_DESCRIPTION).int16(len(columns))
+	for i, column := range columns {
+		oid := typeOID(schema[column])
+		typeSize := -1
+		if oid == OID_INT8 {
+			typeSize = 8
+		}
+		description.string(column).int32(0).int16(0).int32(int(oid)).int16(typeSize).int32(-1).int16(format(formats, i))
+	}
+	return description
+}
+
+// dataRow encodes the columns of row in formats, an int8 is sent in binary
+// as 8 bytes big endian and a text as its bytes.
+func dataRow(schema map[string]string, row *core.Row, columns []string, formats []int) *message {
+	m := newMessage(MESSAGE_DATA_ROW).int16(len(columns))
+	for i, column := range columns {
+		value, _ := core.GetValueFromRow(row, column)
+		bs := []byte(fmt.Sprint(value))
+		if format(formats, i) == FORMAT_BINARY && typeOID(schema[column]) == OID_INT8 {
+			bs = make([]byte, 8)
+			binary.BigEndian.PutUint64(bs, uint64(value.(uint32)))
+		}
+		m.int32(len(bs)).bytes(bs)
+	}
+	return m
+}
+
+func readyForQuery(session *statement.Session) *message {
+	status := byte(TRANSACTION_STATUS_IDLE)
+	if session.InTransaction() {
+		status = TRANSACTION_STATUS_IN_TRANSACTION
+	}
+	return newMessage(MESSAGE_READY_FOR_QUERY).bytes([]byte{status})
+}
+
+func sqlState(err error) string {
+	e, ok := err.(ErrStatement)
+	if ok {
+		return e.Code
+	}
+	switch err.Error() {
+	case "PREPARE_SYNTAX_ERROR", "UNRECOGNIZED_STATEMENT":
+		return SQLSTATE_SYNTAX_ERROR
+	default:
+		return SQLSTATE_INTERNAL_ERROR
+	}
+}
+
+// Close stops accepting connections, closes every session and waits for
+// them to end.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.sessions.Wait()
+	return err
+}
@@ -0,0 +1,312 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+func startTestServer(t *testing.T) (*Server, string, func()) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	table, err := core.OpenTable(filepath.Join(dir, "test.db"))
+	assert.Nil(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	server := NewServer(table)
+	go server.Serve(listener)
+	return server, listener.Addr().String(), func() {
+		server.Close()
+		table.CloseTable()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestClientExecute(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	client, _ := Dial(address)
+	defer client.Close()
+
+	response, err := client.Execute("insert 1 harry harry@hogwarts.edu")
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT 0 1", response.Tag)
+
+	response, err = client.Execute("select * from users")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 1", response.Tag)
+	assert.Equal(t, []string{"id", "username", "email"}, response.Columns)
+	assert.Equal(t, [][]*string{rowValues("1", "harry", "harry@hogwarts.edu")}, response.Rows)
+
+	_, err = client.Execute("insert 1 harry")
+	assert.Equal(t, ErrStatement{Code: SQLSTATE_SYNTAX_ERROR, Message: "PREPARE_SYNTAX_ERROR"}, err)
+}
+
+func rowValues(values ...string) []*string {
+	row := []*string{}
+	for i := range values {
+		row = append(row, &values[i])
+	}
+	return row
+}
+
+func TestClientExecuteSeveralStatements(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	client, _ := Dial(address)
+	defer client.Close()
+
+	response, err := client.Execute("begin; insert 1 harry harry@hogwarts.edu; select * from users;")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 1", response.Tag)
+
+	_, err = client.Execute("insert 2 ron ron@hogwarts.edu; insert 2; insert 3 hermione hermione@hogwarts.edu")
+	assert.Equal(t, "PREPARE_SYNTAX_ERROR", err.Error())
+	response, _ = client.Execute("commit; select * from users")
+	assert.Equal(t, "SELECT 2", response.Tag)
+}
+
+func TestStartupDeclinesSSL(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	conn, _ := net.Dial("tcp", address)
+	defer conn.Close()
+
+	conn.Write(newMessage(0).int32(SSL_REQUEST_CODE).encode())
+	answer := make([]byte, 1)
+	conn.Read(answer)
+	assert.Equal(t, []byte{'N'}, answer)
+
+	conn.Write(newMessage(0).int32(PROTOCOL_VERSION).string("user").string("psql").bytes([]byte{0}).encode())
+	reader := bufio.NewReader(conn)
+	kind, body, _ := readMessage(reader)
+	assert.Equal(t, byte(MESSAGE_AUTHENTICATION), kind)
+	assert.Equal(t, []byte{0, 0, 0, 0}, body)
+	for kind != MESSAGE_READY_FOR_QUERY {
+		kind, body, _ = readMessage(reader)
+	}
+	assert.Equal(t, []byte{TRANSACTION_STATUS_IDLE}, body)
+}
+
+func TestRowDescriptionTypes(t *testing.T) {
+	server, _, cleanup := startTestServer(t)
+	defer cleanup()
+	buffer := &bytes.Buffer{}
+	writer := bufio.NewWriter(buffer)
+
+	server.writeRows(writer, &statement.Result{Columns: []string{"id", "email"}})
+	writer.Flush()
+
+	kind, body, _ := readMessage(buffer)
+	assert.Equal(t, byte(MESSAGE_ROW_DESCRIPTION), kind)
+	r := &messageReader{body: body}
+	assert.Equal(t, 2, r.int16())
+	assert.Equal(t, "id", r.string())
+	r.int32()
+	r.int16()
+	assert.Equal(t, OID_INT8, r.int32())
+	r.bytes(8)
+	assert.Equal(t, "email", r.string())
+	r.int32()
+	r.int16()
+	assert.Equal(t, OID_TEXT, r.int32())
+}
+
+func TestSessionsHaveTheirOwnTransaction(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	writer, _ := Dial(address)
+	defer writer.Close()
+	reader, _ := Dial(address)
+	defer reader.Close()
+
+	writer.Execute("begin")
+	writer.Execute("insert 1 harry harry@hogwarts.edu")
+	// the reader does not wait for the transaction and does not see its rows
+	response, err := reader.Execute("select * from users")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 0", response.Tag)
+	writer.Execute("insert 2 ron ron@hogwarts.edu")
+	writer.Execute("commit")
+
+	response, _ = reader.Execute("select * from users")
+	assert.Equal(t, "SELECT 2", response.Tag)
+}
+
+func TestDisconnectRollsBackTransaction(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	client, _ := Dial(address)
+	client.Execute("begin")
+	client.Execute("insert 1 harry harry@hogwarts.edu")
+	client.Close()
+
+	client, _ = Dial(address)
+	defer client.Close()
+	response, err := client.Execute("select * from users")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 0", response.Tag)
+}
+
+func TestIdleInTransactionTimeout(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	table, _ := core.OpenTable(filepath.Join(dir, "test.db"))
+	defer table.CloseTable()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	server := NewServer(table)
+	server.IdleInTransactionTimeout = 100 * time.Millisecond
+	go server.Serve(listener)
+	defer server.Close()
+	idle, _ := Dial(listener.Addr().String())
+	defer idle.Close()
+	client, _ := Dial(listener.Addr().String())
+	defer client.Close()
+
+	idle.Execute("begin")
+	idle.Execute("insert 1 harry harry@hogwarts.edu")
+	// waits for the idle session to be closed
+	_, err := client.Execute("insert 2 ron ron@hogwarts.edu")
+
+	assert.Nil(t, err)
+	_, err = idle.Execute("commit")
+	assert.NotNil(t, err)
+	response, _ := client.Execute("select * from users")
+	assert.Equal(t, "SELECT 1", response.Tag)
+	assert.Equal(t, "2", *response.Rows[0][0])
+}
+
+func TestConcurrentClients(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client, _ := Dial(address)
+			defer client.Close()
+			for j := 1; j <= 10; j++ {
+				id := i*10 + j
+				client.Execute(fmt.Sprintf("insert %d user%d user%d@test.com", id, id, id))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	client, _ := Dial(address)
+	defer client.Close()
+	response, _ := client.Execute("select * from users")
+	assert.Equal(t, "SELECT 40", response.Tag)
+}
+
+func TestServeAfterClose(t *testing.T) {
+	server, _, cleanup := startTestServer(t)
+	defer cleanup()
+	server.Close()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	err := server.Serve(listener)
+
+	assert.Equal(t, ErrServerClosed, err)
+}
+
+// dialRaw connects to the server and reads up to the first ReadyForQuery.
+func dialRaw(t *testing.T, address string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", address)
+	assert.Nil(t, err)
+	conn.Write(newMessage(0).int32(PROTOCOL_VERSION).string("user").string("sqlbit").bytes([]byte{0}).encode())
+	reader := bufio.NewReader(conn)
+	readMessages(t, reader)
+	return conn, reader
+}
+
+// readMessages reads the messages up to ReadyForQuery, which is included.
+func readMessages(t *testing.T, reader *bufio.Reader) ([]byte, [][]byte) {
+	kinds := []byte{}
+	bodies := [][]byte{}
+	for true {
+		kind, body, err := readMessage(reader)
+		assert.Nil(t, err)
+		if err != nil {
+			return kinds, bodies
+		}
+		kinds = append(kinds, kind)
+		bodies = append(bodies, body)
+		if kind == MESSAGE_READY_FOR_QUERY {
+			return kinds, bodies
+		}
+	}
+	return kinds, bodies
+}
+
+func TestExtendedQuery(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	conn, reader := dialRaw(t, address)
+	defer conn.Close()
+
+	// the id is sent in binary
+	id := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	conn.Write(newMessage(MESSAGE_PARSE).string("").string("insert $1 $2 $3").int16(0).encode())
+	conn.Write(newMessage(MESSAGE_BIND).string("").string("").int16(3).int16(1).int16(0).int16(0).
+		int16(3).int32(8).bytes(id).int32(5).bytes([]byte("harry")).int32(18).bytes([]byte("harry@hogwarts.edu")).
+		int16(0).encode())
+	conn.Write(newMessage(MESSAGE_DESCRIBE).bytes([]byte{'P'}).string("").encode())
+	conn.Write(newMessage(MESSAGE_EXECUTE).string("").int32(0).encode())
+	conn.Write(newMessage(MESSAGE_SYNC).encode())
+	kinds, bodies := readMessages(t, reader)
+	assert.Equal(t, "12nCZ", string(kinds))
+	assert.Equal(t, "INSERT 0 1\x00", string(bodies[3]))
+
+	conn.Write(newMessage(MESSAGE_PARSE).string("by_id").string("select * from users where id = $1").int16(0).encode())
+	conn.Write(newMessage(MESSAGE_DESCRIBE).bytes([]byte{'S'}).string("by_id").encode())
+	conn.Write(newMessage(MESSAGE_BIND).string("").string("by_id").int16(0).int16(1).int32(1).bytes([]byte("1")).int16(1).int16(1).encode())
+	conn.Write(newMessage(MESSAGE_EXECUTE).string("").int32(0).encode())
+	conn.Write(newMessage(MESSAGE_CLOSE).bytes([]byte{'S'}).string("by_id").encode())
+	conn.Write(newMessage(MESSAGE_SYNC).encode())
+	kinds, bodies = readMessages(t, reader)
+	assert.Equal(t, "1tT2DC3Z", string(kinds))
+	assert.Equal(t, []byte{0, 1, 0, 0, 0, OID_INT8}, bodies[1])
+	r := &messageReader{body: bodies[4]}
+	assert.Equal(t, 3, r.int16())
+	assert.Equal(t, id, r.bytes(r.int32()))
+	assert.Equal(t, "harry", string(r.bytes(r.int32())))
+	assert.Equal(t, "SELECT 1\x00", string(bodies[5]))
+}
+
+func TestExtendedQueryErrorDiscardsUntilSync(t *testing.T) {
+	_, address, cleanup := startTestServer(t)
+	defer cleanup()
+	conn, reader := dialRaw(t, address)
+	defer conn.Close()
+
+	conn.Write(newMessage(MESSAGE_BIND).string("").string("missing").int16(0).int16(0).int16(0).encode())
+	conn.Write(newMessage(MESSAGE_EXECUTE).string("").int32(0).encode())
+	conn.Write(newMessage(MESSAGE_SYNC).encode())
+	kinds, bodies := readMessages(t, reader)
+
+	assert.Equal(t, "EZ", string(kinds))
+	assert.Equal(t, ErrStatement{Code: SQLSTATE_INVALID_STATEMENT_NAME, Message: `prepared statement "missing" does not exist`}, parseErrorResponse(bodies[0]))
+
+	conn.Write(newMessage(MESSAGE_PARSE).string("").string("select * from users where id = $1").int16(0).encode())
+	conn.Write(newMessage(MESSAGE_BIND).string("").string("").int16(0).int16(1).int32(3).bytes([]byte("one")).int16(0).encode())
+	conn.Write(newMessage(MESSAGE_SYNC).encode())
+	kinds, bodies = readMessages(t, reader)
+	assert.Equal(t, "1EZ", string(kinds))
+	assert.Equal(t, SQLSTATE_INVALID_TEXT_REPRESENTATION, parseErrorResponse(bodies[1]).Code)
+}
@@ -0,0 +1,119 @@
+package sqlbit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// Rows is the result of a query, it is not safe for concurrent use.
+//
+//	for rows.Next() {
+//		err = rows.Scan(&id, &username, &email)
+//	}
+//	err = rows.Err()
+type Rows struct {
+	columns []string
+	next    func() (*core.Row, error)
+	row     *core.Row
+	err     error
+	closed  bool
+}
+
+func newRows(columns []string, next func() (*core.Row, error)) *Rows {
+	return &Rows{
+		columns: columns,
+		next:    next,
+	}
+}
+
+func (r *Rows) Columns() []string {
+	return r.columns
+}
+
+// Next moves to the next row, it returns false at the end of the rows or on
+// error, which is reported by Err.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	r.row, r.err = r.next()
+	if r.err != nil || r.row == nil {
+		r.Close()
+		return false
+	}
+	return true
+}
+
+// Scan copies the columns of the current row into dest, a column can be
+// scanned into a pointer to its own type, to a wider integer, or to an
+// interface{}.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if r.row == nil {
+		return errors.New("sqlbit: Scan called without calling Next")
+	}
+	if len(dest) != len(r.columns) {
+		message := fmt.Sprintf("sqlbit: expected %d destination arguments in Scan, got %d", len(r.columns), len(dest))
+		return errors.New(message)
+	}
+
+	for i, column := range r.columns {
+		value, err := core.GetValueFromRow(r.row, column)
+		if err != nil {
+			return err
+		}
+		err = assign(dest[i], value)
+		if err != nil {
+			message := fmt.Sprintf("sqlbit: column %s: %s", column, err)
+			return errors.New(message)
+		}
+	}
+	return nil
+}
+
+func assign(dest interface{}, value interface{}) error {
+	switch v := value.(type) {
+	case uint32:
+		switch d := dest.(type) {
+		case *uint32:
+			*d = v
+			return nil
+		case *uint64:
+			*d = uint64(v)
+			return nil
+		case *int64:
+			*d = int64(v)
+			return nil
+		case *int:
+			*d = int(v)
+			return nil
+		}
+	case string:
+		switch d := dest.(type) {
+		case *string:
+			*d = v
+			return nil
+		case *[]byte:
+			*d = []byte(v)
+			return nil
+		}
+	}
+	if d, ok := dest.(*interface{}); ok {
+		*d = value
+		return nil
+	}
+	message := fmt.Sprintf("can not scan %T into %T", value, dest)
+	return errors.New(message)
+}
+
+// Err returns the error which ended the iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close stops the iteration, it is called by Next at the end of the rows.
+func (r *Rows) Close() error {
+	r.closed = true
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package sqldriver registers sqlbit as the "sqlbit" database/sql driver,
+// the data source name is the path of the database file:
+//
+//	db, err := sql.Open("sqlbit", "/path/to.db")
+//	db.Exec("insert ? ? ?", 1, "harry potter", "harry@hogwarts.edu")
+//
+// The pooled connections of a file share its write lock, so a write waits
+// for the transaction of another connection to end and fails with "database
+// is locked" after the busy timeout, 5 seconds by default. The DSN
+// "/path/to.db?busy_timeout=100" sets it in milliseconds, the first
+// connection to a file decides it.
+//
+// The DSN ":memory:" opens a database in memory, its pooled connections
+// share one table which is dropped when the last of them is closed.
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+func init() {
+	sql.Register("sqlbit", &Driver{})
+}
+
+// Driver opens connections to database files, the connections to the same
+// file share one table, so they share its buffer pool and its lock. The
+// tables are keyed by file name, so every connection to ":memory:" shares
+// the same in-memory table.
+type Driver struct {
+	lock   sync.Mutex
+	tables map[string]*sharedTable
+}
+
+type sharedTable struct {
+	table *core.Table
+	conns int
+}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	path, options, err := parseDSN(name)
+	if err != nil {
+		return nil, err
+	}
+	fileName := path
+	if path != core.MEMORY_FILE_NAME {
+		fileName, err = filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	table, err := d.acquire(fileName, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{
+		driver:   d,
+		fileName: fileName,
+		table:    table,
+		session:  statement.NewSession(table),
+	}, nil
+}
+
+// parseDSN splits the data source name into the path of the file and the
+// options of its table.
+func parseDSN(name string) (string, *core.TableOptions, error) {
+	options := core.DefaultTableOptions()
+	end := strings.IndexByte(name, '?')
+	if end < 0 {
+		return name, options, nil
+	}
+	query, err := url.ParseQuery(name[end+1:])
+	if err != nil {
+		return "", nil, err
+	}
+	for key, values := range query {
+		switch key {
+		case "busy_timeout":
+			ms, err := strconv.Atoi(values[0])
+			if err != nil || ms <= 0 {
+				message := fmt.Sprintf("busy_timeout must be a positive number of milliseconds, got %q", values[0])
+				return "", nil, errors.New(message)
+			}
+			options.BusyTimeout = time.Duration(ms) * time.Millisecond
+		default:
+			message := fmt.Sprintf("unknown DSN parameter %q", key)
+			return "", nil, errors.New(message)
+		}
+	}
+	return name[:end], options, nil
+}
+
+func (d *Driver) acquire(fileName string, options *core.TableOptions) (*core.Table, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.tables == nil {
+		d.tables = make(map[string]*sharedTable)
+	}
+
+	shared := d.tables[fileName]
+	if shared == nil {
+		table, err := core.OpenTableWithOptions(fileName, options)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedTable{table: table}
+		d.tables[fileName] = shared
+	}
+	shared.conns++
+	return shared.table, nil
+}
+
+// release closes the table when its last connection is closed.
+func (d *Driver) release(fileName string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	shared := d.tables[fileName]
+	shared.conns--
+	if shared.conns == 0 {
+		delete(d.tables, fileName)
+		return shared.table.CloseTable()
+	}
+	return nil
+}
+
+// Conn is a session on the table, its statements run in their own
+// transaction unless Begin is called.
+type Conn struct {
+	driver   *Driver
+	fileName string
+	table    *core.Table
+	session  *statement.Session
+	closed   bool
+}
+
+// Prepare parses query once, the statement is bound to the arguments of
+// every execution.
+func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+	st, err := statement.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{
+		conn:      c,
+		statement: st,
+	}, nil
+}
+
+// Close rolls back the open transaction of the connection, closing the last
+// connection of a file reports the pages which could not be written.
+func (c *Conn) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.session.Close()
+	return c.driver.release(c.fileName)
+}
+
+// Begin starts a transaction, its first write waits for the transaction of
+// the connection which writes to end, up to the busy timeout.
+func (c *Conn) Begin() (driver.Tx, error) {
+	_, err := c.execute("begin")
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{conn: c}, nil
+}
+
+func (c *Conn) execute(text string) (*statement.Result, error) {
+	st, err := statement.Prepare(text)
+	if err != nil {
+		return nil, err
+	}
+	return c.executeStatement(st)
+}
+
+func (c *Conn) executeStatement(st statement.Statement) (*statement.Result, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+	return c.session.ExecuteStatement(st)
+}
+
+// Tx is the transaction opened by Conn.Begin.
+type Tx struct {
+	conn *Conn
+}
+
+func (tx *Tx) Commit() error {
+	_, err := tx.conn.execute("commit")
+	return err
+}
+
+func (tx *Tx) Rollback() error {
+	_, err := tx.conn.execute("rollback")
+	return err
+}
+
+// Stmt is a prepared statement with ? or $n placeholders.
+type Stmt struct {
+	conn      *Conn
+	statement statement.Statement
+}
+
+func (s *Stmt) Close() error {
+	return nil
+}
+
+func (s *Stmt) NumInput() int {
+	return s.statement.NumParams()
+}
+
+func (s *Stmt) run(args []driver.Value) (*statement.Result, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	st, err := statement.Bind(s.statement, values)
+	if err != nil {
+		return nil, err
+	}
+	return s.conn.executeStatement(st)
+}
+
+func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, err := s.run(args)
+	if err != nil {
+		return nil, err
+	}
+	return newExecResult(result), nil
+}
+
+func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
+	result, err := s.run(args)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{
+		schema: s.conn.table.Schema(),
+		result: result,
+	}, nil
+}
+
+// execResult reports the number of rows in the command tag.
+type execResult struct {
+	rowsAffected int64
+}
+
+func newExecResult(result *statement.Result) *execResult {
+	r := &execResult{}
+	tokens := strings.Fields(result.Tag)
+	if len(tokens) > 1 {
+		r.rowsAffected, _ = strconv.ParseInt(tokens[len(tokens)-1], 10, 64)
+	}
+	return r
+}
+
+func (r *execResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported")
+}
+
+func (r *execResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
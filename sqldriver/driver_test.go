@@ -0,0 +1,193 @@
+package sqldriver
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) (*sql.DB, func()) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	db, err := sql.Open("sqlbit", filepath.Join(dir, "test.db"))
+	assert.Nil(t, err)
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+type user struct {
+	id       int64
+	username string
+	email    string
+}
+
+func queryUsers(t *testing.T, db *sql.DB, query string, args ...interface{}) []user {
+	rows, err := db.Query(query, args...)
+	assert.Nil(t, err)
+	defer rows.Close()
+	users := []user{}
+	for rows.Next() {
+		u := user{}
+		assert.Nil(t, rows.Scan(&u.id, &u.username, &u.email))
+		users = append(users, u)
+	}
+	assert.Nil(t, rows.Err())
+	return users
+}
+
+func TestExecAndQuery(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	result, err := db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	rowsAffected, _ := result.RowsAffected()
+	assert.Equal(t, int64(1), rowsAffected)
+	db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+
+	users := queryUsers(t, db, "select * from users where id >= ?", 2)
+	assert.Equal(t, []user{{2, "ron", "ron@hogwarts.edu"}}, users)
+	users = queryUsers(t, db, "select * from users where username = ?", "harry")
+	assert.Equal(t, []user{{1, "harry", "harry@hogwarts.edu"}}, users)
+
+	rows, _ := db.Query("select * from users")
+	types, _ := rows.ColumnTypes()
+	rows.Close()
+	assert.Equal(t, "UINT32", types[0].DatabaseTypeName())
+	assert.Equal(t, "STRING", types[1].DatabaseTypeName())
+
+	_, err = db.Exec("insert ? ? ?", 3, "harry potter", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	users = queryUsers(t, db, "select * from users where username = $1", "harry potter")
+	assert.Equal(t, []user{{3, "harry potter", "harry@hogwarts.edu"}}, users)
+
+	_, err = db.Exec("INSERT INTO users VALUES (?, ?, ?), (?, 'fred', 'fred@hogwarts.edu')", 4, "ginny", "ginny@hogwarts.edu", 5)
+	assert.Nil(t, err)
+	users = queryUsers(t, db, "select * from users where id >= ?", 4)
+	assert.Equal(t, []user{{4, "ginny", "ginny@hogwarts.edu"}, {5, "fred", "fred@hogwarts.edu"}}, users)
+}
+
+func TestPreparedStatement(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	insert, err := db.Prepare("insert ? ? ?")
+	assert.Nil(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = insert.Exec(i, "user", "user@test.com")
+		assert.Nil(t, err)
+	}
+	insert.Close()
+
+	_, err = db.Exec("insert ? ? ?", 4, "user")
+	assert.Equal(t, "sql: expected 3 arguments, got 2", err.Error())
+	users := queryUsers(t, db, "select * from users where id > ?", 1)
+	assert.Equal(t, 2, len(users))
+}
+
+func TestTransaction(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+
+	tx, _ := db.Begin()
+	tx.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, tx.Rollback())
+	tx, _ = db.Begin()
+	tx.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	assert.Equal(t, 1, len(queryTxUsers(t, tx)))
+	assert.Nil(t, tx.Commit())
+
+	users := queryUsers(t, db, "select * from users")
+	assert.Equal(t, []user{{2, "ron", "ron@hogwarts.edu"}}, users)
+}
+
+func queryTxUsers(t *testing.T, tx *sql.Tx) []string {
+	rows, err := tx.Query("select * from users")
+	assert.Nil(t, err)
+	defer rows.Close()
+	columns, _ := rows.Columns()
+	assert.Equal(t, []string{"id", "username", "email"}, columns)
+	usernames := []string{}
+	for rows.Next() {
+		var id int64
+		var username, email string
+		rows.Scan(&id, &username, &email)
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+func TestConnectionsShareTable(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	first, _ := db.Conn(context.Background())
+	second, _ := db.Conn(context.Background())
+
+	_, err := first.ExecContext(context.Background(), "insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	var count int
+	rows, _ := second.QueryContext(context.Background(), "select * from users")
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	first.Close()
+	second.Close()
+
+	assert.Equal(t, 1, count)
+}
+
+func TestWriteWhileTransactionIsOpen(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	db, err := sql.Open("sqlbit", filepath.Join(dir, "test.db")+"?busy_timeout=50")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	tx, _ := db.Begin()
+	_, err = tx.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	// the pool runs these on its second connection
+	users := queryUsers(t, db, "select * from users")
+	_, err = db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+
+	assert.Equal(t, []user{}, users)
+	assert.Equal(t, "database is locked", err.Error())
+	assert.Nil(t, tx.Commit())
+	_, err = db.Exec("insert ? ? ?", 2, "ron", "ron@hogwarts.edu")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(queryUsers(t, db, "select * from users")))
+}
+
+func TestOpenWithInvalidBusyTimeout(t *testing.T) {
+	db, _ := sql.Open("sqlbit", "test.db?busy_timeout=soon")
+	defer db.Close()
+
+	err := db.Ping()
+
+	assert.Equal(t, `busy_timeout must be a positive number of milliseconds, got "soon"`, err.Error())
+}
+
+func TestOpenMemoryDatabase(t *testing.T) {
+	db, err := sql.Open("sqlbit", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(2)
+
+	_, err = db.Exec("insert ? ? ?", 1, "harry", "harry@hogwarts.edu")
+	assert.Nil(t, err)
+	tx, _ := db.Begin()
+	users := queryUsers(t, db, "select * from users")
+	tx.Rollback()
+
+	assert.Equal(t, []user{{1, "harry", "harry@hogwarts.edu"}}, users)
+	_, err = os.Stat(":memory:")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,77 @@
+package sqldriver
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/ocowchun/sqlbit/statement"
+)
+
+// Rows iterates over the rows of a statement result.
+type Rows struct {
+	schema map[string]string
+	result *statement.Result
+	next   int
+}
+
+func (r *Rows) Columns() []string {
+	return r.result.Columns
+}
+
+func (r *Rows) Close() error {
+	r.next = len(r.result.Rows)
+	return nil
+}
+
+func (r *Rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.Rows) {
+		return io.EOF
+	}
+	row := r.result.Rows[r.next]
+	r.next++
+
+	for i, column := range r.result.Columns {
+		value, err := core.GetValueFromRow(row, column)
+		if err != nil {
+			return err
+		}
+		dest[i], err = toDriverValue(value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns the type of the column in the table
+// schema, e.g. "UINT32".
+func (r *Rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.schema[r.result.Columns[index]])
+}
+
+func (r *Rows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.schema[r.result.Columns[index]] {
+	case "uint32":
+		return reflect.TypeOf(int64(0))
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+// toDriverValue converts a column value to one of the driver.Value types.
+func toDriverValue(value interface{}) (driver.Value, error) {
+	switch v := value.(type) {
+	case uint32:
+		return int64(v), nil
+	case string:
+		return v, nil
+	default:
+		message := fmt.Sprintf("unsupported column value %T", value)
+		return nil, errors.New(message)
+	}
+}
@@ -0,0 +1,219 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/parser"
+)
+
+// binding ties the argument at index arg to the token at position of an
+// insert or a delete, to the value at position of an INSERT INTO, or to the
+// value of the where clause of a select.
+type binding struct {
+	position int
+	arg      int
+}
+
+// placeholders numbers the placeholders of a statement, ? are numbered in
+// order of appearance while $n refers to the nth argument.
+type placeholders struct {
+	count    int
+	question bool
+	dollar   bool
+}
+
+// index returns the argument index of token, ok is false when token is not
+// a placeholder.
+func (p *placeholders) index(token string) (idx int, ok bool, err error) {
+	if token == "?" {
+		if p.dollar {
+			return 0, true, errors.New("can not mix ? and $n placeholders")
+		}
+		p.question = true
+		p.count++
+		return p.count - 1, true, nil
+	}
+	if strings.HasPrefix(token, "$") == false {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(token[1:])
+	if err != nil {
+		return 0, false, nil
+	}
+	if n < 1 {
+		message := fmt.Sprintf("placeholder %s is invalid", token)
+		return 0, true, errors.New(message)
+	}
+	if p.question {
+		return 0, true, errors.New("can not mix ? and $n placeholders")
+	}
+	p.dollar = true
+	if n > p.count {
+		p.count = n
+	}
+	return n - 1, true, nil
+}
+
+// bindTokens finds the placeholders among tokens.
+func bindTokens(tokens []string) ([]binding, int, error) {
+	p := &placeholders{}
+	bindings := []binding{}
+	for position, token := range tokens {
+		arg, ok, err := p.index(token)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			bindings = append(bindings, binding{position: position, arg: arg})
+		}
+	}
+	return bindings, p.count, nil
+}
+
+// NumParams returns the number of arguments the statement has to be bound
+// to before it is executed.
+func (s Statement) NumParams() int {
+	return s.numParams
+}
+
+// insertColumns are the columns of insert ID USERNAME EMAIL by token position.
+var insertColumns = []string{"", "id", "username", "email"}
+
+// ParamColumns returns the column each argument is bound to, an argument
+// which is never referenced has no column. Clients pick the type of an
+// argument by it.
+func (s Statement) ParamColumns() []string {
+	columns := make([]string, s.numParams)
+	for _, b := range s.bindings {
+		column := ""
+		switch s.Type {
+		case StatementType_Insert:
+			if s.insertInto {
+				column = USER_COLUMNS[b.position%len(USER_COLUMNS)]
+			} else {
+				column = insertColumns[b.position]
+			}
+		case StatementType_Delete:
+			column = "id"
+		default:
+			column = s.QueryPlan.From.Where.Condition.LHS
+		}
+		if columns[b.arg] == "" {
+			columns[b.arg] = column
+		}
+	}
+	return columns
+}
+
+// Bind returns the statement with its placeholders replaced by args, the
+// statement itself is left untouched so it can be bound again.
+func Bind(s Statement, args []interface{}) (Statement, error) {
+	if len(args) != s.numParams {
+		message := fmt.Sprintf("expected %d arguments, got %d", s.numParams, len(args))
+		return Statement{}, errors.New(message)
+	}
+	if s.numParams == 0 {
+		return s, nil
+	}
+
+	switch s.Type {
+	case StatementType_Insert:
+		tokens, err := bindArgsToTokens(s, args)
+		if err != nil {
+			return Statement{}, err
+		}
+		if s.insertInto {
+			return rowsFromTokens(tokens)
+		}
+		return extractUserFromTokens(tokens)
+	case StatementType_Delete:
+		tokens, err := bindArgsToTokens(s, args)
+		if err != nil {
+			return Statement{}, err
+		}
+		return extractIdFromTokens(tokens)
+	default:
+		value, err := argToValue(args[s.bindings[0].arg])
+		if err != nil {
+			return Statement{}, err
+		}
+		return Statement{
+			Type:      s.Type,
+			QueryPlan: bindWhereValue(s.QueryPlan, value),
+		}, nil
+	}
+}
+
+func bindArgsToTokens(s Statement, args []interface{}) ([]string, error) {
+	tokens := make([]string, len(s.tokens))
+	copy(tokens, s.tokens)
+	for _, b := range s.bindings {
+		token, err := argToToken(args[b.arg])
+		if err != nil {
+			return nil, err
+		}
+		tokens[b.position] = token
+	}
+	return tokens, nil
+}
+
+// bindWhereValue copies the parts of plan leading to the where value, the
+// parsed plan is shared by every execution of the statement.
+func bindWhereValue(plan *parser.Select, value *parser.Value) *parser.Select {
+	from := *plan.From
+	where := *from.Where
+	condition := *where.Condition
+	condition.Value = value
+	where.Condition = &condition
+	from.Where = &where
+	return &parser.Select{
+		Expression: plan.Expression,
+		From:       &from,
+	}
+}
+
+func argToToken(arg interface{}) (string, error) {
+	switch v := arg.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		message := fmt.Sprintf("unsupported argument type %T", arg)
+		return "", errors.New(message)
+	}
+}
+
+func argToValue(arg interface{}) (*parser.Value, error) {
+	var number float64
+	switch v := arg.(type) {
+	case string:
+		return &parser.Value{Str: &v}, nil
+	case []byte:
+		s := string(v)
+		return &parser.Value{Str: &s}, nil
+	case int:
+		number = float64(v)
+	case int64:
+		number = float64(v)
+	case uint32:
+		number = float64(v)
+	case float64:
+		number = v
+	default:
+		message := fmt.Sprintf("unsupported argument type %T", arg)
+		return nil, errors.New(message)
+	}
+	return &parser.Value{Number: &number}, nil
+}
@@ -0,0 +1,121 @@
+package statement
+
+import (
+	"testing"
+
+	"github.com/ocowchun/sqlbit/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindInsert(t *testing.T) {
+	s, err := Prepare("insert ? ? ?")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, s.NumParams())
+
+	bound, err := Bind(s, []interface{}{int64(1), "harry potter", "harry@hogwarts.edu"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "harry potter", bound.RowToInsert.Username())
+	assert.Equal(t, 0, bound.NumParams())
+	_, err = Bind(s, []interface{}{"one", "harry", "harry@hogwarts.edu"})
+	assert.Equal(t, "id must be integer", err.Error())
+}
+
+func TestBindNumberedPlaceholders(t *testing.T) {
+	s, _ := Prepare("insert $1 $2 $2")
+	assert.Equal(t, 2, s.NumParams())
+
+	bound, err := Bind(s, []interface{}{1, "harry"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "harry", bound.RowToInsert.Email())
+	_, err = Bind(s, []interface{}{1})
+	assert.Equal(t, "expected 2 arguments, got 1", err.Error())
+	_, err = Prepare("insert $1 ? ?")
+	assert.Equal(t, "can not mix ? and $n placeholders", err.Error())
+}
+
+func TestBindInsertInto(t *testing.T) {
+	s, err := Prepare("INSERT INTO users VALUES (?, ?, ?)")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, s.NumParams())
+
+	bound, err := Bind(s, []interface{}{int64(1), "harry potter", "harry@hogwarts.edu"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*core.Row{core.NewRow(1, "harry potter", "harry@hogwarts.edu")}, bound.RowsToInsert)
+	assert.Equal(t, 0, bound.NumParams())
+	_, err = Bind(s, []interface{}{"one", "harry", "harry@hogwarts.edu"})
+	assert.Equal(t, "id must be integer", err.Error())
+	_, err = Prepare("insert into users values ($1, ?, 'x')")
+	assert.Equal(t, "can not mix ? and $n placeholders", err.Error())
+	_, err = Prepare("insert into users values ('1', ?, 'x')")
+	assert.Equal(t, "id must be integer", err.Error())
+}
+
+func TestBindInsertIntoSeveralRows(t *testing.T) {
+	s, err := Prepare("insert into users (email, id, username) values ($2, 1, $1), ('ron@hogwarts.edu', $3, 'ron')")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, s.NumParams())
+	assert.Equal(t, []string{"username", "email", "id"}, s.ParamColumns())
+
+	bound, err := Bind(s, []interface{}{"harry", "harry@hogwarts.edu", 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*core.Row{
+		core.NewRow(1, "harry", "harry@hogwarts.edu"),
+		core.NewRow(2, "ron", "ron@hogwarts.edu"),
+	}, bound.RowsToInsert)
+	bound, _ = Bind(s, []interface{}{"ginny", "ginny@hogwarts.edu", 3})
+	assert.Equal(t, "ginny", bound.RowsToInsert[0].Username())
+	assert.Equal(t, uint32(3), bound.RowsToInsert[1].Id())
+}
+
+func TestBindSelect(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	Execute(mustPrepare(t, "insert 1 harry harry@hogwarts.edu"), table)
+	Execute(mustPrepare(t, "insert 2 ron ron@hogwarts.edu"), table)
+	s := mustPrepare(t, "select * from users where email = ?")
+
+	_, err := Execute(s, table)
+	assert.Equal(t, "statement has 1 unbound parameters", err.Error())
+	for _, email := range []string{"ron@hogwarts.edu", "harry@hogwarts.edu"} {
+		bound, _ := Bind(s, []interface{}{email})
+		result, err := Execute(bound, table)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(result.Rows))
+		assert.Equal(t, email, result.Rows[0].Email())
+	}
+	assert.NotNil(t, s.QueryPlan.From.Where.Condition.Value.Placeholder)
+
+	s = mustPrepare(t, "select * from users where id > $1")
+	bound, _ := Bind(s, []interface{}{int64(1)})
+	result, _ := Execute(bound, table)
+	assert.Equal(t, "SELECT 1", result.Tag)
+}
+
+func TestBindDelete(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	Execute(mustPrepare(t, "insert 1 harry harry@hogwarts.edu"), table)
+
+	bound, _ := Bind(mustPrepare(t, "delete ?"), []interface{}{uint32(1)})
+	result, err := Execute(bound, table)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE 1", result.Tag)
+}
+
+func mustPrepare(t *testing.T, text string) Statement {
+	s, err := Prepare(text)
+	assert.Nil(t, err)
+	return s
+}
+
+func TestParamColumns(t *testing.T) {
+	assert.Equal(t, []string{"id", "username", "email"}, mustPrepare(t, "insert ? ? ?").ParamColumns())
+	assert.Equal(t, []string{"email", "username"}, mustPrepare(t, "insert 1 $2 $1").ParamColumns())
+	assert.Equal(t, []string{"id"}, mustPrepare(t, "delete $1").ParamColumns())
+	assert.Equal(t, []string{"email"}, mustPrepare(t, "select * from users where email = ?").ParamColumns())
+}
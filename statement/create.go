@@ -0,0 +1,122 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// columnDefinition is a column of CREATE TABLE.
+type columnDefinition struct {
+	name       string
+	typeName   string
+	primaryKey bool
+}
+
+func (c columnDefinition) String() string {
+	definition := c.name + " " + c.typeName
+	if c.primaryKey {
+		definition = definition + " PRIMARY KEY"
+	}
+	return definition
+}
+
+// CreateTableStatement returns the CREATE TABLE statement of the table
+// without the semicolon, the id column is the primary key.
+func CreateTableStatement(relation core.Relation) string {
+	return "CREATE TABLE " + core.TABLE_NAME + " (" + columnsString(tableColumns(relation)) + ")"
+}
+
+func tableColumns(relation core.Relation) []columnDefinition {
+	schema := relation.Schema()
+	columns := []columnDefinition{}
+	for _, name := range relation.Columns() {
+		columns = append(columns, columnDefinition{name: name, typeName: schema[name], primaryKey: name == "id"})
+	}
+	return columns
+}
+
+func columnsString(columns []columnDefinition) string {
+	definitions := []string{}
+	for _, column := range columns {
+		definitions = append(definitions, column.String())
+	}
+	return strings.Join(definitions, ", ")
+}
+
+// PrepareCreate prepares CREATE TABLE users (column type [PRIMARY KEY], ...).
+func PrepareCreate(text string) (Statement, error) {
+	scanner := newSQLScanner(text)
+	err := scanner.expectKeyword("create")
+	if err != nil {
+		return Statement{}, err
+	}
+	if scanner.acceptKeyword("index") || (scanner.acceptKeyword("unique") && scanner.acceptKeyword("index")) {
+		message := fmt.Sprintf("CREATE INDEX is not supported, %s is only indexed by id", core.TABLE_NAME)
+		return Statement{}, errors.New(message)
+	}
+	err = scanner.expectKeyword("table")
+	if err != nil {
+		return Statement{}, err
+	}
+	err = checkTableName(scanner.word())
+	if err != nil {
+		return Statement{}, err
+	}
+
+	err = scanner.expect("(")
+	if err != nil {
+		return Statement{}, err
+	}
+	columns := []columnDefinition{}
+	for true {
+		column := columnDefinition{name: scanner.word(), typeName: scanner.word()}
+		if column.name == "" || column.typeName == "" {
+			return Statement{}, scanner.syntaxError()
+		}
+		if scanner.acceptKeyword("primary") {
+			err = scanner.expectKeyword("key")
+			if err != nil {
+				return Statement{}, err
+			}
+			column.primaryKey = true
+		}
+		columns = append(columns, column)
+		if scanner.accept(",") == false {
+			break
+		}
+	}
+	err = scanner.expect(")")
+	if err != nil {
+		return Statement{}, err
+	}
+	if scanner.done() == false {
+		return Statement{}, scanner.syntaxError()
+	}
+	return Statement{Type: StatementType_CreateTable, columnsToCreate: columns}, nil
+}
+
+// ExecuteCreateTable checks the columns against the table. A database file
+// always has the users table, creating it only succeeds while it is empty
+// so a dump can be replayed into a new database file.
+func ExecuteCreateTable(s Statement, relation core.Relation) (*Result, error) {
+	columns := tableColumns(relation)
+	matches := len(columns) == len(s.columnsToCreate)
+	for idx := 0; matches && idx < len(columns); idx++ {
+		column := s.columnsToCreate[idx]
+		matches = strings.EqualFold(column.name, columns[idx].name) &&
+			strings.EqualFold(column.typeName, columns[idx].typeName) &&
+			column.primaryKey == columns[idx].primaryKey
+	}
+	if matches == false {
+		message := fmt.Sprintf("table %s has the columns %s", core.TABLE_NAME, columnsString(columns))
+		return nil, errors.New(message)
+	}
+	if relation.NumRows() > 0 {
+		message := fmt.Sprintf("table %s already exists", core.TABLE_NAME)
+		return nil, errors.New(message)
+	}
+	return &Result{Tag: "CREATE TABLE"}, nil
+}
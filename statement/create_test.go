@@ -0,0 +1,28 @@
+package statement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+func TestCreateTable(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	session := NewSession(table)
+
+	result, err := session.Execute(CreateTableStatement(table))
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE TABLE", result.Tag)
+
+	_, err = session.Execute("create table users (id uint32 primary key, username string)")
+	assert.Equal(t, "table users has the columns id uint32 PRIMARY KEY, username string, email string", err.Error())
+	_, err = session.Execute("create index users_email on users (email)")
+	assert.Equal(t, "CREATE INDEX is not supported, users is only indexed by id", err.Error())
+
+	table.InsertRow(core.NewRow(1, "harry", "harry@hogwarts.edu"))
+	_, err = session.Execute("CREATE TABLE users (id uint32 PRIMARY KEY, username string, email string)")
+	assert.Equal(t, "table users already exists", err.Error())
+}
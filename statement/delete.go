@@ -0,0 +1,51 @@
+package statement
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+func PrepareDelete(text string) (Statement, error) {
+	tokens := strings.Split(text, " ")
+	if len(tokens) != 2 {
+		return Statement{}, errors.New("PREPARE_SYNTAX_ERROR")
+	}
+	bindings, numParams, err := bindTokens(tokens)
+	if err != nil {
+		return Statement{}, err
+	}
+	if numParams > 0 {
+		return Statement{
+			Type:      StatementType_Delete,
+			tokens:    tokens,
+			bindings:  bindings,
+			numParams: numParams,
+		}, nil
+	}
+	return extractIdFromTokens(tokens)
+}
+
+func extractIdFromTokens(tokens []string) (Statement, error) {
+	userID, err := strconv.ParseUint(tokens[1], 10, 32)
+	if err != nil {
+		return Statement{}, errors.New("id must be integer")
+	}
+	return Statement{
+		Type:       StatementType_Delete,
+		IdToDelete: uint32(userID),
+	}, nil
+}
+
+func ExecuteDelete(s Statement, relation core.Relation) (*Result, error) {
+	found, err := relation.DeleteRow(s.IdToDelete)
+	if err != nil {
+		return nil, err
+	}
+	if found == false {
+		return &Result{Tag: "DELETE 0"}, nil
+	}
+	return &Result{Tag: "DELETE 1"}, nil
+}
@@ -0,0 +1,27 @@
+package statement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareDelete(t *testing.T) {
+	s, err := PrepareDelete("delete 42")
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatementType_Delete, s.Type)
+	assert.Equal(t, uint32(42), s.IdToDelete)
+}
+
+func TestPrepareDelete_withSyntaxError(t *testing.T) {
+	_, err := PrepareDelete("delete")
+
+	assert.Equal(t, "PREPARE_SYNTAX_ERROR", err.Error())
+}
+
+func TestPrepareDelete_withInvalidDataType(t *testing.T) {
+	_, err := PrepareDelete("delete s")
+
+	assert.Equal(t, "id must be integer", err.Error())
+}
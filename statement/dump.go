@@ -0,0 +1,65 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// An INSERT statement of a dump ends after DUMP_BATCH_ROWS rows or once its
+// values take DUMP_BATCH_BYTES, a statement is replayed in one transaction
+// which has to fit into the buffer pool.
+const DUMP_BATCH_ROWS = 100
+const DUMP_BATCH_BYTES = 64 * 1024
+
+// Dump writes the statements recreating the table, which are replayed into
+// an empty database file. Every row is on its own line so dumps can be
+// diffed, and control characters are written as char(n). The table lock is
+// held until the last row is written, so the dump is a consistent copy and
+// commits wait for it. It returns the number of rows dumped.
+func Dump(table *core.Table, w io.Writer) (int, error) {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "%s;\n", CreateTableStatement(table))
+
+	numRows := 0
+	err := table.ScanLocked(nil, nil, func(rows *core.RowIterator) error {
+		batchRows := 0
+		batchBytes := 0
+		for true {
+			row, err := rows.Next()
+			if err != nil {
+				return err
+			}
+			if row == nil {
+				break
+			}
+
+			values := fmt.Sprintf("(%d, %s, %s)", row.Id(), QuoteString(row.Username()), QuoteString(row.Email()))
+			if batchRows == 0 {
+				fmt.Fprintf(out, "INSERT INTO %s VALUES\n", core.TABLE_NAME)
+			} else {
+				fmt.Fprint(out, ",\n")
+			}
+			fmt.Fprint(out, values)
+			numRows++
+			batchRows++
+			batchBytes += len(values)
+			if batchRows == DUMP_BATCH_ROWS || batchBytes >= DUMP_BATCH_BYTES {
+				fmt.Fprint(out, ";\n")
+				batchRows = 0
+				batchBytes = 0
+			}
+		}
+		if batchRows > 0 {
+			fmt.Fprint(out, ";\n")
+		}
+		return nil
+	})
+	if err != nil {
+		out.Flush()
+		return numRows, err
+	}
+	return numRows, out.Flush()
+}
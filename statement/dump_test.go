@@ -0,0 +1,90 @@
+package statement
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+func TestDump(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	rows := []*core.Row{
+		core.NewRow(1, "harry", "harry@hogwarts.edu"),
+		core.NewRow(2, "o'reilly", "nul\x00in\nthe middle;"),
+	}
+	for i := 3; i <= 250; i++ {
+		email := "user@test.com"
+		if i%100 == 0 {
+			email = strings.Repeat("e", 2*core.DEFAULT_PAGE_SIZE)
+		}
+		rows = append(rows, core.NewRow(uint32(i), "user", email))
+	}
+	table.InsertRows(rows)
+	var out bytes.Buffer
+
+	numRows, err := Dump(table, &out)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 250, numRows)
+	dump := out.String()
+	assert.True(t, strings.HasPrefix(dump, "CREATE TABLE users (id uint32 PRIMARY KEY, username string, email string);\nINSERT INTO users VALUES\n(1, 'harry', 'harry@hogwarts.edu'),\n(2, 'o''reilly', 'nul' || char(0) || 'in' || char(10) || 'the middle;'),\n"))
+	assert.Equal(t, 3, strings.Count(dump, "INSERT INTO"))
+
+	restored, cleanupRestored := openTestTable(t)
+	defer cleanupRestored()
+	session := NewSession(restored)
+	for _, text := range Split(dump) {
+		_, err = session.Execute(text)
+		assert.Nil(t, err)
+	}
+	restoredRows, _ := restored.SeqScan(nil)
+	assert.Equal(t, rows, restoredRows)
+}
+
+// commitOnWrite inserts a row from another goroutine at the first write and
+// gives the insert some time to commit before the dump goes on.
+type commitOnWrite struct {
+	bytes.Buffer
+	table *core.Table
+	done  chan bool
+}
+
+func (w *commitOnWrite) Write(p []byte) (int, error) {
+	if w.done == nil {
+		w.done = make(chan bool)
+		go func() {
+			w.table.InsertRow(core.NewRow(1000, "late", "late@test.com"))
+			close(w.done)
+		}()
+		select {
+		case <-w.done:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestDumpIsConsistent(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	rows := []*core.Row{}
+	for i := 1; i <= 500; i++ {
+		rows = append(rows, core.NewRow(uint32(i), "user", "user@test.com"))
+	}
+	table.InsertRows(rows)
+	out := &commitOnWrite{table: table}
+
+	numRows, err := Dump(table, out)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 500, numRows)
+	assert.False(t, strings.Contains(out.String(), "late"))
+	<-out.done
+	assert.Equal(t, 501, table.NumRows())
+}
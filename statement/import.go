@@ -0,0 +1,383 @@
+package statement
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// The formats Import reads, a CSV file starts with a header line naming the
+// columns while every NDJSON line is an object keyed by the column names.
+const IMPORT_FORMAT_CSV = "csv"
+const IMPORT_FORMAT_NDJSON = "ndjson"
+
+// ImportFormat tells the format of a file by its extension.
+func ImportFormat(fileName string) (string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return IMPORT_FORMAT_CSV, nil
+	case ".ndjson", ".jsonl":
+		return IMPORT_FORMAT_NDJSON, nil
+	default:
+		message := fmt.Sprintf("can not tell the format of %s, it is either csv or ndjson", fileName)
+		return "", errors.New(message)
+	}
+}
+
+// RejectedRow is a row Import skipped, Line is where it starts in the input.
+type RejectedRow struct {
+	Line int
+	Err  error
+}
+
+type ImportResult struct {
+	Imported int
+	// BulkLoaded is the number of imported rows which were loaded bottom-up
+	// into the empty table
+	BulkLoaded int
+	Rejected   []RejectedRow
+}
+
+// importRecord is a row read from the input, err tells why it is rejected.
+type importRecord struct {
+	line int
+	row  *core.Row
+	err  error
+}
+
+// importRecords reads the input, next returns nil at the end of it.
+type importRecords interface {
+	next() (*importRecord, error)
+}
+
+// Import reads the rows of r into the table. Rows with invalid values or an
+// id which already exists are rejected and reported with their line number.
+// An empty table is bulk loaded for as long as the ids are sorted, the other
+// rows are inserted by transactions committed whenever they fill half of
+// the buffer pool. The rows committed before an error stay in the table and
+// are counted by the result returned along with the error.
+func Import(table *core.Table, r io.Reader, format string) (*ImportResult, error) {
+	var records importRecords
+	var err error
+	switch format {
+	case IMPORT_FORMAT_CSV:
+		records, err = newCSVRecords(r, table.Columns())
+	case IMPORT_FORMAT_NDJSON:
+		records = newNDJSONRecords(r, table.Columns(), table.Schema())
+	default:
+		message := fmt.Sprintf("unknown import format %s", format)
+		err = errors.New(message)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	im := &importer{
+		records: records,
+		result:  &ImportResult{Rejected: []RejectedRow{}},
+	}
+	numRows, err := table.BulkLoad(im.nextSorted)
+	if err != nil && err != core.ErrTableNotEmpty {
+		return im.result, err
+	}
+	im.result.Imported = numRows
+	im.result.BulkLoaded = numRows
+	return im.result, im.insert(table)
+}
+
+type importer struct {
+	records importRecords
+	result  *ImportResult
+	// pending is the first row out of order, bulk loading stops there
+	pending *importRecord
+	lastID  uint32
+}
+
+func (im *importer) reject(line int, err error) {
+	im.result.Rejected = append(im.result.Rejected, RejectedRow{Line: line, Err: err})
+}
+
+// next returns the next valid row, nil at the end of input.
+func (im *importer) next() (*importRecord, error) {
+	if im.pending != nil {
+		record := im.pending
+		im.pending = nil
+		return record, nil
+	}
+	for true {
+		record, err := im.records.next()
+		if err != nil || record == nil {
+			return nil, err
+		}
+		if record.err == nil {
+			return record, nil
+		}
+		im.reject(record.line, record.err)
+	}
+	return nil, nil
+}
+
+// nextSorted feeds the bulk load, it ends at the first row whose id is not
+// greater than the previous one.
+func (im *importer) nextSorted() (*core.Row, error) {
+	record, err := im.next()
+	if err != nil || record == nil {
+		return nil, err
+	}
+	if im.lastID != 0 && record.row.Id() <= im.lastID {
+		im.pending = record
+		return nil, nil
+	}
+	im.lastID = record.row.Id()
+	return record.row, nil
+}
+
+// insert inserts the remaining rows in batches, a transaction is begun for
+// the first row of a batch.
+func (im *importer) insert(table *core.Table) error {
+	var tx *core.TableTransaction
+	numRows := 0
+	for true {
+		record, err := im.next()
+		if err != nil {
+			if tx != nil {
+				tx.Rollback()
+			}
+			return err
+		}
+		if record == nil {
+			break
+		}
+		if tx == nil {
+			tx = table.Begin()
+		}
+
+		condition := &core.IndexCondition{ColumnName: "id", Target: record.row.Id(), Operator: "="}
+		rows, err := tx.IndexScan(condition, nil)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if len(rows) > 0 {
+			message := fmt.Sprintf("id %d already exists", record.row.Id())
+			im.reject(record.line, errors.New(message))
+			continue
+		}
+		err = tx.InsertRow(record.row)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		numRows++
+
+		if tx.Full() {
+			err = tx.Commit()
+			tx = nil
+			if err != nil {
+				return err
+			}
+			im.result.Imported += numRows
+			numRows = 0
+		}
+	}
+	if tx == nil {
+		return nil
+	}
+	err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	im.result.Imported += numRows
+	return nil
+}
+
+// newImportRecord validates the values, which are in the order of the
+// columns of the table.
+func newImportRecord(line int, values []string) *importRecord {
+	row, err := newUserRow(values[0], values[1], values[2])
+	return &importRecord{line: line, row: row, err: err}
+}
+
+type csvRecords struct {
+	reader *csv.Reader
+	// positions are the positions of the table columns in a record
+	positions  []int
+	numColumns int
+}
+
+func newCSVRecords(r io.Reader, columns []string) (*csvRecords, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the header line naming the columns is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(header))
+	for idx, name := range header {
+		names[idx] = strings.TrimSpace(name)
+	}
+	positions, err := columnPositions(columns, names)
+	if err != nil {
+		return nil, err
+	}
+	return &csvRecords{reader: reader, positions: positions, numColumns: len(header)}, nil
+}
+
+// columnPositions returns the positions of the columns of the table among
+// names, which has to name each of them once.
+func columnPositions(columns []string, names []string) ([]int, error) {
+	positions := make([]int, len(columns))
+	for idx := range positions {
+		positions[idx] = -1
+	}
+	for position, name := range names {
+		idx := columnIndex(columns, name)
+		if idx == -1 {
+			message := fmt.Sprintf("column %s does not exist", name)
+			return nil, errors.New(message)
+		}
+		if positions[idx] != -1 {
+			message := fmt.Sprintf("column %s appears twice", name)
+			return nil, errors.New(message)
+		}
+		positions[idx] = position
+	}
+	for idx, position := range positions {
+		if position == -1 {
+			message := fmt.Sprintf("column %s is missing", columns[idx])
+			return nil, errors.New(message)
+		}
+	}
+	return positions, nil
+}
+
+// columnIndex finds the column by name ignoring case, -1 means there is no
+// such column.
+func columnIndex(columns []string, name string) int {
+	for idx, column := range columns {
+		if strings.EqualFold(column, name) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func (c *csvRecords) next() (*importRecord, error) {
+	fields, err := c.reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		return &importRecord{line: parseErr.StartLine, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+	if len(fields) != c.numColumns {
+		message := fmt.Sprintf("expected %d values, got %d", c.numColumns, len(fields))
+		return &importRecord{line: line, err: errors.New(message)}, nil
+	}
+	values := make([]string, len(c.positions))
+	for idx, position := range c.positions {
+		values[idx] = fields[position]
+	}
+	return newImportRecord(line, values), nil
+}
+
+type ndjsonRecords struct {
+	reader  *bufio.Reader
+	columns []string
+	schema  map[string]string
+	line    int
+}
+
+func newNDJSONRecords(r io.Reader, columns []string, schema map[string]string) *ndjsonRecords {
+	return &ndjsonRecords{
+		reader:  bufio.NewReader(r),
+		columns: columns,
+		schema:  schema,
+	}
+}
+
+// next skips empty lines, a line is not limited in length.
+func (n *ndjsonRecords) next() (*importRecord, error) {
+	for true {
+		text, err := n.reader.ReadString('\n')
+		if err == io.EOF && text == "" {
+			return nil, nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		n.line++
+		text = strings.TrimSpace(text)
+		if text != "" {
+			values, err := n.parse(text)
+			if err != nil {
+				return &importRecord{line: n.line, err: err}, nil
+			}
+			return newImportRecord(n.line, values), nil
+		}
+	}
+	return nil, nil
+}
+
+// parse returns the values of the object in column order, a uint32 column
+// takes a number and a string column a string.
+func (n *ndjsonRecords) parse(text string) ([]string, error) {
+	object := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	err := decoder.Decode(&object)
+	if err != nil {
+		message := fmt.Sprintf("invalid JSON object: %s", err)
+		return nil, errors.New(message)
+	}
+	for name := range object {
+		if _, ok := n.schema[name]; ok == false {
+			message := fmt.Sprintf("column %s does not exist", name)
+			return nil, errors.New(message)
+		}
+	}
+
+	values := make([]string, len(n.columns))
+	for idx, column := range n.columns {
+		value, ok := object[column]
+		if ok == false {
+			message := fmt.Sprintf("column %s is missing", column)
+			return nil, errors.New(message)
+		}
+		switch v := value.(type) {
+		case json.Number:
+			if n.schema[column] == "uint32" {
+				values[idx] = v.String()
+				continue
+			}
+		case string:
+			if n.schema[column] == "string" {
+				values[idx] = v
+				continue
+			}
+		}
+		if n.schema[column] == "uint32" {
+			message := fmt.Sprintf("%s must be integer", column)
+			return nil, errors.New(message)
+		}
+		message := fmt.Sprintf("%s must be string", column)
+		return nil, errors.New(message)
+	}
+	return values, nil
+}
@@ -0,0 +1,126 @@
+package statement
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+func rejectedLines(result *ImportResult) []string {
+	lines := []string{}
+	for _, rejected := range result.Rejected {
+		lines = append(lines, fmt.Sprintf("%d: %s", rejected.Line, rejected.Err))
+	}
+	return lines
+}
+
+func TestImportCSV(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	input := "email,id,username\n" +
+		"harry@hogwarts.edu,1,harry\n" +
+		"\"ron@hogwarts.edu, \"\"the\"\" ron\",2,ron\n" +
+		"x,three,neville\n" +
+		"hermione@hogwarts.edu,4,hermione\n" +
+		"only,two\n" +
+		"ginny@hogwarts.edu,3,ginny\n" +
+		"fred@hogwarts.edu,4,fred\n" +
+		"x,0,neville\n"
+
+	result, err := Import(table, strings.NewReader(input), IMPORT_FORMAT_CSV)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, result.Imported)
+	assert.Equal(t, 3, result.BulkLoaded)
+	assert.Equal(t, []string{
+		"4: id must be integer",
+		"6: expected 3 values, got 2",
+		"8: id 4 already exists",
+		"9: id must be positive",
+	}, rejectedLines(result))
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 4, len(rows))
+	assert.Equal(t, "ron@hogwarts.edu, \"the\" ron", rows[1].Email())
+	assert.Equal(t, "ginny", rows[2].Username())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestImportCSVWithInvalidHeader(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+
+	_, err := Import(table, strings.NewReader("id,username\n"), IMPORT_FORMAT_CSV)
+	assert.Equal(t, "column email is missing", err.Error())
+	_, err = Import(table, strings.NewReader("id,name,email\n"), IMPORT_FORMAT_CSV)
+	assert.Equal(t, "column name does not exist", err.Error())
+	_, err = Import(table, strings.NewReader(""), IMPORT_FORMAT_CSV)
+	assert.Equal(t, "the header line naming the columns is missing", err.Error())
+}
+
+func TestImportNDJSON(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	table.InsertRow(core.NewRow(2, "george", "george@hogwarts.edu"))
+	input := `{"id": 1, "username": "harry", "email": "harry@hogwarts.edu"}
+
+{"id": "3", "username": "ron", "email": "ron@hogwarts.edu"}
+{"id": 4, "username": 4, "email": "hermione@hogwarts.edu"}
+{"id": 5, "username": "ginny"}
+{"id": 6, "username": "fred", "email": "fred@hogwarts.edu", "age": 20}
+{"id": 2, "username": "george", "email": "george@hogwarts.edu"}
+not json
+`
+
+	result, err := Import(table, strings.NewReader(input), IMPORT_FORMAT_NDJSON)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 0, result.BulkLoaded)
+	assert.Equal(t, []string{
+		"3: id must be integer",
+		"4: username must be string",
+		"5: column email is missing",
+		"6: column age does not exist",
+		"7: id 2 already exists",
+		"8: invalid JSON object: invalid character 'o' in literal null (expecting 'u')",
+	}, rejectedLines(result))
+	assert.Equal(t, 2, table.NumRows())
+}
+
+func TestImportManyRows(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	lines := []string{"id,username,email"}
+	for i := 1; i <= 3000; i++ {
+		id := i
+		// the second half counts down from 3000, bulk loading stops at 2999
+		// and the remaining rows are inserted
+		if i > 1500 {
+			id = 4501 - i
+		}
+		lines = append(lines, fmt.Sprintf("%d,user%d,user%d@test.com", id, id, id))
+	}
+
+	result, err := Import(table, strings.NewReader(strings.Join(lines, "\n")), IMPORT_FORMAT_CSV)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3000, result.Imported)
+	assert.Equal(t, 1501, result.BulkLoaded)
+	assert.Equal(t, 3000, table.NumRows())
+	problems, _ := table.CheckIntegrity()
+	assert.Equal(t, []string{}, problems)
+}
+
+func TestImportFormat(t *testing.T) {
+	format, _ := ImportFormat("users.CSV")
+	assert.Equal(t, IMPORT_FORMAT_CSV, format)
+	format, _ = ImportFormat("data/users.jsonl")
+	assert.Equal(t, IMPORT_FORMAT_NDJSON, format)
+	_, err := ImportFormat("users.txt")
+	assert.Equal(t, "can not tell the format of users.txt, it is either csv or ndjson", err.Error())
+}
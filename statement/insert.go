@@ -2,6 +2,7 @@ package statement
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -13,44 +14,201 @@ func extractUserFromTokens(tokens []string) (Statement, error) {
 	usernameIdx := 2
 	emailIdx := 3
 
-	userID, err := strconv.ParseUint(tokens[idIdx], 10, 32)
+	row, err := newUserRow(tokens[idIdx], tokens[usernameIdx], tokens[emailIdx])
 	if err != nil {
-		return Statement{}, errors.New("id must be integer")
+		return Statement{}, err
 	}
-	if userID < 1 {
-		return Statement{}, errors.New("id must be positive")
-	}
-
-	username := tokens[usernameIdx]
-	if len(username) > core.COLUMN_USERNAME_LENGTH {
-		return Statement{}, errors.New("username too long")
-	}
-
-	email := tokens[emailIdx]
-	if len(email) > core.COLUMN_EMAIL_LENGTH {
-		return Statement{}, errors.New("email too long")
-	}
-
-	row := core.NewRow(uint32(userID), username, email)
 	return Statement{
 		Type:        StatementType_Insert,
 		RowToInsert: row,
 	}, nil
 }
 
+// newUserRow validates the values of a row, a long username or email is
+// stored in overflow pages so neither is limited.
+func newUserRow(id string, username string, email string) (*core.Row, error) {
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, errors.New("id must be integer")
+	}
+	if userID < 1 {
+		return nil, errors.New("id must be positive")
+	}
+
+	return core.NewRow(uint32(userID), username, email), nil
+}
+
+// PrepareInsert prepares either insert ID USERNAME EMAIL or INSERT INTO
+// users [(columns)] VALUES (...), ... with SQL literals, the values of both
+// can be placeholders.
 func PrepareInsert(text string) (Statement, error) {
+	fields := strings.Fields(text)
+	if len(fields) > 1 && strings.EqualFold(fields[1], "into") {
+		return prepareInsertInto(text)
+	}
 	tokens := strings.Split(text, " ")
 	if len(tokens) != 4 {
 		return Statement{}, errors.New("PREPARE_SYNTAX_ERROR")
 	}
+	bindings, numParams, err := bindTokens(tokens)
+	if err != nil {
+		return Statement{}, err
+	}
+	if numParams > 0 {
+		return Statement{
+			Type:      StatementType_Insert,
+			tokens:    tokens,
+			bindings:  bindings,
+			numParams: numParams,
+		}, nil
+	}
 	return extractUserFromTokens(tokens)
 }
 
-func ExecuteInsert(s Statement, table *core.Table) ExecuteResult {
-	if table.NumRows() >= core.TABLE_MAX_ROWS {
-		return ExecuteResult_TableFull
+// prepareInsertInto reads the rows of an INSERT INTO statement, the column
+// list names every column in any order.
+func prepareInsertInto(text string) (Statement, error) {
+	scanner := newSQLScanner(text)
+	err := scanner.expectKeyword("insert")
+	if err == nil {
+		err = scanner.expectKeyword("into")
+	}
+	if err != nil {
+		return Statement{}, err
+	}
+	err = checkTableName(scanner.word())
+	if err != nil {
+		return Statement{}, err
 	}
 
-	table.InsertRow(s.RowToInsert)
-	return ExecuteResult_Success
+	columns := USER_COLUMNS
+	if scanner.peek("(") {
+		columns, err = scanner.names()
+		if err != nil {
+			return Statement{}, err
+		}
+	}
+	positions, err := columnPositions(USER_COLUMNS, columns)
+	if err != nil {
+		return Statement{}, err
+	}
+	err = scanner.expectKeyword("values")
+	if err != nil {
+		return Statement{}, err
+	}
+
+	p := &placeholders{}
+	tokens := []string{}
+	bindings := []binding{}
+	for true {
+		row, rowBindings, err := scanRow(scanner, columns, positions, p, len(tokens))
+		if err != nil {
+			return Statement{}, err
+		}
+		tokens = append(tokens, row...)
+		bindings = append(bindings, rowBindings...)
+		if scanner.accept(",") == false {
+			break
+		}
+	}
+	if scanner.done() == false {
+		return Statement{}, scanner.syntaxError()
+	}
+	if p.count > 0 {
+		return Statement{
+			Type:       StatementType_Insert,
+			tokens:     tokens,
+			bindings:   bindings,
+			numParams:  p.count,
+			insertInto: true,
+		}, nil
+	}
+	return rowsFromTokens(tokens)
+}
+
+// rowsFromTokens returns an INSERT INTO statement for the values of its rows
+// in the order of USER_COLUMNS.
+func rowsFromTokens(tokens []string) (Statement, error) {
+	rows := []*core.Row{}
+	for i := 0; i < len(tokens); i += len(USER_COLUMNS) {
+		row, err := newUserRow(tokens[i], tokens[i+1], tokens[i+2])
+		if err != nil {
+			return Statement{}, err
+		}
+		rows = append(rows, row)
+	}
+	return Statement{
+		Type:         StatementType_Insert,
+		RowsToInsert: rows,
+	}, nil
+}
+
+// USER_COLUMNS are the columns of the users table in the order of a row,
+// the names are lower case.
+var USER_COLUMNS = []string{"id", "username", "email"}
+
+// scanRow reads the values of a row in parentheses and returns them in the
+// order of USER_COLUMNS, the id is an integer and the other columns are
+// strings. A placeholder is bound to the position of its value, offset is
+// the position of the first value of the row.
+func scanRow(scanner *sqlScanner, columns []string, positions []int, p *placeholders, offset int) ([]string, []binding, error) {
+	err := scanner.expect("(")
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make([]*sqlValue, len(columns))
+	for position := range columns {
+		if position > 0 {
+			err = scanner.expect(",")
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		token := scanner.placeholder()
+		if token != "" {
+			arg, _, err := p.index(token)
+			if err != nil {
+				return nil, nil, err
+			}
+			values[position] = &sqlValue{text: token, isPlaceholder: true, arg: arg}
+			continue
+		}
+		values[position], err = scanner.value()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	err = scanner.expect(")")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	texts := make([]string, len(positions))
+	bindings := []binding{}
+	for idx, position := range positions {
+		value := values[position]
+		if value.isPlaceholder {
+			bindings = append(bindings, binding{position: offset + idx, arg: value.arg})
+		} else if idx == 0 && value.isString {
+			return nil, nil, errors.New("id must be integer")
+		} else if idx > 0 && value.isString == false {
+			message := fmt.Sprintf("%s must be string", USER_COLUMNS[idx])
+			return nil, nil, errors.New(message)
+		}
+		texts[idx] = value.text
+	}
+	return texts, bindings, nil
+}
+
+// ExecuteInsert inserts the rows of the statement, all of them or none.
+func ExecuteInsert(s Statement, relation core.Relation) (*Result, error) {
+	rows := s.RowsToInsert
+	if rows == nil {
+		rows = []*core.Row{s.RowToInsert}
+	}
+	err := relation.InsertRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Tag: fmt.Sprintf("INSERT 0 %d", len(rows))}, nil
 }
@@ -2,6 +2,7 @@ package statement
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -44,16 +45,24 @@ func TestPrepareInsert_withInvalidDataType(t *testing.T) {
 	assert.Equal(t, "id must be integer", err.Error())
 }
 
-func TestPrepareInsert_withInvalidLength(t *testing.T) {
-	username := ""
-	for i := 0; i < core.COLUMN_USERNAME_LENGTH+1; i++ {
-		username = username + "a"
-	}
+func TestPrepareInsert_withLongUsername(t *testing.T) {
+	username := strings.Repeat("a", core.COLUMN_USERNAME_LENGTH+1)
 	text := fmt.Sprintf("insert 1 %s foo@bar.com", username)
 
-	_, err := PrepareInsert(text)
+	s, err := PrepareInsert(text)
+
+	assert.Nil(t, err)
+	assert.Equal(t, username, s.RowToInsert.Username())
+}
 
-	assert.Equal(t, "username too long", err.Error())
+func TestPrepareInsert_withLongEmail(t *testing.T) {
+	email := strings.Repeat("a", core.COLUMN_EMAIL_LENGTH+1) + "@bar.com"
+	text := fmt.Sprintf("insert 1 cstack %s", email)
+
+	s, err := PrepareInsert(text)
+
+	assert.Nil(t, err)
+	assert.Equal(t, email, s.RowToInsert.Email())
 }
 
 // func TestExecuteInsert(t *testing.T) {
@@ -73,3 +82,40 @@ func TestPrepareInsert_withInvalidLength(t *testing.T) {
 // 	assert.Equal(t, "harry", row.Username())
 // 	assert.Equal(t, "harry@hogwarts.edu", row.Email())
 // }
+
+func TestPrepareInsertInto(t *testing.T) {
+	text := "INSERT INTO users (email, id, username) VALUES\n('harry@hogwarts.edu', 1, 'harry'),\n('it''s' || char(0, 10) || ';', 2, '')"
+
+	s, err := PrepareInsert(text)
+
+	assert.Nil(t, err)
+	assert.Equal(t, StatementType_Insert, s.Type)
+	assert.Equal(t, []*core.Row{
+		core.NewRow(1, "harry", "harry@hogwarts.edu"),
+		core.NewRow(2, "", "it's\x00\n;"),
+	}, s.RowsToInsert)
+
+	_, err = PrepareInsert("insert into users values (1, 'harry')")
+	assert.Equal(t, "PREPARE_SYNTAX_ERROR", err.Error())
+	_, err = PrepareInsert("insert into users values ('1', 'harry', 'harry@hogwarts.edu')")
+	assert.Equal(t, "id must be integer", err.Error())
+	_, err = PrepareInsert("insert into users (id, username) values (1, 'harry')")
+	assert.Equal(t, "column email is missing", err.Error())
+	_, err = PrepareInsert("insert into pets values (1, 'harry', 'harry@hogwarts.edu')")
+	assert.Equal(t, "no such table: pets", err.Error())
+	_, err = PrepareInsert("insert into users values (1, 'harry', 'harry@hogwarts.edu)")
+	assert.Equal(t, "unterminated string literal", err.Error())
+}
+
+func TestQuoteString(t *testing.T) {
+	texts := []string{"", "harry", "it's", "''", "\x00", "a\x00b", "\r\n\t", "日本\x7f", strings.Repeat("'\x01", 10)}
+	for _, text := range texts {
+		quoted := QuoteString(text)
+		assert.Equal(t, false, strings.ContainsAny(quoted, "\x00\n\r"))
+
+		s, err := PrepareInsert(fmt.Sprintf("insert into users values (1, 'harry', %s)", quoted))
+		assert.Nil(t, err)
+		assert.Equal(t, text, s.RowsToInsert[0].Email())
+	}
+	assert.Equal(t, "'it''s' || char(0, 10) || 'ok'", QuoteString("it's\x00\nok"))
+}
@@ -0,0 +1,263 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// QuoteString writes text as a SQL string literal, quotes are doubled and
+// control characters are written as char(n) so the literal stays on one
+// printable line, e.g. a NUL followed by a newline is written as
+// char(0, 10) and joined to the quoted parts by ||.
+func QuoteString(text string) string {
+	parts := []string{}
+	quoted := []byte{}
+	codes := []string{}
+	flush := func() {
+		if len(quoted) > 0 {
+			parts = append(parts, "'"+strings.Replace(string(quoted), "'", "''", -1)+"'")
+			quoted = []byte{}
+		}
+		if len(codes) > 0 {
+			parts = append(parts, "char("+strings.Join(codes, ", ")+")")
+			codes = []string{}
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c < 0x20 || c == 0x7f {
+			if len(quoted) > 0 {
+				flush()
+			}
+			codes = append(codes, strconv.Itoa(int(c)))
+			continue
+		}
+		if len(codes) > 0 {
+			flush()
+		}
+		quoted = append(quoted, c)
+	}
+	flush()
+	if len(parts) == 0 {
+		return "''"
+	}
+	return strings.Join(parts, " || ")
+}
+
+// sqlScanner reads the words, numbers, string literals and punctuation of a
+// statement.
+type sqlScanner struct {
+	text string
+	pos  int
+}
+
+func newSQLScanner(text string) *sqlScanner {
+	return &sqlScanner{text: text}
+}
+
+func (s *sqlScanner) skipSpace() {
+	for s.pos < len(s.text) && isSpace(s.text[s.pos]) {
+		s.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// done reports whether the whole text is read.
+func (s *sqlScanner) done() bool {
+	s.skipSpace()
+	return s.pos == len(s.text)
+}
+
+// peek reports whether the next token is symbol, without reading it.
+func (s *sqlScanner) peek(symbol string) bool {
+	s.skipSpace()
+	return strings.HasPrefix(s.text[s.pos:], symbol)
+}
+
+// accept reads symbol when it comes next.
+func (s *sqlScanner) accept(symbol string) bool {
+	if s.peek(symbol) == false {
+		return false
+	}
+	s.pos += len(symbol)
+	return true
+}
+
+func (s *sqlScanner) expect(symbol string) error {
+	if s.accept(symbol) == false {
+		return s.syntaxError()
+	}
+	return nil
+}
+
+// word reads a keyword or a name, it returns "" when none comes next.
+func (s *sqlScanner) word() string {
+	s.skipSpace()
+	start := s.pos
+	for s.pos < len(s.text) && isWordByte(s.text[s.pos]) {
+		s.pos++
+	}
+	return s.text[start:s.pos]
+}
+
+// acceptKeyword reads the keyword, ignoring case, when it comes next.
+func (s *sqlScanner) acceptKeyword(keyword string) bool {
+	pos := s.pos
+	if strings.EqualFold(s.word(), keyword) {
+		return true
+	}
+	s.pos = pos
+	return false
+}
+
+func (s *sqlScanner) expectKeyword(keyword string) error {
+	if s.acceptKeyword(keyword) == false {
+		return s.syntaxError()
+	}
+	return nil
+}
+
+func (s *sqlScanner) syntaxError() error {
+	return errors.New("PREPARE_SYNTAX_ERROR")
+}
+
+// sqlValue is a literal, either an integer or a string, or a placeholder
+// for the argument at index arg.
+type sqlValue struct {
+	text          string
+	isString      bool
+	isPlaceholder bool
+	arg           int
+}
+
+// placeholder reads a ? or $n placeholder, it returns "" when none comes
+// next.
+func (s *sqlScanner) placeholder() string {
+	s.skipSpace()
+	if s.accept("?") {
+		return "?"
+	}
+	if s.peek("$") == false {
+		return ""
+	}
+	end := s.pos + 1
+	for end < len(s.text) && s.text[end] >= '0' && s.text[end] <= '9' {
+		end++
+	}
+	if end == s.pos+1 {
+		return ""
+	}
+	token := s.text[s.pos:end]
+	s.pos = end
+	return token
+}
+
+// value reads an integer, or a string made of quoted literals and char(n)
+// joined by ||.
+func (s *sqlScanner) value() (*sqlValue, error) {
+	s.skipSpace()
+	if s.pos < len(s.text) && s.text[s.pos] >= '0' && s.text[s.pos] <= '9' {
+		return &sqlValue{text: s.word()}, nil
+	}
+
+	var b strings.Builder
+	for true {
+		err := s.stringTerm(&b)
+		if err != nil {
+			return nil, err
+		}
+		if s.accept("||") == false {
+			break
+		}
+	}
+	return &sqlValue{text: b.String(), isString: true}, nil
+}
+
+func (s *sqlScanner) stringTerm(b *strings.Builder) error {
+	if s.acceptKeyword("char") {
+		return s.charCodes(b)
+	}
+	if s.accept("'") == false {
+		return s.syntaxError()
+	}
+	for true {
+		end := strings.IndexByte(s.text[s.pos:], '\'')
+		if end == -1 {
+			return errors.New("unterminated string literal")
+		}
+		b.WriteString(s.text[s.pos : s.pos+end])
+		s.pos += end + 1
+		// a doubled quote is a quote inside the literal
+		if s.pos < len(s.text) && s.text[s.pos] == '\'' {
+			b.WriteByte('\'')
+			s.pos++
+			continue
+		}
+		return nil
+	}
+	return nil
+}
+
+// charCodes reads the arguments of char(n, ...), the characters with the
+// code points n.
+func (s *sqlScanner) charCodes(b *strings.Builder) error {
+	err := s.expect("(")
+	if err != nil {
+		return err
+	}
+	for true {
+		code, err := strconv.ParseUint(s.word(), 10, 32)
+		if err != nil || code > unicode.MaxRune {
+			return s.syntaxError()
+		}
+		b.WriteRune(rune(code))
+		if s.accept(",") == false {
+			break
+		}
+	}
+	return s.expect(")")
+}
+
+// names reads a list of names in parentheses.
+func (s *sqlScanner) names() ([]string, error) {
+	err := s.expect("(")
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for true {
+		name := s.word()
+		if name == "" {
+			return nil, s.syntaxError()
+		}
+		names = append(names, name)
+		if s.accept(",") == false {
+			break
+		}
+	}
+	return names, s.expect(")")
+}
+
+// checkTableName fails unless name is the table of the database file.
+func checkTableName(name string) error {
+	if name == "" {
+		return errors.New("PREPARE_SYNTAX_ERROR")
+	}
+	if strings.EqualFold(name, core.TABLE_NAME) == false {
+		message := fmt.Sprintf("no such table: %s", name)
+		return errors.New(message)
+	}
+	return nil
+}
@@ -12,10 +12,20 @@ func PrepareSelect(text string) (Statement, error) {
 	if err != nil {
 		return Statement{}, err
 	}
-	return Statement{
+	s := Statement{
 		Type:      StatementType_Select,
 		QueryPlan: plan,
-	}, nil
+	}
+	if plan.From.Where != nil && plan.From.Where.Condition.Value.Placeholder != nil {
+		p := &placeholders{}
+		arg, _, err := p.index(*plan.From.Where.Condition.Value.Placeholder)
+		if err != nil {
+			return Statement{}, err
+		}
+		s.bindings = []binding{{arg: arg}}
+		s.numParams = p.count
+	}
+	return s, nil
 }
 
 type ScanMethodType int
@@ -31,14 +41,14 @@ type QueryPlan struct {
 	IndexCondition *core.IndexCondition
 }
 
-func OptimizeQueryPlan(s Statement, table *core.Table) (*QueryPlan, error) {
+func OptimizeQueryPlan(s Statement, relation core.Relation) (*QueryPlan, error) {
 	whereExpression := s.QueryPlan.From.Where
 	if whereExpression == nil {
 		return &QueryPlan{
 			ScanMethod: ScanMethodType_SeqScan,
 		}, nil
 	} else {
-		filter, err := core.NewFilter(whereExpression, table.Schema())
+		filter, err := core.NewFilter(whereExpression, relation.Schema())
 		if err != nil {
 			return nil, err
 		}
@@ -63,33 +73,64 @@ func OptimizeQueryPlan(s Statement, table *core.Table) (*QueryPlan, error) {
 	}
 }
 
-func ExecuteSelect(s Statement, table *core.Table) ExecuteResult {
+// SeqScanQueryPlan reads every row through the filter of the where clause,
+// it returns the rows of the plan chosen by OptimizeQueryPlan in the same
+// order. Logic tests check index scans against it.
+func SeqScanQueryPlan(s Statement, relation core.Relation) (*QueryPlan, error) {
+	plan := &QueryPlan{ScanMethod: ScanMethodType_SeqScan}
+	if s.QueryPlan.From.Where == nil {
+		return plan, nil
+	}
+	filter, err := core.NewFilter(s.QueryPlan.From.Where, relation.Schema())
+	if err != nil {
+		return nil, err
+	}
+	plan.Filter = filter
+	return plan, nil
+}
+
+func ExecuteSelect(s Statement, relation core.Relation) (*Result, error) {
 	var rows []*core.Row
 	var err error
 
-	queryPlan, err := OptimizeQueryPlan(s, table)
+	queryPlan, err := OptimizeQueryPlan(s, relation)
 	if err != nil {
-		return ExecuteResult_Failure
+		return nil, err
 	}
 
 	if queryPlan.ScanMethod == ScanMethodType_IndexScan {
-		rows, err = table.IndexScan(queryPlan.IndexCondition, queryPlan.Filter)
+		rows, err = relation.IndexScan(queryPlan.IndexCondition, queryPlan.Filter)
 		if err != nil {
-			return ExecuteResult_Failure
+			return nil, err
 		}
 	} else {
-		rows, err = table.SeqScan(queryPlan.Filter)
+		rows, err = relation.SeqScan(queryPlan.Filter)
 		if err != nil {
-			return ExecuteResult_Failure
+			return nil, err
 		}
 	}
 
-	for _, row := range rows {
-		fmt.Println(row)
+	return &Result{
+		Tag:     fmt.Sprintf("SELECT %d", len(rows)),
+		Columns: relation.Columns(),
+		Rows:    rows,
+	}, nil
+	// select necessary attributes
+}
+
+// OpenSelect plans a select and returns an iterator over its rows, the rows
+// are read while iterating.
+func OpenSelect(s Statement, relation core.Relation) (*core.RowIterator, error) {
+	err := checkBound(s)
+	if err != nil {
+		return nil, err
 	}
 
-	return ExecuteResult_Success
-	// select necessary attributes
+	queryPlan, err := OptimizeQueryPlan(s, relation)
+	if err != nil {
+		return nil, err
+	}
+	return relation.Scan(queryPlan.IndexCondition, queryPlan.Filter), nil
 }
 
 // func ExecuteSelect(s Statement, table *core.Table) ExecuteResult {
@@ -0,0 +1,102 @@
+package statement
+
+import (
+	"errors"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+// Session executes statements for one client, it keeps the transaction
+// opened by BEGIN until COMMIT or ROLLBACK. Statements outside of a
+// transaction are committed one by one.
+type Session struct {
+	table *core.Table
+	tx    *core.TableTransaction
+}
+
+func NewSession(table *core.Table) *Session {
+	return &Session{
+		table: table,
+	}
+}
+
+// InTransaction reports whether the session has an open transaction.
+func (s *Session) InTransaction() bool {
+	return s.tx != nil
+}
+
+// Relation is what the statements of the session read, the open
+// transaction or else the table.
+func (s *Session) Relation() core.Relation {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.table
+}
+
+func (s *Session) Execute(text string) (*Result, error) {
+	st, err := Prepare(text)
+	if err != nil {
+		return nil, err
+	}
+	return s.ExecuteStatement(st)
+}
+
+// ExecuteStatement executes a prepared statement, a statement with
+// placeholders has to be bound first.
+func (s *Session) ExecuteStatement(st Statement) (*Result, error) {
+	switch st.Type {
+	case StatementType_Begin:
+		if s.tx != nil {
+			return nil, errors.New("there is already a transaction in progress")
+		}
+		s.tx = s.table.Begin()
+		return &Result{Tag: "BEGIN"}, nil
+	case StatementType_Commit:
+		if s.tx == nil {
+			return nil, errors.New("there is no transaction in progress")
+		}
+		tx := s.tx
+		s.tx = nil
+		err := tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Tag: "COMMIT"}, nil
+	case StatementType_Rollback:
+		if s.tx == nil {
+			return nil, errors.New("there is no transaction in progress")
+		}
+		s.tx.Rollback()
+		s.tx = nil
+		return &Result{Tag: "ROLLBACK"}, nil
+	case StatementType_Vacuum:
+		if s.tx != nil {
+			return nil, errors.New("cannot VACUUM from within a transaction")
+		}
+		err := s.table.Vacuum()
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Tag: "VACUUM"}, nil
+	}
+
+	if s.tx == nil {
+		return Execute(st, s.table)
+	}
+	result, err := Execute(st, s.tx)
+	if s.tx.Closed() {
+		// a failed write is undone alone, unless the pages it appended can
+		// not be released and the whole transaction is rolled back
+		s.tx = nil
+	}
+	return result, err
+}
+
+// Close rolls back the open transaction of the session.
+func (s *Session) Close() {
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.tx = nil
+	}
+}
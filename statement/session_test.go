@@ -0,0 +1,134 @@
+package statement
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ocowchun/sqlbit/core"
+)
+
+func openTestTable(t *testing.T) (*core.Table, func()) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	table, err := core.OpenTable(filepath.Join(dir, "test.db"))
+	assert.Nil(t, err)
+	return table, func() {
+		table.CloseTable()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSessionExecute(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	session := NewSession(table)
+
+	result, err := session.Execute("insert 1 harry harry@hogwarts.edu")
+	assert.Nil(t, err)
+	assert.Equal(t, "INSERT 0 1", result.Tag)
+
+	result, err = session.Execute("select * from users")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT 1", result.Tag)
+	assert.Equal(t, "harry", result.Rows[0].Username())
+
+	result, _ = session.Execute("delete 2")
+	assert.Equal(t, "DELETE 0", result.Tag)
+}
+
+func TestSessionTransaction(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	session := NewSession(table)
+
+	session.Execute("BEGIN")
+	session.Execute("insert 1 harry harry@hogwarts.edu")
+	assert.Equal(t, true, session.InTransaction())
+	result, _ := session.Execute("select * from users")
+	assert.Equal(t, 1, len(result.Rows))
+	result, err := session.Execute("rollback")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ROLLBACK", result.Tag)
+	assert.Equal(t, false, session.InTransaction())
+	result, _ = session.Execute("select * from users")
+	assert.Equal(t, 0, len(result.Rows))
+
+	session.Execute("begin")
+	session.Execute("insert 2 ron ron@hogwarts.edu")
+	session.Execute("commit")
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+}
+
+func TestSessionFailedStatementKeepsTransaction(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "sqlbit")
+	defer os.RemoveAll(dir)
+	table, _ := core.OpenTableWithOptions(filepath.Join(dir, "test.db"), &core.TableOptions{
+		PageSize: core.DEFAULT_PAGE_SIZE,
+		MaxSize:  4 * core.DEFAULT_PAGE_SIZE,
+	})
+	defer table.CloseTable()
+	session := NewSession(table)
+	session.Execute("begin")
+	session.Execute("insert 1 harry harry@hogwarts.edu")
+
+	// the second row does not fit, the first one is undone too
+	_, err := session.Execute("insert into users values (2, 'ron', 'ron@hogwarts.edu'), (3, 'ginny', '" + strings.Repeat("e", 10000) + "')")
+
+	assert.Equal(t, "statement is rolled back: database is full", err.Error())
+	assert.Equal(t, true, session.InTransaction())
+	session.Execute("commit")
+	rows, _ := table.SeqScan(nil)
+	assert.Equal(t, 1, len(rows))
+}
+
+func TestSessionTransactionControlErrors(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	session := NewSession(table)
+
+	_, err := session.Execute("commit")
+	assert.Equal(t, "there is no transaction in progress", err.Error())
+
+	session.Execute("begin")
+	_, err = session.Execute("begin")
+	assert.Equal(t, "there is already a transaction in progress", err.Error())
+
+	session.Close()
+	assert.Equal(t, false, session.InTransaction())
+}
+
+func TestSessionVacuum(t *testing.T) {
+	table, cleanup := openTestTable(t)
+	defer cleanup()
+	session := NewSession(table)
+	session.Execute("insert 1 harry harry@hogwarts.edu")
+	session.Execute("insert 2 ron ron@hogwarts.edu")
+	session.Execute("delete 1")
+
+	result, err := session.Execute("VACUUM")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "VACUUM", result.Tag)
+	result, _ = session.Execute("select * from users")
+	assert.Equal(t, 1, len(result.Rows))
+	assert.Equal(t, "ron", result.Rows[0].Username())
+
+	session.Execute("begin")
+	_, err = session.Execute("vacuum")
+	assert.Equal(t, "cannot VACUUM from within a transaction", err.Error())
+	session.Close()
+	_, err = session.Execute("vacuum full")
+	assert.Equal(t, "PREPARE_SYNTAX_ERROR", err.Error())
+}
+
+func TestPrepareUnrecognizedStatement(t *testing.T) {
+	_, err := Prepare("update 1")
+
+	assert.Equal(t, "UNRECOGNIZED_STATEMENT", err.Error())
+}
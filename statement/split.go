@@ -0,0 +1,52 @@
+package statement
+
+import "strings"
+
+// Split splits text into statements at the semicolons outside of quotes,
+// empty statements are dropped.
+func Split(text string) []string {
+	statements := []string{}
+	start := 0
+	scanSemicolons(text, func(i int) {
+		statements = appendStatement(statements, text[start:i])
+		start = i + 1
+	})
+	return appendStatement(statements, text[start:])
+}
+
+// IsComplete reports whether text ends with a semicolon outside of quotes,
+// which ends the last statement of text.
+func IsComplete(text string) bool {
+	text = strings.TrimRight(text, " \t\r\n")
+	complete := false
+	scanSemicolons(text, func(i int) {
+		complete = i == len(text)-1
+	})
+	return complete
+}
+
+// scanSemicolons calls f with the index of every semicolon outside of
+// quotes.
+func scanSemicolons(text string, f func(i int)) {
+	var quote rune
+	for i, c := range text {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			f(i)
+		}
+	}
+}
+
+func appendStatement(statements []string, text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return statements
+	}
+	return append(statements, text)
+}
@@ -0,0 +1,23 @@
+package statement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	assert.Equal(t, []string{}, Split(" ; "))
+	assert.Equal(t, []string{"select * from users"}, Split("select * from users;"))
+	assert.Equal(t,
+		[]string{"begin", "select * from users where email = 'a;b'"},
+		Split("begin; select * from users where email = 'a;b';"))
+}
+
+func TestIsComplete(t *testing.T) {
+	assert.True(t, IsComplete("select * from users; \n"))
+	assert.True(t, IsComplete("begin; commit;"))
+	assert.False(t, IsComplete("select * from users"))
+	assert.False(t, IsComplete("begin; select * from users"))
+	assert.False(t, IsComplete("select * from users where email = 'a;"))
+}
@@ -1,6 +1,10 @@
 package statement
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/ocowchun/sqlbit/core"
 	"github.com/ocowchun/sqlbit/parser"
 )
@@ -11,18 +15,110 @@ const (
 	StatementType_Insert StatementType = iota
 	StatementType_Select
 	StatementType_Delete
+	StatementType_Begin
+	StatementType_Commit
+	StatementType_Rollback
+	StatementType_Vacuum
+	StatementType_CreateTable
 )
 
 type Statement struct {
 	Type        StatementType
 	RowToInsert *core.Row
-	QueryPlan   *parser.Select
+	// RowsToInsert are the rows of an INSERT INTO statement, which might
+	// insert several rows at once
+	RowsToInsert []*core.Row
+	IdToDelete   uint32
+	QueryPlan    *parser.Select
+
+	// a statement with placeholders keeps them until Bind, tokens are
+	// the words of an insert or a delete, or the values of the rows of an
+	// INSERT INTO in the order of USER_COLUMNS when insertInto is set
+	tokens     []string
+	bindings   []binding
+	numParams  int
+	insertInto bool
+
+	columnsToCreate []columnDefinition
 }
 
-type ExecuteResult int
+// Result is the outcome of a statement, Tag is the command tag reported to
+// clients, e.g. "INSERT 0 1" or "SELECT 2". Columns is only set for
+// statements returning rows.
+type Result struct {
+	Tag     string
+	Columns []string
+	Rows    []*core.Row
+}
 
-const (
-	ExecuteResult_Success ExecuteResult = iota
-	ExecuteResult_Failure
-	ExecuteResult_TableFull
-)
+// our SQL Compilier
+func Prepare(text string) (Statement, error) {
+	keyword := ""
+	fields := strings.Fields(text)
+	if len(fields) > 0 {
+		keyword = strings.ToLower(fields[0])
+	}
+	switch keyword {
+	case "insert":
+		return PrepareInsert(text)
+	case "select":
+		return PrepareSelect(text)
+	case "delete":
+		return PrepareDelete(text)
+	case "create":
+		return PrepareCreate(text)
+	case "begin", "commit", "rollback", "vacuum":
+		return prepareTransactionControl(text)
+	default:
+		return Statement{}, errors.New("UNRECOGNIZED_STATEMENT")
+	}
+}
+
+// prepareTransactionControl prepares the statements made of a single
+// keyword, which VACUUM is too.
+func prepareTransactionControl(text string) (Statement, error) {
+	tokens := strings.Fields(strings.ToLower(text))
+	if len(tokens) != 1 {
+		return Statement{}, errors.New("PREPARE_SYNTAX_ERROR")
+	}
+	switch tokens[0] {
+	case "begin":
+		return Statement{Type: StatementType_Begin}, nil
+	case "commit":
+		return Statement{Type: StatementType_Commit}, nil
+	case "vacuum":
+		return Statement{Type: StatementType_Vacuum}, nil
+	default:
+		return Statement{Type: StatementType_Rollback}, nil
+	}
+}
+
+// Execute runs a data statement against relation, transaction control
+// statements and VACUUM are handled by Session.
+func Execute(s Statement, relation core.Relation) (*Result, error) {
+	err := checkBound(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Type {
+	case StatementType_Insert:
+		return ExecuteInsert(s, relation)
+	case StatementType_Select:
+		return ExecuteSelect(s, relation)
+	case StatementType_Delete:
+		return ExecuteDelete(s, relation)
+	case StatementType_CreateTable:
+		return ExecuteCreateTable(s, relation)
+	default:
+		return nil, errors.New("UNRECOGNIZED_STATEMENT")
+	}
+}
+
+func checkBound(s Statement) error {
+	if s.numParams > 0 {
+		message := fmt.Sprintf("statement has %d unbound parameters", s.numParams)
+		return errors.New(message)
+	}
+	return nil
+}